@@ -0,0 +1,165 @@
+// Package accounts manages the lifecycle of user accounts, like exporting and deleting their data.
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"checklist-api/db"
+)
+
+// deletionWorkerInterval is how often RunDeletionWorker looks for accounts that are due to be deleted.
+const deletionWorkerInterval = 10 * time.Minute
+
+// DeletionGracePeriod reads ACCOUNT_DELETION_GRACE_PERIOD, a duration like "720h", which is how long an account
+// is kept after its user asks for it to be deleted. Without it, accounts are deleted straight away.
+func DeletionGracePeriod() (time.Duration, error) {
+	raw := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD")
+	if raw == "" {
+		return 0, nil
+	}
+
+	gracePeriod, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ACCOUNT_DELETION_GRACE_PERIOD: %w", err)
+	} else if gracePeriod < 0 {
+		return 0, fmt.Errorf("ACCOUNT_DELETION_GRACE_PERIOD can't be negative")
+	}
+
+	return gracePeriod, nil
+}
+
+// RequestDeletion schedules a user's account for deletion after the grace period, and returns when that will be.
+// Without a grace period the account is deleted right away, and deleted is true. If that fails the deletion stays
+// scheduled, and RunDeletionWorker finishes it.
+func RequestDeletion(userID string) (scheduledFor time.Time, deleted bool, err error) {
+	gracePeriod, err := DeletionGracePeriod()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	scheduledFor = time.Now().UTC().Add(gracePeriod)
+	err = service.ScheduleUserDeletion(userID, scheduledFor.Format(time.RFC3339))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	if gracePeriod > 0 {
+		return scheduledFor, false, nil
+	}
+
+	if err := DeleteAccount(userID); err != nil {
+		fmt.Printf("Error deleting account %s, it will be retried: %v\n", userID, err)
+		return scheduledFor, false, nil
+	}
+
+	return scheduledFor, true, nil
+}
+
+// CancelDeletion cancels a user's scheduled account deletion. It returns false if none was scheduled.
+func CancelDeletion(userID string) (bool, error) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return false, err
+	}
+
+	return service.CancelUserDeletion(userID)
+}
+
+// DeleteAccount deletes everything stored about a user: their memberships of shared checklists, the checklists they
+// own along with their collaborators, public links and invitations, their personal access tokens, webhooks,
+// notifications and the invitations they got, their share codes and finally their Users record. Every step can be
+// repeated, so a deletion that fails part way is resumed by running it again.
+// The Users record goes last, since its pending deletion is what the worker resumes from.
+func DeleteAccount(userID string) error {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return err
+	}
+
+	if err := service.DeleteUserMemberships(userID); err != nil {
+		return fmt.Errorf("failed to delete memberships, %v", err)
+	}
+
+	if err := service.DeleteOwnedChecklists(userID); err != nil {
+		return fmt.Errorf("failed to delete checklists, %v", err)
+	}
+
+	if err := service.DeleteUserAccessTokens(userID); err != nil {
+		return fmt.Errorf("failed to delete access tokens, %v", err)
+	}
+
+	if err := service.DeleteUserWebhooks(userID); err != nil {
+		return fmt.Errorf("failed to delete webhooks, %v", err)
+	}
+
+	if err := service.DeleteUserNotifications(userID); err != nil {
+		return fmt.Errorf("failed to delete notifications, %v", err)
+	}
+
+	if err := service.DeleteUserInvitations(userID); err != nil {
+		return fmt.Errorf("failed to delete invitations, %v", err)
+	}
+
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return err
+	}
+	defer redisService.Client.Close()
+
+	if err := redisService.DeleteUserShareCodes(userID); err != nil {
+		return fmt.Errorf("failed to delete share codes, %v", err)
+	}
+
+	if err := service.DeleteUser(userID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account %s deleted\n", userID)
+	return nil
+}
+
+// RunDeletionWorker deletes the accounts whose grace period has passed, and resumes deletions that failed,
+// until the context is done.
+func RunDeletionWorker(ctx context.Context) {
+	ticker := time.NewTicker(deletionWorkerInterval)
+	defer ticker.Stop()
+
+	for {
+		deleteDueAccounts()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteDueAccounts deletes every account that is due. A failed deletion is logged, and retried on the next run.
+func deleteDueAccounts() {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		fmt.Printf("Error setting up DynamoDBService: %v\n", err)
+		return
+	}
+
+	userIDs, err := service.GetUsersDueForDeletion(time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		fmt.Printf("Error finding accounts to delete: %v\n", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := DeleteAccount(userID); err != nil {
+			fmt.Printf("Error deleting account %s, it will be retried: %v\n", userID, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package accounts manages the lifecycle of user accounts, like exporting and deleting their data.
+package accounts
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/db/migrate"
+	"checklist-api/models"
+)
+
+func TestDeletionGracePeriod(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		expected time.Duration
+		fails    bool
+	}{
+		"unset":    {value: "", expected: 0},
+		"duration": {value: "720h", expected: 720 * time.Hour},
+		"invalid":  {value: "a month", fails: true},
+		"negative": {value: "-1h", fails: true},
+	}
+
+	for name, test := range tests {
+		t.Setenv("ACCOUNT_DELETION_GRACE_PERIOD", test.value)
+
+		gracePeriod, err := DeletionGracePeriod()
+		if test.fails {
+			if err == nil {
+				t.Fatalf("%s: expected an error, but got %v", name, gracePeriod)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: expected no error, but got: %v", name, err)
+		} else if gracePeriod != test.expected {
+			t.Fatalf("%s: expected %v, but got %v", name, test.expected, gracePeriod)
+		}
+	}
+}
+
+// localServices connects to the local DynamoDB and Redis that docker-compose starts, and sets up the tables. Like
+// the sharing tests, these tests need them running, and they are skipped without them.
+func localServices(t *testing.T) (*db.DynamoDBService, *db.RedisService) {
+	t.Helper()
+
+	for _, address := range []string{"localhost:8000", "localhost:6379"} {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err != nil {
+			t.Skip("Local DynamoDB and Redis aren't running, start them with docker-compose up")
+		}
+		conn.Close()
+	}
+
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("REDIS_URL", "localhost:6379")
+	t.Setenv("ACCOUNT_DELETION_GRACE_PERIOD", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "local")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+
+	if err := migrate.RunMigrations(); err != nil {
+		t.Fatalf("Failed to set up tables: %v", err)
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		t.Fatalf("Failed to set up DynamoDBService: %v", err)
+	}
+
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		t.Fatalf("Failed to set up RedisService: %v", err)
+	}
+	t.Cleanup(func() { redisService.Client.Close() })
+
+	return service, redisService
+}
+
+// testUser stores a user with a unique email, and deletes them after the test if they are still there.
+func testUser(t *testing.T, service *db.DynamoDBService, name string) string {
+	t.Helper()
+
+	userID := name + "-" + uuid.New().String()
+	if err := service.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() { service.DeleteUser(userID) })
+
+	return userID
+}
+
+// testChecklist stores a checklist with an item for the owner, and deletes it after the test if it is still there.
+func testChecklist(t *testing.T, service *db.DynamoDBService, ownerID string) models.Checklist {
+	t.Helper()
+
+	now := time.Now().Format(time.RFC3339)
+	checklist := models.Checklist{ID: uuid.New().String(), Title: "Groceries", CreatedAt: now, UpdatedAt: now}
+	if err := service.CreateChecklist(ownerID, &checklist); err != nil {
+		t.Fatalf("Failed to create checklist: %v", err)
+	}
+	t.Cleanup(func() { service.DeleteChecklist(ownerID, checklist.ID) })
+
+	item := models.ChecklistItem{ID: uuid.New().String(), Content: "Milk", CreatedAt: now, UpdatedAt: now}
+	if err := service.CreateChecklistItem(ownerID, checklist.ID, &item); err != nil {
+		t.Fatalf("Failed to create item: %v", err)
+	}
+
+	return checklist
+}
+
+// testAccount gives alice a bit of everything DeleteAccount deletes: a checklist with an item, a collaborator and
+// an invitation, a membership of bob's checklist, an invitation from bob, a personal access token, a webhook, a
+// notification and a share code. It returns alice's and bob's IDs, and their checklists.
+func testAccount(t *testing.T, service *db.DynamoDBService, redisService *db.RedisService) (string, string, models.Checklist, models.Checklist) {
+	t.Helper()
+
+	aliceID := testUser(t, service, "alice")
+	bobID := testUser(t, service, "bob")
+	aliceChecklist := testChecklist(t, service, aliceID)
+	bobChecklist := testChecklist(t, service, bobID)
+
+	if err := service.AddCollaborator(aliceID, aliceChecklist.ID, bobID, models.RoleEditor); err != nil {
+		t.Fatalf("Failed to add collaborator: %v", err)
+	}
+	if err := service.AddCollaborator(bobID, bobChecklist.ID, aliceID, models.RoleEditor); err != nil {
+		t.Fatalf("Failed to add collaborator: %v", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(24 * time.Hour).Format(time.RFC3339)
+	invitations := []models.Invitation{
+		{ChecklistID: aliceChecklist.ID, OwnerID: aliceID, Email: "carol@example.com", Delivery: models.InvitationEmail},
+		{ChecklistID: bobChecklist.ID, OwnerID: bobID, InviteeID: aliceID, Email: aliceID + "@example.com", Delivery: models.InvitationInApp},
+	}
+	for _, invitation := range invitations {
+		invitation.ID = uuid.New().String()
+		invitation.Role = models.RoleEditor
+		invitation.CreatedAt = now.Format(time.RFC3339)
+		invitation.SentAt = invitation.CreatedAt
+		invitation.ExpiresAt = expiresAt
+		if err := service.CreateInvitation(invitation); err != nil {
+			t.Fatalf("Failed to create invitation: %v", err)
+		}
+		t.Cleanup(func() { service.DeleteInvitation(invitation.ID) })
+	}
+
+	token := models.AccessToken{ID: uuid.New().String(), Name: "backup script", Scopes: []string{models.ScopeChecklistsRead}, CreatedAt: now.Format(time.RFC3339)}
+	if err := service.CreateAccessToken(aliceID, uuid.New().String(), &token); err != nil {
+		t.Fatalf("Failed to create access token: %v", err)
+	}
+
+	webhook := models.Webhook{ID: uuid.New().String(), URL: "https://example.com/hook", Events: []string{"item.checked"}, CreatedAt: now.Format(time.RFC3339)}
+	if err := service.CreateWebhook(aliceID, "secret", &webhook); err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+
+	notification := models.Notification{ID: uuid.New().String(), Type: models.NotificationItemChecked, ChecklistID: bobChecklist.ID, CreatedAt: now.Format(time.RFC3339)}
+	if err := service.CreateNotification(aliceID, &notification, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("Failed to create notification: %v", err)
+	}
+
+	shortCode := "test-" + uuid.New().String()
+	if err := redisService.SetShortCodeWithJWT(shortCode, "jwt"); err != nil {
+		t.Fatalf("Failed to store share code: %v", err)
+	}
+	if err := redisService.AddUserShareCode(aliceID, shortCode); err != nil {
+		t.Fatalf("Failed to record share code: %v", err)
+	}
+
+	return aliceID, bobID, aliceChecklist, bobChecklist
+}
+
+// expectDeleted fails the test if anything testAccount gave alice is left, other than bob's checklist.
+func expectDeleted(t *testing.T, service *db.DynamoDBService, redisService *db.RedisService, aliceID string, bobID string, aliceChecklist models.Checklist, bobChecklist models.Checklist) {
+	t.Helper()
+
+	if user, err := service.GetUser(aliceID); err != nil || user.ID != "" {
+		t.Fatalf("Expected the user to be deleted, but got %+v and %v", user, err)
+	}
+	if checklist, err := service.GetChecklist(aliceID, aliceChecklist.ID); err != nil || checklist.ID != "" {
+		t.Fatalf("Expected the checklist to be deleted, but got %+v and %v", checklist, err)
+	}
+	if items, err := service.GetChecklistItems(aliceID, aliceChecklist.ID); err != nil || len(items) != 0 {
+		t.Fatalf("Expected the items to be deleted, but got %+v and %v", items, err)
+	}
+	if _, role, err := service.GetCollaboratorRole(bobID, aliceChecklist.ID); err != nil || role != "" {
+		t.Fatalf("Expected bob's membership of the deleted checklist to be gone, but got %q and %v", role, err)
+	}
+	if _, role, err := service.GetCollaboratorRole(aliceID, bobChecklist.ID); err != nil || role != "" {
+		t.Fatalf("Expected the membership of bob's checklist to be deleted, but got %q and %v", role, err)
+	}
+	if checklist, err := service.GetChecklist(bobID, bobChecklist.ID); err != nil || checklist.ID != bobChecklist.ID {
+		t.Fatalf("Expected bob's checklist to be kept, but got %+v and %v", checklist, err)
+	}
+	if invitations, err := service.GetChecklistInvitations(aliceChecklist.ID); err != nil || len(invitations) != 0 {
+		t.Fatalf("Expected the checklist's invitations to be deleted, but got %+v and %v", invitations, err)
+	}
+	if invitations, err := service.GetUserInvitations(aliceID); err != nil || len(invitations) != 0 {
+		t.Fatalf("Expected the invitations to the user to be deleted, but got %+v and %v", invitations, err)
+	}
+	if tokens, err := service.GetAccessTokens(aliceID); err != nil || len(tokens) != 0 {
+		t.Fatalf("Expected the access tokens to be deleted, but got %+v and %v", tokens, err)
+	}
+	if webhooks, err := service.GetWebhooks(aliceID); err != nil || len(webhooks) != 0 {
+		t.Fatalf("Expected the webhooks to be deleted, but got %+v and %v", webhooks, err)
+	}
+	if notifications, _, err := service.GetNotifications(aliceID, "", 10); err != nil || len(notifications) != 0 {
+		t.Fatalf("Expected the notifications to be deleted, but got %+v and %v", notifications, err)
+	}
+	if shortCodes, err := redisService.Client.SMembers(context.Background(), "sharecodes:USER#"+aliceID).Result(); err != nil || len(shortCodes) != 0 {
+		t.Fatalf("Expected the share codes to be deleted, but got %v and %v", shortCodes, err)
+	}
+}
+
+func TestDeleteAccountCascade(t *testing.T) {
+	service, redisService := localServices(t)
+	aliceID, bobID, aliceChecklist, bobChecklist := testAccount(t, service, redisService)
+
+	if _, deleted, err := RequestDeletion(aliceID); err != nil || !deleted {
+		t.Fatalf("Expected the account to be deleted right away, but got %v and %v", deleted, err)
+	}
+
+	expectDeleted(t, service, redisService, aliceID, bobID, aliceChecklist, bobChecklist)
+}
+
+func TestDeleteAccountResumes(t *testing.T) {
+	service, redisService := localServices(t)
+	aliceID, bobID, aliceChecklist, bobChecklist := testAccount(t, service, redisService)
+
+	if err := service.ScheduleUserDeletion(aliceID, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		t.Fatalf("Failed to schedule deletion: %v", err)
+	}
+
+	// with Redis out of reach, the deletion fails after clearing DynamoDB and before deleting the user
+	t.Setenv("REDIS_URL", "localhost:1")
+	if err := DeleteAccount(aliceID); err == nil {
+		t.Fatalf("Expected the deletion to fail without Redis")
+	}
+	if user, err := service.GetUser(aliceID); err != nil || user.ID != aliceID {
+		t.Fatalf("Expected the user to be kept for the worker to resume from, but got %+v and %v", user, err)
+	}
+
+	t.Setenv("REDIS_URL", "localhost:6379")
+	deleteDueAccounts()
+
+	expectDeleted(t, service, redisService, aliceID, bobID, aliceChecklist, bobChecklist)
+}
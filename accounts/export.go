@@ -0,0 +1,504 @@
+// Package accounts manages the lifecycle of user accounts, like exporting and deleting their data.
+package accounts
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+// The statuses of an account export.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// exportSyncLimit is the most checklists and items an account can have for its export to be built during the request.
+// Larger accounts are exported in the background.
+const exportSyncLimit = 500
+
+// exportTTL is how long a background export, and the link to download it, stay available.
+const exportTTL = time.Hour
+
+// exportLease is how long a background export counts as being built without its lease being renewed.
+const exportLease = time.Minute
+
+// Export is everything stored about a user, as included in their data export.
+type Export struct {
+	ExportedAt    string                `json:"exported_at"`
+	Profile       models.User           `json:"profile"`
+	Preferences   models.Preferences    `json:"preferences"`
+	Checklists    []ExportedChecklist   `json:"checklists"`
+	Memberships   []ExportedMembership  `json:"memberships"`
+	AccessTokens  []models.AccessToken  `json:"access_tokens"`
+	Webhooks      []models.Webhook      `json:"webhooks"`
+	Notifications []models.Notification `json:"notifications"`
+	Activity      []ExportedActivity    `json:"activity"`
+}
+
+// ExportedChecklist is a checklist the user owns, with its items.
+type ExportedChecklist struct {
+	models.Checklist
+	Items []models.ChecklistItem `json:"items"`
+}
+
+// ExportedMembership is a checklist shared with the user, and their role on it.
+type ExportedMembership struct {
+	ChecklistID string      `json:"checklist_id"`
+	Title       string      `json:"title"`
+	Role        models.Role `json:"role"`
+}
+
+// ExportedActivity is something the user did, as recorded by the timestamps on their data.
+// No separate activity log is kept, so this is all the activity there is to export.
+type ExportedActivity struct {
+	Time        string `json:"time"`
+	Action      string `json:"action"`
+	ChecklistID string `json:"checklist_id,omitempty"`
+	ItemID      string `json:"item_id,omitempty"`
+}
+
+// ShouldExportInBackground reports whether a user's account is too large to export during a request.
+func ShouldExportInBackground(userID string) (bool, error) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return false, err
+	}
+
+	count, err := service.CountUserRecords(userID)
+	if err != nil {
+		return false, err
+	}
+
+	return count > exportSyncLimit, nil
+}
+
+// BuildExport collects everything stored about a user, and returns it as a ZIP archive.
+func BuildExport(userID string) ([]byte, error) {
+	export, err := collectExport(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeExportArchive(&buf, export); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// StartExport builds a user's export in the background, and returns its ID. Its progress is read with ExportStatus.
+// The export holds a lease while it is built, so RunExportSweeper can fail it if this instance stops first.
+func StartExport(userID string) (string, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return "", err
+	}
+
+	exportID := uuid.New().String()
+	err = redisService.SetExportStatus(exportID, userID, ExportPending, "", exportTTL)
+	if err == nil {
+		err = redisService.AddPendingExport(exportID, exportLease)
+	}
+	if err != nil {
+		redisService.Client.Close()
+		return "", err
+	}
+
+	// the goroutine closes the client once the export is built and its status saved
+	go func() {
+		defer redisService.Client.Close()
+
+		done := make(chan struct{})
+		go renewExportLease(redisService, exportID, done)
+
+		archive, err := BuildExport(userID)
+		if err == nil {
+			err = redisService.SetExportArchive(exportID, archive, exportTTL)
+		}
+		close(done)
+
+		if err != nil {
+			fmt.Printf("Error exporting account %s: %v\n", userID, err)
+			err = redisService.SetExportStatus(exportID, userID, ExportFailed, err.Error(), exportTTL)
+		} else {
+			err = redisService.SetExportStatus(exportID, userID, ExportReady, "", exportTTL)
+		}
+		if err == nil {
+			err = redisService.RemovePendingExport(exportID)
+		}
+		if err != nil {
+			fmt.Printf("Error saving export status %s: %v\n", exportID, err)
+		}
+	}()
+
+	return exportID, nil
+}
+
+// renewExportLease keeps renewing a background export's lease until done is closed.
+func renewExportLease(redisService *db.RedisService, exportID string, done <-chan struct{}) {
+	ticker := time.NewTicker(exportLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := redisService.RenewExportLease(exportID, exportLease); err != nil {
+				fmt.Printf("Error renewing lease of export %s: %v\n", exportID, err)
+			}
+		}
+	}
+}
+
+// RunExportSweeper marks background exports as failed when their lease runs out, because the instance building them
+// stopped, like on a restart. Otherwise they would stay pending until they expire. It runs until the context is done.
+func RunExportSweeper(ctx context.Context) {
+	ticker := time.NewTicker(exportLease)
+	defer ticker.Stop()
+
+	for {
+		failAbandonedExports()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// failAbandonedExports marks every background export whose lease has run out as failed.
+func failAbandonedExports() {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		fmt.Printf("Error setting up RedisService: %v\n", err)
+		return
+	}
+	defer redisService.Client.Close()
+
+	exportIDs, err := redisService.GetAbandonedExports()
+	if err != nil {
+		fmt.Printf("Error finding abandoned exports: %v\n", err)
+		return
+	}
+
+	for _, exportID := range exportIDs {
+		userID, status, _, err := redisService.GetExportStatus(exportID)
+		if err == nil && userID != "" && status == ExportPending {
+			err = redisService.SetExportStatus(exportID, userID, ExportFailed, "export was interrupted, start a new one", exportTTL)
+		}
+		if err == nil {
+			err = redisService.RemovePendingExport(exportID)
+		}
+		if err != nil {
+			fmt.Printf("Error failing abandoned export %s: %v\n", exportID, err)
+		}
+	}
+}
+
+// ExportStatus returns the status of a user's background export, and its error if it failed.
+// The status is empty if the user has no export with that ID, or it has expired.
+func ExportStatus(userID string, exportID string) (string, string, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return "", "", err
+	}
+	defer redisService.Client.Close()
+
+	owner, status, exportErr, err := redisService.GetExportStatus(exportID)
+	if err != nil {
+		return "", "", err
+	} else if owner != userID {
+		return "", "", nil
+	}
+
+	return status, exportErr, nil
+}
+
+// ExportArchive returns a finished background export. It is nil if the export has expired.
+func ExportArchive(exportID string) ([]byte, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, err
+	}
+	defer redisService.Client.Close()
+
+	return redisService.GetExportArchive(exportID)
+}
+
+// SignExportDownload returns the expiry and signature for a link to download an export, so it can be downloaded
+// without a token until the link expires.
+func SignExportDownload(exportID string) (string, string) {
+	expires := strconv.FormatInt(time.Now().Add(exportTTL).Unix(), 10)
+	return expires, exportSignature(exportID, expires)
+}
+
+// VerifyExportDownload checks that a download link for an export was signed by SignExportDownload, and hasn't expired.
+func VerifyExportDownload(exportID string, expires string, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(exportSignature(exportID, expires)))
+}
+
+// exportSignature signs an export's ID and expiry with the same secret as share codes.
+func exportSignature(exportID string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("export:" + exportID + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// collectExport reads everything stored about a user.
+func collectExport(userID string) (Export, error) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return Export{}, err
+	}
+
+	export := Export{
+		ExportedAt:    time.Now().UTC().Format(time.RFC3339),
+		Checklists:    []ExportedChecklist{},
+		Memberships:   []ExportedMembership{},
+		AccessTokens:  []models.AccessToken{},
+		Webhooks:      []models.Webhook{},
+		Notifications: []models.Notification{},
+		Activity:      []ExportedActivity{},
+	}
+
+	export.Profile, err = service.GetUser(userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("failed to get user, %v", err)
+	}
+	export.Profile.ID = userID
+
+	export.Preferences, err = service.GetUserPreferences(userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("failed to get preferences, %v", err)
+	}
+
+	checklists, err := service.GetChecklists(userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("failed to get checklists, %v", err)
+	}
+
+	for _, checklist := range checklists {
+		items, err := service.GetChecklistItems(userID, checklist.ID)
+		if err != nil {
+			return Export{}, fmt.Errorf("failed to get checklist items, %v", err)
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Ordering < items[j].Ordering })
+
+		export.Checklists = append(export.Checklists, ExportedChecklist{Checklist: checklist, Items: items})
+	}
+
+	shared, err := service.GetSharedChecklists(userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("failed to get shared checklists, %v", err)
+	}
+
+	for _, checklist := range shared {
+		_, role, err := service.GetCollaboratorRole(userID, checklist.ID)
+		if err != nil {
+			return Export{}, fmt.Errorf("failed to get role, %v", err)
+		}
+
+		export.Memberships = append(export.Memberships, ExportedMembership{
+			ChecklistID: checklist.ID,
+			Title:       checklist.Title,
+			Role:        role,
+		})
+	}
+
+	export.AccessTokens, err = service.GetAccessTokens(userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("failed to get access tokens, %v", err)
+	}
+
+	export.Webhooks, err = service.GetWebhooks(userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("failed to get webhooks, %v", err)
+	}
+
+	export.Notifications, err = service.GetAllNotifications(userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("failed to get notifications, %v", err)
+	}
+
+	export.Activity = exportActivity(export)
+
+	return export, nil
+}
+
+// exportActivity lists what the user did, oldest first, from the timestamps on their checklists, items, tokens and
+// webhooks.
+func exportActivity(export Export) []ExportedActivity {
+	activity := []ExportedActivity{}
+
+	for _, checklist := range export.Checklists {
+		activity = append(activity, ExportedActivity{Time: checklist.CreatedAt, Action: "checklist_created", ChecklistID: checklist.ID})
+		if checklist.UpdatedAt != checklist.CreatedAt {
+			activity = append(activity, ExportedActivity{Time: checklist.UpdatedAt, Action: "checklist_updated", ChecklistID: checklist.ID})
+		}
+
+		for _, item := range checklist.Items {
+			activity = append(activity, ExportedActivity{Time: item.CreatedAt, Action: "item_created", ChecklistID: checklist.ID, ItemID: item.ID})
+			if item.UpdatedAt != item.CreatedAt {
+				activity = append(activity, ExportedActivity{Time: item.UpdatedAt, Action: "item_updated", ChecklistID: checklist.ID, ItemID: item.ID})
+			}
+		}
+	}
+
+	for _, token := range export.AccessTokens {
+		activity = append(activity, ExportedActivity{Time: token.CreatedAt, Action: "access_token_created"})
+	}
+
+	for _, webhook := range export.Webhooks {
+		activity = append(activity, ExportedActivity{Time: webhook.CreatedAt, Action: "webhook_created", ChecklistID: webhook.ChecklistID})
+	}
+
+	sort.SliceStable(activity, func(i, j int) bool { return activity[i].Time < activity[j].Time })
+
+	return activity
+}
+
+// writeExportArchive writes the export as a ZIP archive, with the data as JSON and a readable Markdown copy.
+func writeExportArchive(w io.Writer, export Export) error {
+	archive := zip.NewWriter(w)
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := archive.Create("export.json")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	file, err = archive.Create("export.md")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(file, exportMarkdown(export)); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// markdownEscaper escapes the characters that Markdown would read as formatting, and keeps text on one line.
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "`", "\\`", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]", "<", "\\<", ">", "\\>",
+	"#", "\\#", "|", "\\|", "~", "\\~", "!", "\\!", "&", "\\&", "\r\n", " ", "\n", " ", "\r", " ",
+)
+
+// escapeMarkdown escapes text the user wrote, like titles and items, so it reads the same in the Markdown export.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// exportMarkdown renders the export as a Markdown document, with each checklist as a task list.
+func exportMarkdown(export Export) string {
+	var md strings.Builder
+
+	fmt.Fprintf(&md, "# Listo data export\n\nExported at %s.\n\n", export.ExportedAt)
+
+	md.WriteString("## Profile\n\n")
+	fmt.Fprintf(&md, "- ID: %s\n", export.Profile.ID)
+	if export.Profile.Email != "" {
+		fmt.Fprintf(&md, "- Email: %s\n", export.Profile.Email)
+	}
+	if export.Profile.Picture != "" {
+		fmt.Fprintf(&md, "- Picture: %s\n", export.Profile.Picture)
+	}
+
+	if export.Preferences.DisplayName != "" {
+		fmt.Fprintf(&md, "- Display name: %s\n", escapeMarkdown(export.Preferences.DisplayName))
+	}
+	fmt.Fprintf(&md, "- Time zone: %s\n", export.Preferences.TimeZone)
+	if export.Preferences.Locale != "" {
+		fmt.Fprintf(&md, "- Locale: %s\n", export.Preferences.Locale)
+	}
+
+	md.WriteString("\n## Checklists\n")
+	if len(export.Checklists) == 0 {
+		md.WriteString("\nNo checklists.\n")
+	}
+	for _, checklist := range export.Checklists {
+		fmt.Fprintf(&md, "\n### %s\n\nCreated %s, last updated %s.\n\n", escapeMarkdown(checklist.Title), checklist.CreatedAt, checklist.UpdatedAt)
+		for _, item := range checklist.Items {
+			mark := " "
+			if item.Checked {
+				mark = "x"
+			}
+			fmt.Fprintf(&md, "- [%s] %s\n", mark, escapeMarkdown(item.Content))
+		}
+	}
+
+	md.WriteString("\n## Checklists shared with you\n\n")
+	if len(export.Memberships) == 0 {
+		md.WriteString("None.\n")
+	}
+	for _, membership := range export.Memberships {
+		fmt.Fprintf(&md, "- %s (%s)\n", escapeMarkdown(membership.Title), membership.Role)
+	}
+
+	md.WriteString("\n## Personal access tokens\n\n")
+	if len(export.AccessTokens) == 0 {
+		md.WriteString("None.\n")
+	}
+	for _, token := range export.AccessTokens {
+		fmt.Fprintf(&md, "- %s, created %s, scopes: %s\n", escapeMarkdown(token.Name), token.CreatedAt, strings.Join(token.Scopes, ", "))
+	}
+
+	md.WriteString("\n## Webhooks\n\n")
+	if len(export.Webhooks) == 0 {
+		md.WriteString("None.\n")
+	}
+	for _, webhook := range export.Webhooks {
+		fmt.Fprintf(&md, "- %s, created %s, events: %s\n", webhook.URL, webhook.CreatedAt, strings.Join(webhook.Events, ", "))
+	}
+
+	md.WriteString("\n## Notifications\n\n")
+	if len(export.Notifications) == 0 {
+		md.WriteString("None.\n")
+	}
+	for _, notification := range export.Notifications {
+		fmt.Fprintf(&md, "- %s: %s on %s by %s\n", notification.CreatedAt, notification.Type, escapeMarkdown(notification.ChecklistTitle), notification.Actor.Email)
+	}
+
+	md.WriteString("\n## Activity\n\n")
+	if len(export.Activity) == 0 {
+		md.WriteString("None.\n")
+	}
+	for _, activity := range export.Activity {
+		fmt.Fprintf(&md, "- %s: %s\n", activity.Time, strings.ReplaceAll(activity.Action, "_", " "))
+	}
+
+	return md.String()
+}
@@ -0,0 +1,121 @@
+// Package accounts manages the lifecycle of user accounts, like exporting and deleting their data.
+package accounts
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"checklist-api/models"
+)
+
+func testExport() Export {
+	export := Export{
+		ExportedAt: "2024-03-01T00:00:00Z",
+		Profile:    models.User{ID: "alice", Email: "alice@example.com"},
+		Checklists: []ExportedChecklist{
+			{
+				Checklist: models.Checklist{ID: "groceries", Title: "Groceries", CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-02T00:00:00Z"},
+				Items: []models.ChecklistItem{
+					{ID: "milk", Content: "Milk", Checked: true, CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-03T00:00:00Z"},
+					{ID: "eggs", Content: "Eggs", CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z"},
+				},
+			},
+		},
+		Memberships:  []ExportedMembership{{ChecklistID: "trip", Title: "Road trip", Role: models.RoleViewer}},
+		AccessTokens: []models.AccessToken{},
+	}
+	export.Activity = exportActivity(export)
+
+	return export
+}
+
+func TestExportActivity(t *testing.T) {
+	activity := testExport().Activity
+
+	actions := []string{}
+	for _, a := range activity {
+		actions = append(actions, a.Action)
+	}
+
+	expected := "checklist_created item_created item_created checklist_updated item_updated"
+	if strings.Join(actions, " ") != expected {
+		t.Fatalf("Expected activity %q, but got %q", expected, strings.Join(actions, " "))
+	}
+}
+
+func TestWriteExportArchive(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExportArchive(&buf, testExport()); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		files[file.Name] = string(content)
+	}
+
+	var export Export
+	if err := json.Unmarshal([]byte(files["export.json"]), &export); err != nil {
+		t.Fatalf("Failed to parse export.json: %v", err)
+	}
+	if len(export.Checklists) != 1 || len(export.Checklists[0].Items) != 2 {
+		t.Fatalf("Expected one checklist with two items, but got %+v", export.Checklists)
+	}
+
+	for _, line := range []string{"### Groceries", "- [x] Milk", "- [ ] Eggs", "- Road trip (viewer)"} {
+		if !strings.Contains(files["export.md"], line) {
+			t.Fatalf("Expected export.md to contain %q, but got:\n%s", line, files["export.md"])
+		}
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := map[string]string{
+		"Milk":                      "Milk",
+		"**bold** _it_":             "\\*\\*bold\\*\\* \\_it\\_",
+		"[link](http://x)":          "\\[link\\](http://x)",
+		"<script>":                  "\\<script\\>",
+		"# not a heading\n- [x] no": "\\# not a heading - \\[x\\] no",
+		"back\\slash `code`":        "back\\\\slash \\`code\\`",
+	}
+
+	for text, expected := range tests {
+		if escaped := escapeMarkdown(text); escaped != expected {
+			t.Errorf("Expected %q to be escaped as %q, but got %q", text, expected, escaped)
+		}
+	}
+}
+
+func TestVerifyExportDownload(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+
+	expires, signature := SignExportDownload("export-1")
+	if !VerifyExportDownload("export-1", expires, signature) {
+		t.Fatal("Expected a signed link to verify")
+	}
+	if VerifyExportDownload("export-2", expires, signature) {
+		t.Fatal("Expected a link for another export to be rejected")
+	}
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if VerifyExportDownload("export-1", expired, exportSignature("export-1", expired)) {
+		t.Fatal("Expected an expired link to be rejected")
+	}
+}
@@ -0,0 +1,190 @@
+// Package collab merges concurrent edits to an item's content. Edits are operations made against a version of the
+// content. The server puts them in order, transforms each against the edits its author hadn't seen yet, and keeps
+// recent edits in Redis so that can be done.
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/models"
+	"checklist-api/ot"
+)
+
+// historyLength is how many edits are kept for each item. A client further behind than that has to reload the item.
+const historyLength = 200
+
+// historyTTL is how long an item's edits are kept after the last one.
+const historyTTL = 24 * time.Hour
+
+// maxAttempts is how many times an edit is retried when other edits keep getting there first.
+const maxAttempts = 5
+
+var (
+	// ErrItemNotFound is returned when the item doesn't exist.
+	ErrItemNotFound = errors.New("item does not exist")
+	// ErrVersionAhead is returned for edits made against a version the item hasn't reached.
+	ErrVersionAhead = errors.New("edit is against a version the item hasn't reached")
+	// ErrHistoryUnavailable is returned when the edits made since an edit's version are no longer kept.
+	ErrHistoryUnavailable = errors.New("edits since that version are no longer available")
+	// ErrTooManyConflicts is returned when an edit couldn't be saved because other edits kept getting there first.
+	ErrTooManyConflicts = errors.New("too many concurrent edits")
+)
+
+// Edit is an operation that moved an item's content on from Version to the next version.
+type Edit struct {
+	Version   int          `json:"version"`
+	Operation ot.Operation `json:"operation"`
+}
+
+// ItemUpdate is an item after an edit, along with the edit, so clients that are at the version before can apply the
+// operation instead of replacing their content. Its JSON is the item's, with an added operation.
+type ItemUpdate struct {
+	models.ChecklistItem
+	Operation ot.Operation `json:"operation,omitempty"`
+}
+
+// Apply merges an operation made against version of an item's content, and returns the item with the edit as it was
+// applied. ownerID is the owner of the checklist.
+func Apply(ownerID string, checklistID string, itemID string, version int, op ot.Operation) (models.ChecklistItem, Edit, error) {
+	if err := op.Validate(); err != nil {
+		return models.ChecklistItem{}, Edit{}, err
+	}
+
+	return edit(ownerID, checklistID, itemID, func(item models.ChecklistItem, redisService *db.RedisService) (Edit, error) {
+		if version > item.Version {
+			return Edit{}, ErrVersionAhead
+		} else if version == item.Version {
+			return Edit{Version: version, Operation: op}, nil
+		} else if redisService == nil {
+			return Edit{}, ErrHistoryUnavailable
+		}
+
+		records, err := redisService.GetItemEdits(itemID, version, item.Version)
+		if err != nil {
+			return Edit{}, err
+		}
+
+		history := make([]Edit, len(records))
+		for i, record := range records {
+			if err := json.Unmarshal([]byte(record), &history[i]); err != nil {
+				return Edit{}, fmt.Errorf("failed to read edit, %v", err)
+			}
+		}
+
+		transformed, err := transformAgainst(history, version, item.Version, op)
+		if err != nil {
+			return Edit{}, err
+		}
+
+		return Edit{Version: item.Version, Operation: transformed}, nil
+	})
+}
+
+// Replace sets an item's content outright, for clients that send whole content instead of operations. It is
+// recorded as an edit too, so clients editing with operations can merge with it. The edit is empty if the content
+// didn't change.
+func Replace(ownerID string, checklistID string, itemID string, content string) (models.ChecklistItem, Edit, error) {
+	return edit(ownerID, checklistID, itemID, func(item models.ChecklistItem, _ *db.RedisService) (Edit, error) {
+		if item.Content == content {
+			return Edit{Version: item.Version}, nil
+		}
+
+		return Edit{Version: item.Version, Operation: ot.Diff(item.Content, content)}, nil
+	})
+}
+
+// edit saves the edit that prepare makes against the item's current version, and records it. When another edit gets
+// there first, the item is read again and prepare is called again.
+func edit(ownerID string, checklistID string, itemID string, prepare func(models.ChecklistItem, *db.RedisService) (Edit, error)) (models.ChecklistItem, Edit, error) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return models.ChecklistItem{}, Edit{}, err
+	}
+
+	// without Redis, edits against the current version still work, but they can't be recorded for other clients
+	redisService, err := db.NewRedisService()
+	if err == nil {
+		defer redisService.Client.Close()
+	} else {
+		redisService = nil
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		item, err := service.GetChecklistItem(ownerID, checklistID, itemID)
+		if err != nil {
+			return models.ChecklistItem{}, Edit{}, err
+		} else if item.ID == "" {
+			return models.ChecklistItem{}, Edit{}, ErrItemNotFound
+		}
+
+		edit, err := prepare(item, redisService)
+		if err != nil {
+			return models.ChecklistItem{}, Edit{}, err
+		} else if edit.Operation == nil {
+			return item, edit, nil
+		}
+
+		content, err := ot.Apply(item.Content, edit.Operation)
+		if err != nil {
+			return models.ChecklistItem{}, Edit{}, err
+		}
+
+		updatedAt := time.Now().Format(time.RFC3339)
+		err = service.UpdateChecklistItemContent(ownerID, checklistID, itemID, content, item.Version, updatedAt)
+		if errors.Is(err, db.ErrItemVersionConflict) {
+			continue
+		} else if err != nil {
+			return models.ChecklistItem{}, Edit{}, err
+		}
+
+		if redisService != nil {
+			if err := recordEdit(redisService, itemID, edit); err != nil {
+				fmt.Println("Error recording edit, clients behind it will have to reload: " + err.Error())
+			}
+		}
+
+		item.Content = content
+		item.Version++
+		item.UpdatedAt = updatedAt
+
+		return item, edit, nil
+	}
+
+	return models.ChecklistItem{}, Edit{}, ErrTooManyConflicts
+}
+
+// recordEdit keeps an edit, so edits made against the versions before it can be transformed against it.
+func recordEdit(redisService *db.RedisService, itemID string, edit Edit) error {
+	payload, err := json.Marshal(edit)
+	if err != nil {
+		return err
+	}
+
+	return redisService.AddItemEdit(itemID, edit.Version, payload, historyLength, historyTTL)
+}
+
+// transformAgainst transforms an operation made against fromVersion past the edits up to toVersion, which were
+// sequenced before it. history must hold every one of those edits, in order.
+func transformAgainst(history []Edit, fromVersion int, toVersion int, op ot.Operation) (ot.Operation, error) {
+	if len(history) != toVersion-fromVersion {
+		return nil, ErrHistoryUnavailable
+	}
+
+	for i, edit := range history {
+		if edit.Version != fromVersion+i {
+			return nil, ErrHistoryUnavailable
+		}
+
+		var err error
+		_, op, err = ot.Transform(edit.Operation, op)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
+}
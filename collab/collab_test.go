@@ -0,0 +1,54 @@
+// Package collab merges concurrent edits to an item's content.
+package collab
+
+import (
+	"errors"
+	"testing"
+
+	"checklist-api/ot"
+)
+
+func TestTransformAgainstMergesConcurrentEdits(t *testing.T) {
+	// two edits to "Buy milk" were saved before this one, which was also made against version 0, so the "!" they
+	// appended goes before its " x2"
+	history := []Edit{
+		{Version: 0, Operation: ot.Diff("Buy milk", "Buy oat milk")},
+		{Version: 1, Operation: ot.Diff("Buy oat milk", "Buy oat milk!")},
+	}
+	op := ot.Diff("Buy milk", "Buy milk x2")
+
+	transformed, err := transformAgainst(history, 0, 2, op)
+	if err != nil {
+		t.Fatalf("Failed to transform edit: %v", err)
+	}
+
+	result, err := ot.Apply("Buy oat milk!", transformed)
+	if err != nil {
+		t.Fatalf("Failed to apply edit: %v", err)
+	}
+	if result != "Buy oat milk! x2" {
+		t.Fatalf("Expected \"Buy oat milk! x2\", but got %q", result)
+	}
+}
+
+func TestTransformAgainstNeedsEveryEdit(t *testing.T) {
+	history := []Edit{
+		{Version: 1, Operation: ot.Diff("Buy oat milk", "Buy oat milk!")},
+	}
+
+	_, err := transformAgainst(history, 0, 2, ot.Diff("Buy milk", "Buy milk x2"))
+	if !errors.Is(err, ErrHistoryUnavailable) {
+		t.Fatalf("Expected ErrHistoryUnavailable, but got %v", err)
+	}
+}
+
+func TestTransformAgainstRejectsWrongLength(t *testing.T) {
+	history := []Edit{
+		{Version: 0, Operation: ot.Diff("Buy milk", "Buy oat milk")},
+	}
+
+	_, err := transformAgainst(history, 0, 1, ot.Diff("Buy eggs please", "Buy eggs"))
+	if !errors.Is(err, ot.ErrLengthMismatch) {
+		t.Fatalf("Expected ErrLengthMismatch, but got %v", err)
+	}
+}
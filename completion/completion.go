@@ -0,0 +1,48 @@
+// Package completion tells when checklists are completed, by checking their last unchecked item. Each completion is
+// reported once to each reporter, like webhooks and notifications, until the checklist is reopened by unchecking or
+// adding an item. The marks are kept in Redis, so every API instance and the change streams share them.
+package completion
+
+import (
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+// The reporters of completions. Each is told about a completion once.
+const (
+	Webhooks      = "webhooks"
+	Notifications = "notifications"
+)
+
+// markTTL is how long a checklist is remembered as completed, so checking items again much later reports it again.
+const markTTL = 30 * 24 * time.Hour
+
+// Check reports whether a checklist is complete, and the reporter hasn't been told since it was last reopened. It
+// marks the checklist as reported to them, so it returns true only once per completion.
+func Check(dynamo *db.DynamoDBService, redisService *db.RedisService, reporter string, ownerID string, checklistID string) (bool, error) {
+	items, err := dynamo.GetChecklistItems(ownerID, checklistID)
+	if err != nil || !Complete(items) {
+		return false, err
+	}
+
+	return redisService.MarkChecklistCompleted(checklistID, reporter, markTTL)
+}
+
+// Reopen forgets that a checklist was completed, once it has an unchecked item again, so its next completion is
+// reported to every reporter.
+func Reopen(redisService *db.RedisService, checklistID string) error {
+	return redisService.ClearChecklistCompleted(checklistID)
+}
+
+// Complete reports whether a checklist with the given items is complete, which needs at least one item.
+func Complete(items []models.ChecklistItem) bool {
+	for _, item := range items {
+		if !item.Checked {
+			return false
+		}
+	}
+
+	return len(items) > 0
+}
@@ -0,0 +1,22 @@
+// Package completion tells when checklists are completed, by checking their last unchecked item. Each completion is
+// reported once to each reporter, like webhooks and notifications, until the checklist is reopened by unchecking or
+// adding an item. The marks are kept in Redis, so every API instance and the change streams share them.
+package completion
+
+import (
+	"testing"
+
+	"checklist-api/models"
+)
+
+func TestComplete(t *testing.T) {
+	if Complete(nil) {
+		t.Fatal("Expected a checklist without items not to be complete")
+	}
+	if Complete([]models.ChecklistItem{{Checked: true}, {Checked: false}}) {
+		t.Fatal("Expected a checklist with an unchecked item not to be complete")
+	}
+	if !Complete([]models.ChecklistItem{{Checked: true}, {Checked: true}}) {
+		t.Fatal("Expected a checklist with every item checked to be complete")
+	}
+}
@@ -0,0 +1,130 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateAccessToken stores a personal access token for a user, keyed by the token's hash.
+func (d *DynamoDBService) CreateAccessToken(userID string, tokenHash string, token *models.AccessToken) error {
+	item := map[string]types.AttributeValue{
+		"TokenHash": &types.AttributeValueMemberS{Value: tokenHash},
+		"ID":        &types.AttributeValueMemberS{Value: token.ID},
+		"UserID":    &types.AttributeValueMemberS{Value: userID},
+		"Name":      &types.AttributeValueMemberS{Value: token.Name},
+		"Scopes":    &types.AttributeValueMemberSS{Value: token.Scopes},
+		"CreatedAt": &types.AttributeValueMemberS{Value: token.CreatedAt},
+	}
+	if token.ExpiresAt != "" {
+		item["ExpiresAt"] = &types.AttributeValueMemberS{Value: token.ExpiresAt}
+	}
+
+	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:           aws.String("PersonalAccessTokens"),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(TokenHash)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item, %v", err)
+	}
+
+	return nil
+}
+
+// GetAccessTokens retrieves all personal access tokens for a user, oldest first.
+func (d *DynamoDBService) GetAccessTokens(userID string) ([]models.AccessToken, error) {
+	output, err := d.Client.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:              aws.String("PersonalAccessTokens"),
+		IndexName:              aws.String("UserIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table, %v", err)
+	}
+
+	tokens := []models.AccessToken{}
+	for _, item := range output.Items {
+		tokens = append(tokens, accessTokenFromItem(item))
+	}
+
+	return tokens, nil
+}
+
+// GetAccessTokenByHash retrieves a personal access token by its hash, along with the ID of the user it belongs to.
+// The user ID is empty if no token has that hash.
+func (d *DynamoDBService) GetAccessTokenByHash(tokenHash string) (string, models.AccessToken, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("PersonalAccessTokens"),
+		Key: map[string]types.AttributeValue{
+			"TokenHash": &types.AttributeValueMemberS{Value: tokenHash},
+		},
+	})
+	if err != nil {
+		return "", models.AccessToken{}, fmt.Errorf("failed to get item, %v", err)
+	}
+
+	if output.Item == nil {
+		return "", models.AccessToken{}, nil
+	}
+
+	userID := output.Item["UserID"].(*types.AttributeValueMemberS).Value
+
+	return userID, accessTokenFromItem(output.Item), nil
+}
+
+// DeleteAccessToken revokes one of a user's personal access tokens. It returns false if the user has no token with that ID.
+func (d *DynamoDBService) DeleteAccessToken(userID string, tokenID string) (bool, error) {
+	output, err := d.Client.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:              aws.String("PersonalAccessTokens"),
+		IndexName:              aws.String("UserIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		FilterExpression:       aws.String("ID = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+			":id":     &types.AttributeValueMemberS{Value: tokenID},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to query table, %v", err)
+	}
+
+	if len(output.Items) == 0 {
+		return false, nil
+	}
+
+	_, err = d.Client.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+		TableName: aws.String("PersonalAccessTokens"),
+		Key: map[string]types.AttributeValue{
+			"TokenHash": output.Items[0]["TokenHash"],
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete item, %v", err)
+	}
+
+	return true, nil
+}
+
+// accessTokenFromItem converts a PersonalAccessTokens record to an AccessToken.
+func accessTokenFromItem(item map[string]types.AttributeValue) models.AccessToken {
+	token := models.AccessToken{
+		ID:        item["ID"].(*types.AttributeValueMemberS).Value,
+		Name:      item["Name"].(*types.AttributeValueMemberS).Value,
+		Scopes:    item["Scopes"].(*types.AttributeValueMemberSS).Value,
+		CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
+	}
+	if expiresAt, ok := item["ExpiresAt"].(*types.AttributeValueMemberS); ok {
+		token.ExpiresAt = expiresAt.Value
+	}
+
+	return token
+}
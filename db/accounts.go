@@ -0,0 +1,310 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ScheduleUserDeletion marks a user's account for deletion at the given time, in RFC 3339 format.
+// The Users record is created if the user never saved a profile, so their other data is still found and deleted.
+func (d *DynamoDBService) ScheduleUserDeletion(userID string, scheduledFor string) error {
+	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":scheduledFor": &types.AttributeValueMemberS{Value: scheduledFor},
+		},
+		UpdateExpression: aws.String("SET DeletionScheduledFor = :scheduledFor"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule user deletion, %v", err)
+	}
+
+	return nil
+}
+
+// CancelUserDeletion removes a user's pending deletion. It returns false if no deletion was pending.
+func (d *DynamoDBService) CancelUserDeletion(userID string) (bool, error) {
+	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ConditionExpression: aws.String("attribute_exists(DeletionScheduledFor)"),
+		UpdateExpression:    aws.String("REMOVE DeletionScheduledFor"),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to cancel user deletion, %v", err)
+	}
+
+	return true, nil
+}
+
+// GetUsersDueForDeletion retrieves the IDs of users whose deletion was scheduled for the given time or earlier.
+// Deletions are rare, so this scans the Users table rather than keeping an index for them.
+func (d *DynamoDBService) GetUsersDueForDeletion(now string) ([]string, error) {
+	paginator := dynamodb.NewScanPaginator(d.Client, &dynamodb.ScanInput{
+		TableName:            aws.String("Users"),
+		FilterExpression:     aws.String("DeletionScheduledFor <= :now"),
+		ProjectionExpression: aws.String("ID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberS{Value: now},
+		},
+	})
+
+	userIDs := []string{}
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan table, %v", err)
+		}
+
+		for _, item := range output.Items {
+			userIDs = append(userIDs, item["ID"].(*types.AttributeValueMemberS).Value)
+		}
+	}
+
+	return userIDs, nil
+}
+
+// DeleteUserMemberships removes a user from every checklist shared with them.
+func (d *DynamoDBService) DeleteUserMemberships(userID string) error {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("ChecklistCollaborators"),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.deleteRecords("ChecklistCollaborators", records, "PK", "SK")
+}
+
+// DeleteOwnedChecklists deletes every checklist a user owns, with their items, collaborators, public links, ingest
+// URLs and invitations.
+// Locks are ignored, since they protect a checklist's content from its collaborators, not from its owner's erasure.
+// The checklists themselves are deleted last, so a failed run leaves them in place to be found by the next one.
+func (d *DynamoDBService) DeleteOwnedChecklists(userID string) error {
+	collaborators, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("ChecklistCollaborators"),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = d.deleteRecords("ChecklistCollaborators", collaborators, "PK", "SK")
+	if err != nil {
+		return fmt.Errorf("failed to delete checklist collaborators, %v", err)
+	}
+
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Checklists"),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var publicLinks []map[string]types.AttributeValue
+	for _, record := range records {
+		if checklistID, ok := strings.CutPrefix(record["SK"].(*types.AttributeValueMemberS).Value, "CHECKLIST#"); ok && !strings.Contains(checklistID, "ITEM#") {
+			if err := d.DeleteChecklistInvitations(checklistID); err != nil {
+				return fmt.Errorf("failed to delete invitations, %v", err)
+			}
+		}
+		if token, ok := record["PublicToken"].(*types.AttributeValueMemberS); ok {
+			publicLinks = append(publicLinks, map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token.Value},
+				"SK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token.Value},
+			})
+		}
+		if hash, ok := record["IngestHash"].(*types.AttributeValueMemberS); ok {
+			publicLinks = append(publicLinks, map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "INGEST#" + hash.Value},
+				"SK": &types.AttributeValueMemberS{Value: "INGEST#" + hash.Value},
+			})
+		}
+	}
+
+	err = d.deleteRecords("Checklists", publicLinks, "PK", "SK")
+	if err != nil {
+		return fmt.Errorf("failed to delete public links and ingest URLs, %v", err)
+	}
+
+	err = d.deleteRecords("Checklists", records, "PK", "SK")
+	if err != nil {
+		return fmt.Errorf("failed to delete checklists, %v", err)
+	}
+
+	return nil
+}
+
+// DeleteUserAccessTokens revokes all of a user's personal access tokens.
+func (d *DynamoDBService) DeleteUserAccessTokens(userID string) error {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("PersonalAccessTokens"),
+		IndexName:              aws.String("UserIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.deleteRecords("PersonalAccessTokens", records, "TokenHash")
+}
+
+// DeleteUser deletes a user's record from the Users table.
+func (d *DynamoDBService) DeleteUser(userID string) error {
+	_, err := d.Client.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete user, %v", err)
+	}
+
+	return nil
+}
+
+// queryAll runs a query and returns the records from every page of its results.
+func (d *DynamoDBService) queryAll(input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	paginator := dynamodb.NewQueryPaginator(d.Client, input)
+
+	var records []map[string]types.AttributeValue
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to query table, %v", err)
+		}
+		records = append(records, output.Items...)
+	}
+
+	return records, nil
+}
+
+// deleteRecords deletes the records from a table, using the named attributes of each record as its key.
+func (d *DynamoDBService) deleteRecords(tableName string, records []map[string]types.AttributeValue, keyNames ...string) error {
+	deleteRequests := make([]types.WriteRequest, 0, len(records))
+	for _, record := range records {
+		key := make(map[string]types.AttributeValue, len(keyNames))
+		for _, name := range keyNames {
+			key[name] = record[name]
+		}
+
+		deleteRequests = append(deleteRequests, types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{Key: key},
+		})
+	}
+
+	return d.batchWriteItems(tableName, deleteRequests)
+}
+
+// CountUserRecords counts the records a user owns in the Checklists table, which is their checklists and items.
+func (d *DynamoDBService) CountUserRecords(userID string) (int, error) {
+	paginator := dynamodb.NewQueryPaginator(d.Client, &dynamodb.QueryInput{
+		TableName:              aws.String("Checklists"),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		Select:                 types.SelectCount,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+		},
+	})
+
+	count := 0
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return 0, fmt.Errorf("failed to query table, %v", err)
+		}
+		count += int(output.Count)
+	}
+
+	return count, nil
+}
+
+// GetUserPreferences retrieves a user's preferences, or the defaults for any they haven't set.
+func (d *DynamoDBService) GetUserPreferences(userID string) (models.Preferences, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: aws.String("Preferences"),
+	})
+	if err != nil {
+		return models.Preferences{}, fmt.Errorf("failed to get item, %v", err)
+	}
+
+	preferences := models.DefaultPreferences()
+	if stored, ok := output.Item["Preferences"]; ok {
+		if err := attributevalue.Unmarshal(stored, &preferences); err != nil {
+			return models.Preferences{}, fmt.Errorf("failed to unmarshal preferences, %v", err)
+		}
+	}
+	// preferences saved before notification types could be muted have none muted
+	if preferences.Notifications.Muted == nil {
+		preferences.Notifications.Muted = []models.NotificationType{}
+	}
+
+	return preferences, nil
+}
+
+// UpdateUserPreferences stores a user's preferences. It returns false if the user has no profile to store them on.
+func (d *DynamoDBService) UpdateUserPreferences(userID string, preferences models.Preferences) (bool, error) {
+	stored, err := attributevalue.Marshal(preferences)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal preferences, %v", err)
+	}
+
+	_, err = d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":preferences": stored,
+		},
+		ConditionExpression: aws.String("attribute_exists(ID)"),
+		UpdateExpression:    aws.String("SET Preferences = :preferences"),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to update preferences, %v", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,141 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// maxBatchWriteItems is the most requests DynamoDB accepts in a single BatchWriteItem call.
+const maxBatchWriteItems = 25
+
+// CopyChecklist copies a checklist and all of its items into another user's partition, with new IDs.
+// fromUserID is the owner of the original checklist. The copy starts out unlocked, and unchecked if resetChecked is set.
+func (d *DynamoDBService) CopyChecklist(fromUserID string, checklistID string, toUserID string, title string, resetChecked bool) (models.Checklist, error) {
+	original, err := d.GetChecklist(fromUserID, checklistID)
+	if err != nil {
+		return models.Checklist{}, fmt.Errorf("failed to get checklist, %v", err)
+	} else if original.ID == "" {
+		return models.Checklist{}, fmt.Errorf("checklist does not exist")
+	}
+
+	items, err := d.GetChecklistItems(fromUserID, checklistID)
+	if err != nil {
+		return models.Checklist{}, fmt.Errorf("failed to get checklist items, %v", err)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	checklist := models.Checklist{
+		ID:        uuid.New().String(),
+		Title:     title,
+		Locked:    false,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	writeRequests := []types.WriteRequest{}
+	for _, item := range items {
+		checked := item.Checked && !resetChecked
+		writeRequests = append(writeRequests, types.WriteRequest{
+			PutRequest: &types.PutRequest{
+				Item: map[string]types.AttributeValue{
+					"PK":        &types.AttributeValueMemberS{Value: "USER#" + toUserID},
+					"SK":        &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklist.ID + "ITEM#" + uuid.New().String()},
+					"Entity":    &types.AttributeValueMemberS{Value: "ITEM"},
+					"Content":   &types.AttributeValueMemberS{Value: item.Content},
+					"Checked":   &types.AttributeValueMemberBOOL{Value: checked},
+					"Ordering":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", item.Ordering)},
+					"CreatedAt": &types.AttributeValueMemberS{Value: now},
+					"UpdatedAt": &types.AttributeValueMemberS{Value: now},
+				},
+			},
+		})
+	}
+
+	// write the items first, so a failure never leaves a visible checklist that is missing items
+	err = d.batchWriteItems("Checklists", writeRequests)
+	if err != nil {
+		return models.Checklist{}, fmt.Errorf("failed to copy checklist items, %v", err)
+	}
+
+	err = d.CreateChecklist(toUserID, &checklist)
+	if err != nil {
+		return models.Checklist{}, fmt.Errorf("failed to create checklist, %v", err)
+	}
+
+	return checklist, nil
+}
+
+// batchWriteItems writes the requests to a table in batches of 25, retrying any unprocessed requests.
+func (d *DynamoDBService) batchWriteItems(tableName string, writeRequests []types.WriteRequest) error {
+	for start := 0; start < len(writeRequests); start += maxBatchWriteItems {
+		end := min(start+maxBatchWriteItems, len(writeRequests))
+		requestItems := map[string][]types.WriteRequest{
+			tableName: writeRequests[start:end],
+		}
+
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt == 5 {
+				return fmt.Errorf("failed to write %d items after %d attempts", len(requestItems[tableName]), attempt)
+			} else if attempt > 0 {
+				time.Sleep(time.Duration(attempt*50) * time.Millisecond)
+			}
+
+			output, err := d.Client.BatchWriteItem(context.TODO(), &dynamodb.BatchWriteItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to batch write items, %v", err)
+			}
+
+			requestItems = output.UnprocessedItems
+		}
+	}
+
+	return nil
+}
+
+// CreateChecklistWithItems creates a checklist along with its items, in as few batched writes as possible.
+// Checklists with up to 24 items are written in a single call.
+func (d *DynamoDBService) CreateChecklistWithItems(userID string, checklist *models.Checklist, items []models.ChecklistItem) error {
+	writeRequests := []types.WriteRequest{
+		{
+			PutRequest: &types.PutRequest{
+				Item: map[string]types.AttributeValue{
+					"PK":        &types.AttributeValueMemberS{Value: "USER#" + userID},
+					"SK":        &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklist.ID},
+					"Entity":    &types.AttributeValueMemberS{Value: "CHECKLIST"},
+					"Title":     &types.AttributeValueMemberS{Value: checklist.Title},
+					"Locked":    &types.AttributeValueMemberBOOL{Value: checklist.Locked},
+					"CreatedAt": &types.AttributeValueMemberS{Value: checklist.CreatedAt},
+					"UpdatedAt": &types.AttributeValueMemberS{Value: checklist.UpdatedAt},
+				},
+			},
+		},
+	}
+
+	for _, item := range items {
+		writeRequests = append(writeRequests, types.WriteRequest{
+			PutRequest: &types.PutRequest{
+				Item: map[string]types.AttributeValue{
+					"PK":        &types.AttributeValueMemberS{Value: "USER#" + userID},
+					"SK":        &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklist.ID + "ITEM#" + item.ID},
+					"Entity":    &types.AttributeValueMemberS{Value: "ITEM"},
+					"Content":   &types.AttributeValueMemberS{Value: item.Content},
+					"Checked":   &types.AttributeValueMemberBOOL{Value: item.Checked},
+					"Ordering":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", item.Ordering)},
+					"CreatedAt": &types.AttributeValueMemberS{Value: item.CreatedAt},
+					"UpdatedAt": &types.AttributeValueMemberS{Value: item.UpdatedAt},
+				},
+			},
+		})
+	}
+
+	return d.batchWriteItems("Checklists", writeRequests)
+}
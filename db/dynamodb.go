@@ -16,7 +16,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/google/uuid"
 )
 
 // DynamoDBService is a struct that holds the DynamoDB client.
@@ -68,6 +67,85 @@ func (d *DynamoDBService) EnsureTableExists(tableName string, createTableFunc fu
 	return nil
 }
 
+// EnableTimeToLive has DynamoDB delete a table's records once the time in the named attribute, in Unix seconds, has
+// passed. It waits for a table that is still being created first.
+func (d *DynamoDBService) EnableTimeToLive(tableName string, attributeName string) error {
+	waiter := dynamodb.NewTableExistsWaiter(d.Client)
+	err := waiter.Wait(context.TODO(), &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	}, 5*time.Minute)
+	if err != nil {
+		return fmt.Errorf("failed to wait for table, %v", err)
+	}
+
+	output, err := d.Client.DescribeTimeToLive(context.TODO(), &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe time to live, %v", err)
+	}
+
+	description := output.TimeToLiveDescription
+	if description != nil && (description.TimeToLiveStatus == types.TimeToLiveStatusEnabled || description.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		if aws.ToString(description.AttributeName) != attributeName {
+			return fmt.Errorf("table %s already expires records by %s, not %s", tableName, aws.ToString(description.AttributeName), attributeName)
+		}
+		return nil
+	}
+
+	_, err = d.Client.UpdateTimeToLive(context.TODO(), &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attributeName),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update time to live, %v", err)
+	}
+
+	return nil
+}
+
+// EnsureIndexExists adds a global secondary index to an existing table, unless it already has one by that name.
+// attributes defines the index's key attributes. DynamoDB builds the index in the background.
+func (d *DynamoDBService) EnsureIndexExists(tableName string, index types.CreateGlobalSecondaryIndexAction, attributes []types.AttributeDefinition) error {
+	waiter := dynamodb.NewTableExistsWaiter(d.Client)
+	err := waiter.Wait(context.TODO(), &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	}, 5*time.Minute)
+	if err != nil {
+		return fmt.Errorf("failed to wait for table, %v", err)
+	}
+
+	output, err := d.Client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe table, %v", err)
+	}
+
+	for _, existing := range output.Table.GlobalSecondaryIndexes {
+		if aws.ToString(existing.IndexName) == aws.ToString(index.IndexName) {
+			return nil
+		}
+	}
+
+	_, err = d.Client.UpdateTable(context.TODO(), &dynamodb.UpdateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: attributes,
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{Create: &index},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update table, %v", err)
+	}
+
+	fmt.Printf("Index %s added to table %s\n", aws.ToString(index.IndexName), tableName)
+	return nil
+}
+
 // GetChecklists retrieves all checklists for a user.
 func (d *DynamoDBService) GetChecklists(userID string) ([]models.Checklist, error) {
 	output, err := d.Client.Query(context.TODO(), &dynamodb.QueryInput{
@@ -149,17 +227,30 @@ func (d *DynamoDBService) GetChecklist(userID string, checklistID string) (model
 		return models.Checklist{}, fmt.Errorf("failed to get checklist collaborators, %v", err)
 	}
 
-	item := output.Items[0]
+	checklist := ChecklistFromRecord(output.Items[0])
+	checklist.Collaborators = collaborators
+
+	return checklist, nil
+}
+
+// ChecklistFromRecord converts a checklist record from the Checklists table to a Checklist, without its collaborators.
+func ChecklistFromRecord(item map[string]types.AttributeValue) models.Checklist {
 	checklist := models.Checklist{
-		ID:            strings.Split(item["SK"].(*types.AttributeValueMemberS).Value, "#")[1],
-		Title:         item["Title"].(*types.AttributeValueMemberS).Value,
-		Locked:        item["Locked"].(*types.AttributeValueMemberBOOL).Value,
-		Collaborators: collaborators,
-		CreatedAt:     item["CreatedAt"].(*types.AttributeValueMemberS).Value,
-		UpdatedAt:     item["UpdatedAt"].(*types.AttributeValueMemberS).Value,
+		ID:        strings.Split(item["SK"].(*types.AttributeValueMemberS).Value, "#")[1],
+		Title:     item["Title"].(*types.AttributeValueMemberS).Value,
+		Locked:    item["Locked"].(*types.AttributeValueMemberBOOL).Value,
+		CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
+		UpdatedAt: item["UpdatedAt"].(*types.AttributeValueMemberS).Value,
 	}
 
-	return checklist, nil
+	if lockMode, ok := item["LockMode"].(*types.AttributeValueMemberS); ok {
+		checklist.LockMode = models.LockMode(lockMode.Value)
+	}
+	if checklist.Locked && checklist.LockMode == "" {
+		checklist.LockMode = models.LockModeChecks
+	}
+
+	return checklist
 }
 
 // GetChecklistItems retrieves the items for a checklist.
@@ -182,18 +273,9 @@ func (d *DynamoDBService) GetChecklistItems(userID string, checklistID string) (
 	checklistItems := []models.ChecklistItem{}
 
 	for _, item := range output.Items {
-		orderingVal, err := strconv.Atoi(item["Ordering"].(*types.AttributeValueMemberN).Value)
+		checklistItem, err := ChecklistItemFromRecord(item)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse order for item")
-		}
-
-		checklistItem := models.ChecklistItem{
-			ID:        strings.Split(item["SK"].(*types.AttributeValueMemberS).Value, "ITEM#")[1],
-			Content:   item["Content"].(*types.AttributeValueMemberS).Value,
-			Checked:   item["Checked"].(*types.AttributeValueMemberBOOL).Value,
-			Ordering:  orderingVal,
-			CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
-			UpdatedAt: item["UpdatedAt"].(*types.AttributeValueMemberS).Value,
+			return nil, err
 		}
 
 		checklistItems = append(checklistItems, checklistItem)
@@ -202,6 +284,32 @@ func (d *DynamoDBService) GetChecklistItems(userID string, checklistID string) (
 	return checklistItems, nil
 }
 
+// ChecklistItemFromRecord converts an item record from the Checklists table to a ChecklistItem.
+// Items written before content had versions are at version 0.
+func ChecklistItemFromRecord(item map[string]types.AttributeValue) (models.ChecklistItem, error) {
+	orderingVal, err := strconv.Atoi(item["Ordering"].(*types.AttributeValueMemberN).Value)
+	if err != nil {
+		return models.ChecklistItem{}, fmt.Errorf("failed to parse order for item")
+	}
+
+	checklistItem := models.ChecklistItem{
+		ID:        strings.Split(item["SK"].(*types.AttributeValueMemberS).Value, "ITEM#")[1],
+		Content:   item["Content"].(*types.AttributeValueMemberS).Value,
+		Checked:   item["Checked"].(*types.AttributeValueMemberBOOL).Value,
+		Ordering:  orderingVal,
+		CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
+		UpdatedAt: item["UpdatedAt"].(*types.AttributeValueMemberS).Value,
+	}
+	if version, ok := item["Version"].(*types.AttributeValueMemberN); ok {
+		checklistItem.Version, err = strconv.Atoi(version.Value)
+		if err != nil {
+			return models.ChecklistItem{}, fmt.Errorf("failed to parse version for item")
+		}
+	}
+
+	return checklistItem, nil
+}
+
 // CreateChecklist creates a new checklist in the database.
 func (d *DynamoDBService) CreateChecklist(userID string, checklist *models.Checklist) error {
 	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
@@ -225,6 +333,7 @@ func (d *DynamoDBService) CreateChecklist(userID string, checklist *models.Check
 }
 
 // UpdateChecklist updates a checklist in the database.
+// The title of a locked checklist can't change, but the lock itself can.
 func (d *DynamoDBService) UpdateChecklist(userID string, checklistID string, checklist *models.Checklist) error {
 	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
 		TableName: aws.String("Checklists"),
@@ -235,13 +344,22 @@ func (d *DynamoDBService) UpdateChecklist(userID string, checklistID string, che
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":title":     &types.AttributeValueMemberS{Value: checklist.Title},
 			":locked":    &types.AttributeValueMemberBOOL{Value: checklist.Locked},
+			":lockMode":  &types.AttributeValueMemberS{Value: string(checklist.LockMode)},
 			":updatedAt": &types.AttributeValueMemberS{Value: checklist.UpdatedAt},
+			":false":     &types.AttributeValueMemberBOOL{Value: false},
 		},
-		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
-		UpdateExpression:    aws.String("SET Title = :title, Locked = :locked, UpdatedAt = :updatedAt"),
+		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK) AND (attribute_not_exists(Locked) OR Locked = :false OR Title = :title)"),
+		UpdateExpression:    aws.String("SET Title = :title, Locked = :locked, LockMode = :lockMode, UpdatedAt = :updatedAt"),
 	})
 
-	if err != nil {
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		existing, getErr := d.GetChecklist(userID, checklistID)
+		if getErr == nil && existing.Locked {
+			return ErrChecklistLocked
+		}
+		return fmt.Errorf("failed to update item, %v", err)
+	} else if err != nil {
 		return fmt.Errorf("failed to update item, %v", err)
 	}
 	return nil
@@ -253,7 +371,7 @@ func (d *DynamoDBService) DeleteChecklist(userID string, checklistID string) err
 	if err != nil {
 		return fmt.Errorf("failed to get checklist, %v", err)
 	} else if checklist.Locked {
-		return fmt.Errorf("checklist is locked")
+		return ErrChecklistLocked
 	}
 
 	items, err := d.GetChecklistItems(userID, checklistID)
@@ -286,6 +404,21 @@ func (d *DynamoDBService) DeleteChecklist(userID string, checklistID string) err
 		}
 	}
 
+	err = d.DisablePublicLink(userID, checklistID)
+	if err != nil {
+		return fmt.Errorf("failed to disable public link, %v", err)
+	}
+
+	err = d.DisableIngestURL(userID, checklistID)
+	if err != nil {
+		return fmt.Errorf("failed to disable ingest URL, %v", err)
+	}
+
+	err = d.DeleteChecklistInvitations(checklistID)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitations, %v", err)
+	}
+
 	_, err = d.Client.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
 		TableName: aws.String("Checklists"),
 		Key: map[string]types.AttributeValue{
@@ -350,8 +483,8 @@ func (d *DynamoDBService) deleteChecklistCollaborators(userID string, checklistI
 	return nil
 }
 
-// AddCollaborator adds a collaborator to a checklist.
-func (d *DynamoDBService) AddCollaborator(userID string, checklistID string, collaboratorID string) error {
+// AddCollaborator adds a collaborator to a checklist with the given role.
+func (d *DynamoDBService) AddCollaborator(userID string, checklistID string, collaboratorID string, role models.Role) error {
 	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
 		TableName: aws.String("ChecklistCollaborators"),
 		Item: map[string]types.AttributeValue{
@@ -361,6 +494,7 @@ func (d *DynamoDBService) AddCollaborator(userID string, checklistID string, col
 			"GSI1PK":         &types.AttributeValueMemberS{Value: "USER#" + userID},
 			"GSI1SK":         &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
 			"CollaboratorID": &types.AttributeValueMemberS{Value: collaboratorID},
+			"Role":           &types.AttributeValueMemberS{Value: string(role)},
 		},
 	})
 	if err != nil {
@@ -430,7 +564,7 @@ func (d *DynamoDBService) GetChecklistCollaborators(userID string, checklistID s
 	return collaborators, nil
 }
 
-// GetChecklistOwner retrieves the owner of a checklist.
+// GetChecklistOwner retrieves the owner of a checklist shared with a user. It returns an empty string if the checklist isn't shared with them.
 func (d *DynamoDBService) GetChecklistOwner(userID string, checklistID string) (string, error) {
 	output, err := d.Client.Query(context.TODO(), &dynamodb.QueryInput{
 		TableName:              aws.String("ChecklistCollaborators"),
@@ -444,50 +578,285 @@ func (d *DynamoDBService) GetChecklistOwner(userID string, checklistID string) (
 		return "", fmt.Errorf("failed to query table, %v", err)
 	}
 
+	if len(output.Items) == 0 {
+		return "", nil
+	}
+
 	return output.Items[0]["OwnerID"].(*types.AttributeValueMemberS).Value, nil
 }
 
-// CreateChecklistItem creates a new item in a checklist.
-func (d *DynamoDBService) CreateChecklistItem(userID string, checklistID string, item *models.ChecklistItem) error {
-	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+// GetChecklistAccess resolves a user's access to a checklist, whether they own it or it is shared with them.
+// It returns the owner of the checklist and the user's role on it, both empty if the user has no access.
+func (d *DynamoDBService) GetChecklistAccess(userID string, checklistID string) (string, models.Role, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
 		TableName: aws.String("Checklists"),
-		Item: map[string]types.AttributeValue{
-			"PK":        &types.AttributeValueMemberS{Value: "USER#" + userID},
-			"SK":        &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + item.ID},
-			"Entity":    &types.AttributeValueMemberS{Value: "ITEM"},
-			"Content":   &types.AttributeValueMemberS{Value: item.Content},
-			"Checked":   &types.AttributeValueMemberBOOL{Value: item.Checked},
-			"Ordering":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", item.Ordering)},
-			"CreatedAt": &types.AttributeValueMemberS{Value: item.CreatedAt},
-			"UpdatedAt": &types.AttributeValueMemberS{Value: item.UpdatedAt},
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
 		},
+		ProjectionExpression: aws.String("PK"),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to put item, %v", err)
+		return "", "", fmt.Errorf("failed to get item, %v", err)
 	}
 
-	return nil
+	if output.Item != nil {
+		return userID, models.RoleOwner, nil
+	}
+
+	return d.GetCollaboratorRole(userID, checklistID)
 }
 
-// UpdateChecklistItem updates an item in a checklist.
-func (d *DynamoDBService) UpdateChecklistItem(userID string, checklistID string, itemID string, item *models.ChecklistItem) error {
-	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+// GetCollaboratorRole retrieves the owner of a checklist shared with a user, and the user's role on it.
+// Both are empty if the checklist isn't shared with the user.
+func (d *DynamoDBService) GetCollaboratorRole(userID string, checklistID string) (string, models.Role, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("ChecklistCollaborators"),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get item, %v", err)
+	}
+
+	if output.Item == nil {
+		return "", "", nil
+	}
+
+	ownerID := output.Item["OwnerID"].(*types.AttributeValueMemberS).Value
+	role := models.RoleEditor
+	if storedRole, ok := output.Item["Role"].(*types.AttributeValueMemberS); ok {
+		role = models.Role(storedRole.Value)
+	}
+
+	return ownerID, role, nil
+}
+
+// GetPublicToken retrieves the public link token for a checklist. It returns an empty string if the link is disabled.
+func (d *DynamoDBService) GetPublicToken(userID string, checklistID string) (string, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
 		TableName: aws.String("Checklists"),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
-			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + itemID},
+			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
 		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":content":   &types.AttributeValueMemberS{Value: item.Content},
-			":checked":   &types.AttributeValueMemberBOOL{Value: item.Checked},
-			":ordering":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", item.Ordering)},
-			":updatedAt": &types.AttributeValueMemberS{Value: item.UpdatedAt},
+		ProjectionExpression: aws.String("PublicToken"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get item, %v", err)
+	}
+
+	token, ok := output.Item["PublicToken"].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", nil
+	}
+
+	return token.Value, nil
+}
+
+// EnablePublicLink sets the public link token for a checklist, revoking any previous token.
+// The token is stored on the checklist, and in a PUBLIC# record that maps it back to the owner and checklist.
+func (d *DynamoDBService) EnablePublicLink(userID string, checklistID string, token string) error {
+	previousToken, err := d.GetPublicToken(userID, checklistID)
+	if err != nil {
+		return fmt.Errorf("failed to get public token, %v", err)
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String("Checklists"),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+					"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":token": &types.AttributeValueMemberS{Value: token},
+				},
+				ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+				UpdateExpression:    aws.String("SET PublicToken = :token"),
+			},
+		},
+		{
+			Put: &types.Put{
+				TableName: aws.String("Checklists"),
+				Item: map[string]types.AttributeValue{
+					"PK":          &types.AttributeValueMemberS{Value: "PUBLIC#" + token},
+					"SK":          &types.AttributeValueMemberS{Value: "PUBLIC#" + token},
+					"Entity":      &types.AttributeValueMemberS{Value: "PUBLIC"},
+					"OwnerID":     &types.AttributeValueMemberS{Value: userID},
+					"ChecklistID": &types.AttributeValueMemberS{Value: checklistID},
+				},
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			},
 		},
-		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
-		UpdateExpression:    aws.String("SET Content = :content, Checked = :checked, Ordering = :ordering, UpdatedAt = :updatedAt"),
-		ReturnValues:        types.ReturnValueAllNew,
+	}
+
+	if previousToken != "" {
+		transactItems = append(transactItems, publicTokenDelete(previousToken))
+	}
+
+	_, err = d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
 	})
 	if err != nil {
+		return fmt.Errorf("failed to enable public link, %v", err)
+	}
+
+	return nil
+}
+
+// DisablePublicLink revokes the public link token for a checklist, if there is one.
+func (d *DynamoDBService) DisablePublicLink(userID string, checklistID string) error {
+	token, err := d.GetPublicToken(userID, checklistID)
+	if err != nil {
+		return fmt.Errorf("failed to get public token, %v", err)
+	} else if token == "" {
+		return nil
+	}
+
+	_, err = d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName: aws.String("Checklists"),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+						"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+					},
+					UpdateExpression: aws.String("REMOVE PublicToken"),
+				},
+			},
+			publicTokenDelete(token),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable public link, %v", err)
+	}
+
+	return nil
+}
+
+// GetPublicChecklistOwner resolves a public link token to the owner and ID of its checklist.
+// Both are empty if the token does not exist.
+func (d *DynamoDBService) GetPublicChecklistOwner(token string) (string, string, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Checklists"),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token},
+			"SK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token},
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get item, %v", err)
+	}
+
+	if output.Item == nil {
+		return "", "", nil
+	}
+
+	ownerID := output.Item["OwnerID"].(*types.AttributeValueMemberS).Value
+	checklistID := output.Item["ChecklistID"].(*types.AttributeValueMemberS).Value
+
+	return ownerID, checklistID, nil
+}
+
+// publicTokenDelete builds the transaction step that deletes the PUBLIC# record for a token.
+func publicTokenDelete(token string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String("Checklists"),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token},
+				"SK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token},
+			},
+		},
+	}
+}
+
+// CreateChecklistItem creates a new item in a checklist, unless the checklist is locked.
+func (d *DynamoDBService) CreateChecklistItem(userID string, checklistID string, item *models.ChecklistItem) error {
+	_, err := d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			checklistUnlockedCheck(userID, checklistID),
+			{
+				Put: &types.Put{
+					TableName: aws.String("Checklists"),
+					Item: map[string]types.AttributeValue{
+						"PK":        &types.AttributeValueMemberS{Value: "USER#" + userID},
+						"SK":        &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + item.ID},
+						"Entity":    &types.AttributeValueMemberS{Value: "ITEM"},
+						"Content":   &types.AttributeValueMemberS{Value: item.Content},
+						"Checked":   &types.AttributeValueMemberBOOL{Value: item.Checked},
+						"Ordering":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", item.Ordering)},
+						"CreatedAt": &types.AttributeValueMemberS{Value: item.CreatedAt},
+						"UpdatedAt": &types.AttributeValueMemberS{Value: item.UpdatedAt},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return d.lockAwareError(userID, checklistID, err, "failed to put item")
+	}
+
+	return nil
+}
+
+// UpdateChecklistItem updates whether an item in a checklist is checked, and its ordering. Its content is changed with
+// UpdateChecklistItemContent instead, so concurrent edits can be merged.
+// On a locked checklist in LockModeChecks only the checked state may change, and on a frozen checklist nothing may.
+func (d *DynamoDBService) UpdateChecklistItem(userID string, checklistID string, itemID string, item *models.ChecklistItem) error {
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+		"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + itemID},
+	}
+	values := map[string]types.AttributeValue{
+		":checked":   &types.AttributeValueMemberBOOL{Value: item.Checked},
+		":ordering":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", item.Ordering)},
+		":updatedAt": &types.AttributeValueMemberS{Value: item.UpdatedAt},
+	}
+
+	_, err := d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			checklistUnlockedCheck(userID, checklistID),
+			{
+				Update: &types.Update{
+					TableName:                 aws.String("Checklists"),
+					Key:                       key,
+					ExpressionAttributeValues: values,
+					ConditionExpression:       aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+					UpdateExpression:          aws.String("SET Checked = :checked, Ordering = :ordering, UpdatedAt = :updatedAt"),
+				},
+			},
+		},
+	})
+	if err == nil {
+		return nil
+	} else if !isConditionFailure(err, 0) {
+		return fmt.Errorf("failed to update item, %v", err)
+	}
+
+	// the checklist is locked, which still allows checking and unchecking as long as nothing else changes
+	_, err = d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			checklistChecksAllowedCheck(userID, checklistID),
+			{
+				Update: &types.Update{
+					TableName:                 aws.String("Checklists"),
+					Key:                       key,
+					ExpressionAttributeValues: values,
+					ConditionExpression:       aws.String("attribute_exists(PK) AND attribute_exists(SK) AND Ordering = :ordering"),
+					UpdateExpression:          aws.String("SET Checked = :checked, UpdatedAt = :updatedAt"),
+				},
+			},
+		},
+	})
+	if isConditionFailure(err, 0) || isConditionFailure(err, 1) {
+		return ErrChecklistLocked
+	} else if err != nil {
 		return fmt.Errorf("failed to update item, %v", err)
 	}
 
@@ -495,7 +864,8 @@ func (d *DynamoDBService) UpdateChecklistItem(userID string, checklistID string,
 }
 
 // UpdateChecklistItems updates all items in a checklist.
-// currently only supports 100 total items, and is only for checking/unchecking all items.
+// currently only supports 99 total items, and is only for checking/unchecking all items.
+// This is allowed on locked checklists, unless they are frozen.
 func (d *DynamoDBService) UpdateChecklistItems(userID string, checklistID string, checked bool) error {
 	items, err := d.GetChecklistItems(userID, checklistID)
 
@@ -507,7 +877,9 @@ func (d *DynamoDBService) UpdateChecklistItems(userID string, checklistID string
 		return nil
 	}
 
-	transactItems := []types.TransactWriteItem{}
+	transactItems := []types.TransactWriteItem{
+		checklistChecksAllowedCheck(userID, checklistID),
+	}
 
 	for _, item := range items {
 		item.UpdatedAt = time.Now().Format(time.RFC3339)
@@ -535,24 +907,30 @@ func (d *DynamoDBService) UpdateChecklistItems(userID string, checklistID string
 	_, err = d.Client.TransactWriteItems(context.TODO(), input)
 
 	if err != nil {
-		return fmt.Errorf("failed to update items, %v", err)
+		return d.lockAwareError(userID, checklistID, err, "failed to update items")
 	}
 
 	return nil
 }
 
-// DeleteChecklistItem deletes an item from a checklist.
+// DeleteChecklistItem deletes an item from a checklist, unless the checklist is locked.
 func (d *DynamoDBService) DeleteChecklistItem(userID string, checklistID string, itemID string) error {
-	_, err := d.Client.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
-		TableName: aws.String("Checklists"),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
-			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + itemID},
+	_, err := d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			checklistUnlockedCheck(userID, checklistID),
+			{
+				Delete: &types.Delete{
+					TableName: aws.String("Checklists"),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+						"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + itemID},
+					},
+				},
+			},
 		},
 	})
-
 	if err != nil {
-		return fmt.Errorf("failed to delete item, %v", err)
+		return d.lockAwareError(userID, checklistID, err, "failed to delete item")
 	}
 
 	return nil
@@ -583,33 +961,58 @@ func (d *DynamoDBService) GetUser(userID string) (models.User, error) {
 	return user, nil
 }
 
-// CreateUser creates a new user in the database.
-func (d *DynamoDBService) CreateUser(userID string, email string, picture string) error {
-	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
-		TableName: aws.String("Users"),
-		Item: map[string]types.AttributeValue{
-			"ID":      &types.AttributeValueMemberS{Value: userID},
-			"Email":   &types.AttributeValueMemberS{Value: email},
-			"Picture": &types.AttributeValueMemberS{Value: picture},
+// GetUserIDByEmail finds the ID of the user with the given email, which is matched without regard to case, since
+// emails are stored in lower case. It returns an empty string if there is none.
+func (d *DynamoDBService) GetUserIDByEmail(email string) (string, error) {
+	output, err := d.Client.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:              aws.String("Users"),
+		IndexName:              aws.String("EmailIndex"),
+		KeyConditionExpression: aws.String("Email = :email"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":email": &types.AttributeValueMemberS{Value: strings.ToLower(email)},
 		},
+		Limit: aws.Int32(1),
 	})
-
 	if err != nil {
-		return fmt.Errorf("failed to create user, %v", err)
+		return "", fmt.Errorf("failed to query table, %v", err)
+	}
+
+	if len(output.Items) == 0 {
+		return "", nil
+	}
+
+	return output.Items[0]["ID"].(*types.AttributeValueMemberS).Value, nil
+}
+
+// CreateUser creates a new user in the database. The email is stored in lower case, so the EmailIndex finds it
+// whatever case it is given in, and an empty one isn't stored, since the EmailIndex can't hold one.
+func (d *DynamoDBService) CreateUser(userID string, email string, picture string) error {
+	email = strings.ToLower(email)
+	item := map[string]types.AttributeValue{
+		"ID":      &types.AttributeValueMemberS{Value: userID},
+		"Picture": &types.AttributeValueMemberS{Value: picture},
+	}
+	if email != "" {
+		item["Email"] = &types.AttributeValueMemberS{Value: email}
 	}
 
-	err = d.createIntroductoryListoForUser(userID)
+	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName: aws.String("Users"),
+		Item:      item,
+	})
 
 	if err != nil {
-		return fmt.Errorf("failed to create introductory listo, %v", err)
+		return fmt.Errorf("failed to create user, %v", err)
 	}
 
 	return nil
 }
 
-// UpdateUser updates a user in the database.
+// UpdateUser updates a user in the database. The email is stored in lower case, and an empty one removes the stored
+// one, like in CreateUser.
 func (d *DynamoDBService) UpdateUser(userID string, email string, picture string) error {
-	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+	email = strings.ToLower(email)
+	input := &dynamodb.UpdateItemInput{
 		TableName: aws.String("Users"),
 		Key: map[string]types.AttributeValue{
 			"ID": &types.AttributeValueMemberS{Value: userID},
@@ -620,7 +1023,13 @@ func (d *DynamoDBService) UpdateUser(userID string, email string, picture string
 		},
 		ConditionExpression: aws.String("attribute_exists(ID)"),
 		UpdateExpression:    aws.String("SET Email = :email, Picture = :picture"),
-	})
+	}
+	if email == "" {
+		delete(input.ExpressionAttributeValues, ":email")
+		input.UpdateExpression = aws.String("SET Picture = :picture REMOVE Email")
+	}
+
+	_, err := d.Client.UpdateItem(context.TODO(), input)
 
 	if err != nil {
 		return fmt.Errorf("failed to update user, %v", err)
@@ -629,51 +1038,50 @@ func (d *DynamoDBService) UpdateUser(userID string, email string, picture string
 	return nil
 }
 
-// createIntroductoryListoForUser creates a new listo for a user with introductory content.
-func (d *DynamoDBService) createIntroductoryListoForUser(userID string) error {
-	// create new checklist for user
-	checklist := models.Checklist{
-		ID:        uuid.New().String(),
-		Title:     "My First Listo",
-		Locked:    false,
-		CreatedAt: time.Now().Format(time.RFC3339),
-		UpdatedAt: time.Now().Format(time.RFC3339),
-	}
-	// save the checklist in the db
-	err := d.CreateChecklist(userID, &checklist)
-
-	if err != nil {
-		return fmt.Errorf("failed to create checklist, %v", err)
-	}
-
-	firstListContent := []string{
-		"Edit the title of this Listo by clicking on the title. Your changes will be saved automatically.",
-		"Edit this item by clicking on it, making your changes, and clicking away, or <return>",
-		"Add a new item to your Listo next to the + icon",
-		"You can make a multi-line item by pressing <shift> + <return>",
-		"Mark this item as done, by clicking on the checkbox",
-		"Reorder this item by dragging it somewhere else, and dropping it",
-		"Delete your checked items by selecting \"Delete Checked\" from the options dropdown",
-		"Lock your Listo by selecting \"Lock\" from the options dropdown. You'll still be able to check/uncheck items, but can't change them. This is handy if you have checklists that you need to reuse.",
-		"Share your Listo with others by selecting \"Share\" from the options dropdown. You can share with anyone, even if they don't have an account yet.",
-		"Have fun!",
-	}
-
-	for i, content := range firstListContent {
-		item := models.ChecklistItem{
-			ID:        uuid.New().String(),
-			Content:   content,
-			Checked:   false,
-			Ordering:  i,
-			CreatedAt: time.Now().Format(time.RFC3339),
-			UpdatedAt: time.Now().Format(time.RFC3339),
-		}
-		err = d.CreateChecklistItem(userID, checklist.ID, &item)
+// LowercaseUserEmails lowercases the emails stored before CreateUser and UpdateUser lowercased them, and returns how
+// many it changed. It scans the Users table, and leaves emails that were changed in the meantime alone.
+func (d *DynamoDBService) LowercaseUserEmails() (int, error) {
+	paginator := dynamodb.NewScanPaginator(d.Client, &dynamodb.ScanInput{
+		TableName:            aws.String("Users"),
+		FilterExpression:     aws.String("attribute_exists(Email)"),
+		ProjectionExpression: aws.String("ID, Email"),
+	})
 
+	updated := 0
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
 		if err != nil {
-			return fmt.Errorf("failed to create item, %v", err)
+			return updated, fmt.Errorf("failed to scan table, %v", err)
+		}
+
+		for _, item := range output.Items {
+			email := item["Email"].(*types.AttributeValueMemberS).Value
+			if email == strings.ToLower(email) {
+				continue
+			}
+
+			_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+				TableName: aws.String("Users"),
+				Key: map[string]types.AttributeValue{
+					"ID": item["ID"],
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":email":     &types.AttributeValueMemberS{Value: email},
+					":lowercase": &types.AttributeValueMemberS{Value: strings.ToLower(email)},
+				},
+				ConditionExpression: aws.String("Email = :email"),
+				UpdateExpression:    aws.String("SET Email = :lowercase"),
+			})
+
+			var conditionFailed *types.ConditionalCheckFailedException
+			if errors.As(err, &conditionFailed) {
+				continue
+			} else if err != nil {
+				return updated, fmt.Errorf("failed to update user, %v", err)
+			}
+			updated++
 		}
 	}
 
-	return nil
+	return updated, nil
 }
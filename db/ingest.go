@@ -0,0 +1,160 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GetIngestURL retrieves the hash of a checklist's ingest secret, and when it was created. Both are empty if the
+// checklist has no ingest URL.
+func (d *DynamoDBService) GetIngestURL(userID string, checklistID string) (string, string, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Checklists"),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+		},
+		ProjectionExpression: aws.String("IngestHash, IngestCreatedAt"),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get item, %v", err)
+	}
+
+	hash, ok := output.Item["IngestHash"].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", "", nil
+	}
+
+	createdAt := ""
+	if stored, ok := output.Item["IngestCreatedAt"].(*types.AttributeValueMemberS); ok {
+		createdAt = stored.Value
+	}
+
+	return hash.Value, createdAt, nil
+}
+
+// EnableIngestURL sets the hash of a checklist's ingest secret, revoking any previous secret.
+// The hash is stored on the checklist, and in an INGEST# record that maps it back to the owner and checklist.
+func (d *DynamoDBService) EnableIngestURL(userID string, checklistID string, hash string, createdAt string) error {
+	previousHash, _, err := d.GetIngestURL(userID, checklistID)
+	if err != nil {
+		return fmt.Errorf("failed to get ingest URL, %v", err)
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String("Checklists"),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+					"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":hash":      &types.AttributeValueMemberS{Value: hash},
+					":createdAt": &types.AttributeValueMemberS{Value: createdAt},
+				},
+				ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+				UpdateExpression:    aws.String("SET IngestHash = :hash, IngestCreatedAt = :createdAt"),
+			},
+		},
+		{
+			Put: &types.Put{
+				TableName: aws.String("Checklists"),
+				Item: map[string]types.AttributeValue{
+					"PK":          &types.AttributeValueMemberS{Value: "INGEST#" + hash},
+					"SK":          &types.AttributeValueMemberS{Value: "INGEST#" + hash},
+					"Entity":      &types.AttributeValueMemberS{Value: "INGEST"},
+					"OwnerID":     &types.AttributeValueMemberS{Value: userID},
+					"ChecklistID": &types.AttributeValueMemberS{Value: checklistID},
+				},
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			},
+		},
+	}
+
+	if previousHash != "" {
+		transactItems = append(transactItems, ingestHashDelete(previousHash))
+	}
+
+	_, err = d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable ingest URL, %v", err)
+	}
+
+	return nil
+}
+
+// DisableIngestURL revokes a checklist's ingest secret, if it has one.
+func (d *DynamoDBService) DisableIngestURL(userID string, checklistID string) error {
+	hash, _, err := d.GetIngestURL(userID, checklistID)
+	if err != nil {
+		return fmt.Errorf("failed to get ingest URL, %v", err)
+	} else if hash == "" {
+		return nil
+	}
+
+	_, err = d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName: aws.String("Checklists"),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+						"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+					},
+					UpdateExpression: aws.String("REMOVE IngestHash, IngestCreatedAt"),
+				},
+			},
+			ingestHashDelete(hash),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable ingest URL, %v", err)
+	}
+
+	return nil
+}
+
+// GetIngestChecklistOwner resolves the hash of an ingest secret to the owner and ID of its checklist.
+// Both are empty if the secret does not exist.
+func (d *DynamoDBService) GetIngestChecklistOwner(hash string) (string, string, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Checklists"),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "INGEST#" + hash},
+			"SK": &types.AttributeValueMemberS{Value: "INGEST#" + hash},
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get item, %v", err)
+	}
+
+	if output.Item == nil {
+		return "", "", nil
+	}
+
+	ownerID := output.Item["OwnerID"].(*types.AttributeValueMemberS).Value
+	checklistID := output.Item["ChecklistID"].(*types.AttributeValueMemberS).Value
+
+	return ownerID, checklistID, nil
+}
+
+// ingestHashDelete builds the transaction step that deletes the INGEST# record for a secret's hash.
+func ingestHashDelete(hash string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String("Checklists"),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "INGEST#" + hash},
+				"SK": &types.AttributeValueMemberS{Value: "INGEST#" + hash},
+			},
+		},
+	}
+}
@@ -0,0 +1,279 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrInvitationNotFound is returned when an invitation to update was revoked, accepted or declined in the meantime.
+var ErrInvitationNotFound = errors.New("invitation does not exist")
+
+// CreateInvitation stores a new invitation. DynamoDB deletes it once its ExpiresAt has passed.
+func (d *DynamoDBService) CreateInvitation(invitation models.Invitation) error {
+	item, err := invitationItem(invitation)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:           aws.String("Invitations"),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item, %v", err)
+	}
+
+	return nil
+}
+
+// UpdateInvitation replaces a stored invitation, like when it is resent. It returns ErrInvitationNotFound if the
+// invitation is gone.
+func (d *DynamoDBService) UpdateInvitation(invitation models.Invitation) error {
+	item, err := invitationItem(invitation)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:           aws.String("Invitations"),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(ID)"),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrInvitationNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to put item, %v", err)
+	}
+
+	return nil
+}
+
+// GetInvitation retrieves an invitation. It returns an empty invitation if there is none with that ID, or it has
+// expired.
+func (d *DynamoDBService) GetInvitation(invitationID string) (models.Invitation, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Invitations"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: invitationID},
+		},
+	})
+	if err != nil {
+		return models.Invitation{}, fmt.Errorf("failed to get item, %v", err)
+	}
+
+	if output.Item == nil || invitationExpired(output.Item, time.Now()) {
+		return models.Invitation{}, nil
+	}
+
+	return invitationFromItem(output.Item)
+}
+
+// GetInvitationByToken retrieves the invitation whose join link has the token with the given hash. It returns an
+// empty invitation if there is none, or it has expired.
+func (d *DynamoDBService) GetInvitationByToken(tokenHash string) (models.Invitation, error) {
+	output, err := d.Client.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:              aws.String("Invitations"),
+		IndexName:              aws.String("TokenIndex"),
+		KeyConditionExpression: aws.String("TokenHash = :tokenHash"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":tokenHash": &types.AttributeValueMemberS{Value: tokenHash},
+		},
+	})
+	if err != nil {
+		return models.Invitation{}, fmt.Errorf("failed to query table, %v", err)
+	}
+
+	if len(output.Items) == 0 || invitationExpired(output.Items[0], time.Now()) {
+		return models.Invitation{}, nil
+	}
+
+	return invitationFromItem(output.Items[0])
+}
+
+// GetChecklistInvitations retrieves the pending invitations to a checklist, oldest first.
+func (d *DynamoDBService) GetChecklistInvitations(checklistID string) ([]models.Invitation, error) {
+	return d.getInvitations(&dynamodb.QueryInput{
+		TableName:              aws.String("Invitations"),
+		IndexName:              aws.String("ChecklistIndex"),
+		KeyConditionExpression: aws.String("ChecklistID = :checklistID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":checklistID": &types.AttributeValueMemberS{Value: checklistID},
+		},
+	})
+}
+
+// GetUserInvitations retrieves the pending invitations delivered to a user in the app, oldest first.
+func (d *DynamoDBService) GetUserInvitations(userID string) ([]models.Invitation, error) {
+	return d.getInvitations(&dynamodb.QueryInput{
+		TableName:              aws.String("Invitations"),
+		IndexName:              aws.String("InviteeIndex"),
+		KeyConditionExpression: aws.String("InviteeID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+}
+
+// getInvitations runs a query on one of the Invitations table's indexes, which project every attribute, and leaves
+// out the invitations that have expired but DynamoDB hasn't deleted yet.
+func (d *DynamoDBService) getInvitations(input *dynamodb.QueryInput) ([]models.Invitation, error) {
+	records, err := d.queryAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invitations := []models.Invitation{}
+	for _, record := range records {
+		if invitationExpired(record, now) {
+			continue
+		}
+
+		invitation, err := invitationFromItem(record)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, nil
+}
+
+// DeleteInvitation deletes an invitation, once it is revoked, accepted or declined.
+func (d *DynamoDBService) DeleteInvitation(invitationID string) error {
+	_, err := d.Client.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+		TableName: aws.String("Invitations"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: invitationID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete item, %v", err)
+	}
+
+	return nil
+}
+
+// DeleteChecklistInvitations deletes every invitation to a checklist, including expired ones.
+func (d *DynamoDBService) DeleteChecklistInvitations(checklistID string) error {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Invitations"),
+		IndexName:              aws.String("ChecklistIndex"),
+		KeyConditionExpression: aws.String("ChecklistID = :checklistID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":checklistID": &types.AttributeValueMemberS{Value: checklistID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.deleteRecords("Invitations", records, "ID")
+}
+
+// DeleteUserInvitations deletes every invitation delivered to a user in the app, including expired ones.
+func (d *DynamoDBService) DeleteUserInvitations(userID string) error {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Invitations"),
+		IndexName:              aws.String("InviteeIndex"),
+		KeyConditionExpression: aws.String("InviteeID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.deleteRecords("Invitations", records, "ID")
+}
+
+// invitationItem converts an Invitation to a record for the Invitations table. InviteeID and TokenHash are left out
+// when empty, so the indexes on them only hold the invitations delivered that way.
+func invitationItem(invitation models.Invitation) (map[string]types.AttributeValue, error) {
+	expiresAt, err := time.Parse(time.RFC3339, invitation.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expiry of invitation, %v", err)
+	}
+
+	item := map[string]types.AttributeValue{
+		"ID":               &types.AttributeValueMemberS{Value: invitation.ID},
+		"ChecklistID":      &types.AttributeValueMemberS{Value: invitation.ChecklistID},
+		"ChecklistTitle":   &types.AttributeValueMemberS{Value: invitation.ChecklistTitle},
+		"OwnerID":          &types.AttributeValueMemberS{Value: invitation.OwnerID},
+		"Email":            &types.AttributeValueMemberS{Value: invitation.Email},
+		"Role":             &types.AttributeValueMemberS{Value: string(invitation.Role)},
+		"Delivery":         &types.AttributeValueMemberS{Value: string(invitation.Delivery)},
+		"InvitedByEmail":   &types.AttributeValueMemberS{Value: invitation.InvitedBy.Email},
+		"InvitedByPicture": &types.AttributeValueMemberS{Value: invitation.InvitedBy.Picture},
+		"CreatedAt":        &types.AttributeValueMemberS{Value: invitation.CreatedAt},
+		"SentAt":           &types.AttributeValueMemberS{Value: invitation.SentAt},
+		"ExpiresAt":        &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+	}
+	if invitation.InviteeID != "" {
+		item["InviteeID"] = &types.AttributeValueMemberS{Value: invitation.InviteeID}
+	}
+	if invitation.TokenHash != "" {
+		item["TokenHash"] = &types.AttributeValueMemberS{Value: invitation.TokenHash}
+	}
+
+	return item, nil
+}
+
+// invitationFromItem converts a record from the Invitations table to an Invitation.
+func invitationFromItem(item map[string]types.AttributeValue) (models.Invitation, error) {
+	expiresAt, err := strconv.ParseInt(item["ExpiresAt"].(*types.AttributeValueMemberN).Value, 10, 64)
+	if err != nil {
+		return models.Invitation{}, fmt.Errorf("failed to parse expiry of invitation")
+	}
+
+	invitation := models.Invitation{
+		ID:             item["ID"].(*types.AttributeValueMemberS).Value,
+		ChecklistID:    item["ChecklistID"].(*types.AttributeValueMemberS).Value,
+		ChecklistTitle: item["ChecklistTitle"].(*types.AttributeValueMemberS).Value,
+		OwnerID:        item["OwnerID"].(*types.AttributeValueMemberS).Value,
+		Email:          item["Email"].(*types.AttributeValueMemberS).Value,
+		Role:           models.Role(item["Role"].(*types.AttributeValueMemberS).Value),
+		Delivery:       models.InvitationDelivery(item["Delivery"].(*types.AttributeValueMemberS).Value),
+		InvitedBy: models.Collaborator{
+			Email:   item["InvitedByEmail"].(*types.AttributeValueMemberS).Value,
+			Picture: item["InvitedByPicture"].(*types.AttributeValueMemberS).Value,
+		},
+		CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
+		SentAt:    item["SentAt"].(*types.AttributeValueMemberS).Value,
+		ExpiresAt: time.Unix(expiresAt, 0).UTC().Format(time.RFC3339),
+	}
+
+	if inviteeID, ok := item["InviteeID"].(*types.AttributeValueMemberS); ok {
+		invitation.InviteeID = inviteeID.Value
+	}
+	if tokenHash, ok := item["TokenHash"].(*types.AttributeValueMemberS); ok {
+		invitation.TokenHash = tokenHash.Value
+	}
+
+	return invitation, nil
+}
+
+// invitationExpired reports whether an Invitations record has expired. DynamoDB can take a while to delete expired
+// records, so they are checked when read.
+func invitationExpired(item map[string]types.AttributeValue, now time.Time) bool {
+	expiresAt, ok := item["ExpiresAt"].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+
+	seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+	return err == nil && seconds <= now.Unix()
+}
@@ -0,0 +1,78 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrItemVersionConflict is returned when an item's content changed since the version an update was made against.
+var ErrItemVersionConflict = errors.New("item content has changed")
+
+// GetChecklistItem retrieves a single item from a checklist. The item's ID is empty if it doesn't exist.
+func (d *DynamoDBService) GetChecklistItem(userID string, checklistID string, itemID string) (models.ChecklistItem, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Checklists"),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + itemID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return models.ChecklistItem{}, fmt.Errorf("failed to get item, %v", err)
+	}
+
+	if output.Item == nil {
+		return models.ChecklistItem{}, nil
+	}
+
+	return ChecklistItemFromRecord(output.Item)
+}
+
+// UpdateChecklistItemContent replaces an item's content, as long as it is still at version, and moves it to the next
+// version. It returns ErrItemVersionConflict if another edit got there first, and ErrChecklistLocked if the
+// checklist is locked.
+func (d *DynamoDBService) UpdateChecklistItemContent(userID string, checklistID string, itemID string, content string, version int, updatedAt string) error {
+	condition := "attribute_exists(PK) AND attribute_exists(SK) AND Version = :version"
+	if version == 0 {
+		condition = "attribute_exists(PK) AND attribute_exists(SK) AND (attribute_not_exists(Version) OR Version = :version)"
+	}
+
+	_, err := d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			checklistUnlockedCheck(userID, checklistID),
+			{
+				Update: &types.Update{
+					TableName: aws.String("Checklists"),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+						"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID + "ITEM#" + itemID},
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":content":     &types.AttributeValueMemberS{Value: content},
+						":version":     &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+						":nextVersion": &types.AttributeValueMemberN{Value: strconv.Itoa(version + 1)},
+						":updatedAt":   &types.AttributeValueMemberS{Value: updatedAt},
+					},
+					ConditionExpression: aws.String(condition),
+					UpdateExpression:    aws.String("SET Content = :content, Version = :nextVersion, UpdatedAt = :updatedAt"),
+				},
+			},
+		},
+	})
+	if isConditionFailure(err, 1) {
+		return ErrItemVersionConflict
+	} else if err != nil {
+		return d.lockAwareError(userID, checklistID, err, "failed to update item content")
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrChecklistLocked is returned when a change isn't allowed because the checklist is locked.
+var ErrChecklistLocked = errors.New("checklist is locked")
+
+// checklistUnlockedCheck builds the transaction step that requires a checklist to exist and be unlocked.
+func checklistUnlockedCheck(userID string, checklistID string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName: aws.String("Checklists"),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+				"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":false": &types.AttributeValueMemberBOOL{Value: false},
+			},
+			ConditionExpression: aws.String("attribute_exists(PK) AND (attribute_not_exists(Locked) OR Locked = :false)"),
+		},
+	}
+}
+
+// checklistChecksAllowedCheck builds the transaction step that requires a checklist to exist, and allow its items
+// to be checked and unchecked. That is any checklist that is unlocked, or locked in LockModeChecks.
+// Checklists locked before lock modes existed have no LockMode, and behave like LockModeChecks.
+func checklistChecksAllowedCheck(userID string, checklistID string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName: aws.String("Checklists"),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+				"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":false":  &types.AttributeValueMemberBOOL{Value: false},
+				":checks": &types.AttributeValueMemberS{Value: string(models.LockModeChecks)},
+			},
+			ConditionExpression: aws.String("attribute_exists(PK) AND (attribute_not_exists(Locked) OR Locked = :false OR attribute_not_exists(LockMode) OR LockMode = :checks)"),
+		},
+	}
+}
+
+// isConditionFailure reports whether err is a cancelled transaction whose step at index failed its condition.
+func isConditionFailure(err error, index int) bool {
+	var cancelledErr *types.TransactionCanceledException
+	if !errors.As(err, &cancelledErr) || index >= len(cancelledErr.CancellationReasons) {
+		return false
+	}
+
+	return aws.ToString(cancelledErr.CancellationReasons[index].Code) == "ConditionalCheckFailed"
+}
+
+// lockAwareError turns an error from a transaction that starts with a checklist lock check into ErrChecklistLocked,
+// if that check is what failed and the checklist exists. Other errors are wrapped with the message.
+func (d *DynamoDBService) lockAwareError(userID string, checklistID string, err error, message string) error {
+	if !isConditionFailure(err, 0) {
+		return fmt.Errorf("%s, %v", message, err)
+	}
+
+	output, getErr := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Checklists"),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+		},
+		ProjectionExpression: aws.String("PK"),
+	})
+	if getErr != nil {
+		return fmt.Errorf("%s, %v", message, getErr)
+	} else if output.Item == nil {
+		return fmt.Errorf("%s, checklist does not exist", message)
+	}
+
+	return ErrChecklistLocked
+}
@@ -0,0 +1,222 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// mailQueue is the Queue of every pending email. The attribute is removed once an email is sent or fails, which
+// takes it out of the DueIndex.
+const mailQueue = "mail"
+
+// QueueEmail adds an email to the outbox, to be sent once dueAt has passed.
+func (d *DynamoDBService) QueueEmail(email models.Email, dueAt time.Time) error {
+	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName: aws.String("MailOutbox"),
+		Item: map[string]types.AttributeValue{
+			"ID":        &types.AttributeValueMemberS{Value: email.ID},
+			"To":        &types.AttributeValueMemberS{Value: email.To},
+			"Subject":   &types.AttributeValueMemberS{Value: email.Subject},
+			"Text":      &types.AttributeValueMemberS{Value: email.Text},
+			"HTML":      &types.AttributeValueMemberS{Value: email.HTML},
+			"Status":    &types.AttributeValueMemberS{Value: string(models.EmailPending)},
+			"Attempts":  &types.AttributeValueMemberN{Value: "0"},
+			"CreatedAt": &types.AttributeValueMemberS{Value: email.CreatedAt},
+			"Queue":     &types.AttributeValueMemberS{Value: mailQueue},
+			"DueAt":     &types.AttributeValueMemberN{Value: strconv.FormatInt(dueAt.UnixMilli(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item, %v", err)
+	}
+
+	return nil
+}
+
+// ClaimDueEmails finds up to limit pending emails that are due, and claims each until claimedUntil, so no other
+// instance sends it in the meantime. Claiming counts an attempt. Emails another instance claimed first are left out.
+func (d *DynamoDBService) ClaimDueEmails(now time.Time, claimedUntil time.Time, limit int) ([]models.Email, error) {
+	output, err := d.Client.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:              aws.String("MailOutbox"),
+		IndexName:              aws.String("DueIndex"),
+		KeyConditionExpression: aws.String("#queue = :queue AND DueAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#queue": "Queue",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":queue": &types.AttributeValueMemberS{Value: mailQueue},
+			":now":   &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+		},
+		Limit: aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table, %v", err)
+	}
+
+	claimed := []models.Email{}
+	for _, record := range output.Items {
+		// the claim only wins if the email is still due when it was seen, so one instance gets each attempt
+		updated, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+			TableName: aws.String("MailOutbox"),
+			Key: map[string]types.AttributeValue{
+				"ID": record["ID"],
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":seen":         record["DueAt"],
+				":claimedUntil": &types.AttributeValueMemberN{Value: strconv.FormatInt(claimedUntil.UnixMilli(), 10)},
+				":one":          &types.AttributeValueMemberN{Value: "1"},
+			},
+			ConditionExpression: aws.String("DueAt = :seen"),
+			UpdateExpression:    aws.String("SET DueAt = :claimedUntil, Attempts = Attempts + :one"),
+			ReturnValues:        types.ReturnValueAllNew,
+		})
+
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to update item, %v", err)
+		}
+
+		email, err := emailFromItem(updated.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, email)
+	}
+
+	return claimed, nil
+}
+
+// RetryEmail puts a claimed email back in the queue, to be tried again once dueAt has passed.
+func (d *DynamoDBService) RetryEmail(emailID string, lastError string, dueAt time.Time) error {
+	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("MailOutbox"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: emailID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":error": &types.AttributeValueMemberS{Value: lastError},
+			":dueAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(dueAt.UnixMilli(), 10)},
+		},
+		UpdateExpression: aws.String("SET LastError = :error, DueAt = :dueAt"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update item, %v", err)
+	}
+
+	return nil
+}
+
+// FinishEmail records that an email was sent or has failed for good, and takes it out of the queue. DynamoDB deletes
+// it once expiresAt has passed.
+func (d *DynamoDBService) FinishEmail(emailID string, status models.EmailStatus, lastError string, expiresAt time.Time) error {
+	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("MailOutbox"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: emailID},
+		},
+		ExpressionAttributeNames: map[string]string{
+			"#queue":  "Queue",
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":    &types.AttributeValueMemberS{Value: string(status)},
+			":error":     &types.AttributeValueMemberS{Value: lastError},
+			":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+		UpdateExpression: aws.String("SET #status = :status, LastError = :error, ExpiresAt = :expiresAt REMOVE #queue, DueAt"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update item, %v", err)
+	}
+
+	return nil
+}
+
+// GetUsersWithDigest retrieves the IDs of users who want the daily digest.
+// Few users turn it on, so this scans the Users table rather than keeping an index for them.
+func (d *DynamoDBService) GetUsersWithDigest() ([]string, error) {
+	paginator := dynamodb.NewScanPaginator(d.Client, &dynamodb.ScanInput{
+		TableName:            aws.String("Users"),
+		FilterExpression:     aws.String("Preferences.Notifications.Digest = :true"),
+		ProjectionExpression: aws.String("ID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	})
+
+	userIDs := []string{}
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan table, %v", err)
+		}
+
+		for _, item := range output.Items {
+			userIDs = append(userIDs, item["ID"].(*types.AttributeValueMemberS).Value)
+		}
+	}
+
+	return userIDs, nil
+}
+
+// ClaimDigest records that a user's digest for the given date, in YYYY-MM-DD format, is being sent. It returns false
+// if it already was, by this instance or another.
+func (d *DynamoDBService) ClaimDigest(userID string, date string) (bool, error) {
+	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":date": &types.AttributeValueMemberS{Value: date},
+		},
+		ConditionExpression: aws.String("attribute_exists(ID) AND (attribute_not_exists(LastDigestDate) OR LastDigestDate < :date)"),
+		UpdateExpression:    aws.String("SET LastDigestDate = :date"),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to claim digest, %v", err)
+	}
+
+	return true, nil
+}
+
+// emailFromItem converts a record from the MailOutbox table to an Email.
+func emailFromItem(item map[string]types.AttributeValue) (models.Email, error) {
+	attempts, err := strconv.Atoi(item["Attempts"].(*types.AttributeValueMemberN).Value)
+	if err != nil {
+		return models.Email{}, fmt.Errorf("failed to parse attempts for email")
+	}
+
+	email := models.Email{
+		ID:        item["ID"].(*types.AttributeValueMemberS).Value,
+		To:        item["To"].(*types.AttributeValueMemberS).Value,
+		Subject:   item["Subject"].(*types.AttributeValueMemberS).Value,
+		Text:      item["Text"].(*types.AttributeValueMemberS).Value,
+		HTML:      item["HTML"].(*types.AttributeValueMemberS).Value,
+		Status:    models.EmailStatus(item["Status"].(*types.AttributeValueMemberS).Value),
+		Attempts:  attempts,
+		CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
+	}
+
+	if lastError, ok := item["LastError"].(*types.AttributeValueMemberS); ok {
+		email.Error = lastError.Value
+	}
+
+	return email, nil
+}
@@ -16,6 +16,17 @@ var migrationList = []migration{
 	{1, "1_create_users_table", migrations.CreateUsersTable},
 	{2, "2_create_checklists_table", migrations.CreateChecklistsTable},
 	{3, "3_create_checklist_collaborators_table", migrations.CreateChecklistCollaboratorsTable},
+	{4, "4_create_personal_access_tokens_table", migrations.CreatePersonalAccessTokensTable},
+	{5, "5_enable_streams", migrations.EnableStreams},
+	{6, "6_create_webhooks_table", migrations.CreateWebhooksTable},
+	{7, "7_create_notifications_table", migrations.CreateNotificationsTable},
+	{8, "8_enable_notifications_ttl", migrations.EnableNotificationsTTL},
+	{9, "9_create_mail_outbox_table", migrations.CreateMailOutboxTable},
+	{10, "10_enable_mail_outbox_ttl", migrations.EnableMailOutboxTTL},
+	{11, "11_add_users_email_index", migrations.AddUsersEmailIndex},
+	{12, "12_create_invitations_table", migrations.CreateInvitationsTable},
+	{13, "13_enable_invitations_ttl", migrations.EnableInvitationsTTL},
+	{14, "14_lowercase_users_emails", migrations.LowercaseUsersEmails},
 	// Add new migrations here
 }
 
@@ -0,0 +1,35 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// AddUsersEmailIndex adds the EmailIndex to the Users table, which finds users by email when they are invited.
+func AddUsersEmailIndex() error {
+	service, _ := db.NewDynamoDBService()
+
+	err := service.EnsureIndexExists("Users", types.CreateGlobalSecondaryIndexAction{
+		IndexName: aws.String("EmailIndex"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("Email"), KeyType: types.KeyTypeHash},
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeKeysOnly,
+		},
+	}, []types.AttributeDefinition{
+		{AttributeName: aws.String("Email"), AttributeType: types.ScalarAttributeTypeS},
+	})
+
+	if err != nil {
+		fmt.Printf("Error adding index EmailIndex to table Users: %v\n", err)
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateInvitationsTable creates the Invitations table.
+func CreateInvitationsTable() error {
+	service, _ := db.NewDynamoDBService()
+	err := service.EnsureTableExists("Invitations", createInvitationsTableMigration)
+
+	if err != nil {
+		fmt.Printf("Error creating table Invitations: %v\n", err)
+	}
+	return err
+}
+
+func createInvitationsTableMigration(svc *dynamodb.Client) error {
+	index := func(name string, keySchema ...types.KeySchemaElement) types.GlobalSecondaryIndex {
+		return types.GlobalSecondaryIndex{
+			IndexName: aws.String(name),
+			KeySchema: keySchema,
+			ProvisionedThroughput: &types.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(5),
+				WriteCapacityUnits: aws.Int64(5),
+			},
+			Projection: &types.Projection{
+				ProjectionType: types.ProjectionTypeAll,
+			},
+		}
+	}
+
+	_, err := svc.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+		TableName: aws.String("Invitations"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("ChecklistID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("InviteeID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("TokenHash"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("CreatedAt"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			index("ChecklistIndex",
+				types.KeySchemaElement{AttributeName: aws.String("ChecklistID"), KeyType: types.KeyTypeHash},
+				types.KeySchemaElement{AttributeName: aws.String("CreatedAt"), KeyType: types.KeyTypeRange},
+			),
+			// only invitations delivered in the app have an InviteeID, and only ones delivered by email a TokenHash
+			index("InviteeIndex",
+				types.KeySchemaElement{AttributeName: aws.String("InviteeID"), KeyType: types.KeyTypeHash},
+				types.KeySchemaElement{AttributeName: aws.String("CreatedAt"), KeyType: types.KeyTypeRange},
+			),
+			index("TokenIndex",
+				types.KeySchemaElement{AttributeName: aws.String("TokenHash"), KeyType: types.KeyTypeHash},
+			),
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to create table, %v", err)
+	}
+
+	fmt.Println("Table Invitations created successfully with ChecklistIndex, InviteeIndex and TokenIndex")
+	return nil
+}
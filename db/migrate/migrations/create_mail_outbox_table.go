@@ -0,0 +1,64 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateMailOutboxTable creates the MailOutbox table.
+func CreateMailOutboxTable() error {
+	service, _ := db.NewDynamoDBService()
+	err := service.EnsureTableExists("MailOutbox", createMailOutboxTableMigration)
+
+	if err != nil {
+		fmt.Printf("Error creating table MailOutbox: %v\n", err)
+	}
+	return err
+}
+
+func createMailOutboxTableMigration(svc *dynamodb.Client) error {
+	_, err := svc.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+		TableName: aws.String("MailOutbox"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("Queue"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("DueAt"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				// only pending emails have a Queue, so the index holds just those, in the order they are due
+				IndexName: aws.String("DueIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("Queue"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("DueAt"), KeyType: types.KeyTypeRange},
+				},
+				ProvisionedThroughput: &types.ProvisionedThroughput{
+					ReadCapacityUnits:  aws.Int64(5),
+					WriteCapacityUnits: aws.Int64(5),
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to create table, %v", err)
+	}
+
+	fmt.Println("Table MailOutbox created successfully with DueIndex")
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateNotificationsTable creates the Notifications table.
+func CreateNotificationsTable() error {
+	service, _ := db.NewDynamoDBService()
+	err := service.EnsureTableExists("Notifications", createNotificationsTableMigration)
+
+	if err != nil {
+		fmt.Printf("Error creating table Notifications: %v\n", err)
+	}
+	return err
+}
+
+func createNotificationsTableMigration(svc *dynamodb.Client) error {
+	_, err := svc.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+		TableName: aws.String("Notifications"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("UserID"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("UserID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("UnreadID"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				// only unread notifications have an UnreadID, so the index holds just those
+				IndexName: aws.String("UnreadIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("UserID"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("UnreadID"), KeyType: types.KeyTypeRange},
+				},
+				ProvisionedThroughput: &types.ProvisionedThroughput{
+					ReadCapacityUnits:  aws.Int64(5),
+					WriteCapacityUnits: aws.Int64(5),
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeKeysOnly,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to create table, %v", err)
+	}
+
+	fmt.Println("Table Notifications created successfully with UnreadIndex")
+	return nil
+}
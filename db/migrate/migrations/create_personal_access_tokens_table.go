@@ -0,0 +1,63 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreatePersonalAccessTokensTable creates the PersonalAccessTokens table.
+func CreatePersonalAccessTokensTable() error {
+	service, _ := db.NewDynamoDBService()
+	err := service.EnsureTableExists("PersonalAccessTokens", createPersonalAccessTokensTableMigration)
+
+	if err != nil {
+		fmt.Printf("Error creating table PersonalAccessTokens: %v\n", err)
+	}
+	return err
+}
+
+func createPersonalAccessTokensTableMigration(svc *dynamodb.Client) error {
+	_, err := svc.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+		TableName: aws.String("PersonalAccessTokens"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("TokenHash"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("TokenHash"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("UserID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("CreatedAt"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("UserIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("UserID"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("CreatedAt"), KeyType: types.KeyTypeRange},
+				},
+				ProvisionedThroughput: &types.ProvisionedThroughput{
+					ReadCapacityUnits:  aws.Int64(5),
+					WriteCapacityUnits: aws.Int64(5),
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to create table, %v", err)
+	}
+
+	fmt.Println("Table PersonalAccessTokens created successfully with UserIndex")
+	return nil
+}
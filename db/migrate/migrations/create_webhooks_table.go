@@ -0,0 +1,78 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateWebhooksTable creates the Webhooks table.
+func CreateWebhooksTable() error {
+	service, _ := db.NewDynamoDBService()
+	err := service.EnsureTableExists("Webhooks", createWebhooksTableMigration)
+
+	if err != nil {
+		fmt.Printf("Error creating table Webhooks: %v\n", err)
+	}
+	return err
+}
+
+func createWebhooksTableMigration(svc *dynamodb.Client) error {
+	_, err := svc.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+		TableName: aws.String("Webhooks"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("UserID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("ChecklistID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("CreatedAt"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("UserIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("UserID"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("CreatedAt"), KeyType: types.KeyTypeRange},
+				},
+				ProvisionedThroughput: &types.ProvisionedThroughput{
+					ReadCapacityUnits:  aws.Int64(5),
+					WriteCapacityUnits: aws.Int64(5),
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
+			{
+				// only webhooks limited to one checklist have a ChecklistID, so the index holds just those
+				IndexName: aws.String("ChecklistIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("ChecklistID"), KeyType: types.KeyTypeHash},
+				},
+				ProvisionedThroughput: &types.ProvisionedThroughput{
+					ReadCapacityUnits:  aws.Int64(5),
+					WriteCapacityUnits: aws.Int64(5),
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to create table, %v", err)
+	}
+
+	fmt.Println("Table Webhooks created successfully with UserIndex and ChecklistIndex")
+	return nil
+}
@@ -0,0 +1,19 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"fmt"
+)
+
+// EnableInvitationsTTL has DynamoDB delete invitations once they pass their ExpiresAt time.
+func EnableInvitationsTTL() error {
+	service, _ := db.NewDynamoDBService()
+
+	if err := service.EnableTimeToLive("Invitations", "ExpiresAt"); err != nil {
+		fmt.Printf("Error enabling time to live on table Invitations: %v\n", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"fmt"
+)
+
+// EnableMailOutboxTTL has DynamoDB delete sent and failed emails once they pass their ExpiresAt time.
+func EnableMailOutboxTTL() error {
+	service, _ := db.NewDynamoDBService()
+
+	if err := service.EnableTimeToLive("MailOutbox", "ExpiresAt"); err != nil {
+		fmt.Printf("Error enabling time to live on table MailOutbox: %v\n", err)
+		return err
+	}
+
+	return nil
+}
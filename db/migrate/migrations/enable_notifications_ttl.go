@@ -0,0 +1,19 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"fmt"
+)
+
+// EnableNotificationsTTL has DynamoDB delete notifications once they pass their ExpiresAt time.
+func EnableNotificationsTTL() error {
+	service, _ := db.NewDynamoDBService()
+
+	if err := service.EnableTimeToLive("Notifications", "ExpiresAt"); err != nil {
+		fmt.Printf("Error enabling time to live on table Notifications: %v\n", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"fmt"
+)
+
+// EnableStreams turns on the change streams of the Checklists and ChecklistCollaborators tables, which the streams
+// consumer reads.
+func EnableStreams() error {
+	service, _ := db.NewDynamoDBService()
+
+	for _, tableName := range []string{"Checklists", "ChecklistCollaborators"} {
+		if err := service.EnableStream(tableName); err != nil {
+			fmt.Printf("Error enabling stream on table %s: %v\n", tableName, err)
+			return err
+		}
+	}
+
+	return nil
+}
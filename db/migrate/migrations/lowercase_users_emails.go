@@ -0,0 +1,24 @@
+// Package migrations provides the functions to create/update the database schema.
+package migrations
+
+import (
+	"checklist-api/db"
+	"fmt"
+)
+
+// LowercaseUsersEmails stores every user's email in lower case, like CreateUser and UpdateUser do, so the EmailIndex
+// also finds the users saved before, whatever case their email is given in. Only emails that aren't in lower case
+// yet are changed.
+func LowercaseUsersEmails() error {
+	service, _ := db.NewDynamoDBService()
+
+	updated, err := service.LowercaseUserEmails()
+	if err != nil {
+		fmt.Printf("Error lowercasing emails in table Users: %v\n", err)
+		return err
+	} else if updated > 0 {
+		fmt.Printf("Lowercased the emails of %d users\n", updated)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateNotification stores a notification for a user, unread. DynamoDB deletes it once expiresAt has passed.
+// Notification IDs sort in the order they were created, which is the order they are listed in.
+func (d *DynamoDBService) CreateNotification(userID string, notification *models.Notification, expiresAt time.Time) error {
+	item := map[string]types.AttributeValue{
+		"UserID":         &types.AttributeValueMemberS{Value: userID},
+		"ID":             &types.AttributeValueMemberS{Value: notification.ID},
+		"UnreadID":       &types.AttributeValueMemberS{Value: notification.ID},
+		"Type":           &types.AttributeValueMemberS{Value: string(notification.Type)},
+		"ChecklistID":    &types.AttributeValueMemberS{Value: notification.ChecklistID},
+		"ChecklistTitle": &types.AttributeValueMemberS{Value: notification.ChecklistTitle},
+		"ActorEmail":     &types.AttributeValueMemberS{Value: notification.Actor.Email},
+		"ActorPicture":   &types.AttributeValueMemberS{Value: notification.Actor.Picture},
+		"CreatedAt":      &types.AttributeValueMemberS{Value: notification.CreatedAt},
+		"ExpiresAt":      &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+	}
+	if notification.ItemID != "" {
+		item["ItemID"] = &types.AttributeValueMemberS{Value: notification.ItemID}
+		item["ItemContent"] = &types.AttributeValueMemberS{Value: notification.ItemContent}
+	}
+
+	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName: aws.String("Notifications"),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item, %v", err)
+	}
+
+	return nil
+}
+
+// GetNotifications retrieves a page of a user's notifications, newest first, starting after the notification with
+// the ID before, or from the newest if it is empty. It also returns the ID to start the next page after, which is
+// empty on the last page.
+func (d *DynamoDBService) GetNotifications(userID string, before string, limit int) ([]models.Notification, string, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String("Notifications"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(int32(limit)),
+	}
+	if before != "" {
+		input.KeyConditionExpression = aws.String("UserID = :userID AND ID < :before")
+		input.ExpressionAttributeValues[":before"] = &types.AttributeValueMemberS{Value: before}
+	}
+
+	output, err := d.Client.Query(context.TODO(), input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query table, %v", err)
+	}
+
+	notifications := []models.Notification{}
+	for _, record := range output.Items {
+		notifications = append(notifications, notificationFromItem(record))
+	}
+
+	next := ""
+	if output.LastEvaluatedKey != nil && len(notifications) > 0 {
+		next = notifications[len(notifications)-1].ID
+	}
+
+	return notifications, next, nil
+}
+
+// GetAllNotifications retrieves all of a user's notifications, oldest first.
+func (d *DynamoDBService) GetAllNotifications(userID string) ([]models.Notification, error) {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Notifications"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := []models.Notification{}
+	for _, record := range records {
+		notifications = append(notifications, notificationFromItem(record))
+	}
+
+	return notifications, nil
+}
+
+// CountUnreadNotifications counts a user's unread notifications.
+func (d *DynamoDBService) CountUnreadNotifications(userID string) (int, error) {
+	paginator := dynamodb.NewQueryPaginator(d.Client, &dynamodb.QueryInput{
+		TableName:              aws.String("Notifications"),
+		IndexName:              aws.String("UnreadIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		Select:                 types.SelectCount,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+
+	count := 0
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return 0, fmt.Errorf("failed to query table, %v", err)
+		}
+		count += int(output.Count)
+	}
+
+	return count, nil
+}
+
+// MarkNotificationRead marks one of a user's notifications as read. It returns false if there is no such notification.
+func (d *DynamoDBService) MarkNotificationRead(userID string, notificationID string) (bool, error) {
+	_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("Notifications"),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberS{Value: userID},
+			"ID":     &types.AttributeValueMemberS{Value: notificationID},
+		},
+		ConditionExpression: aws.String("attribute_exists(ID)"),
+		UpdateExpression:    aws.String("REMOVE UnreadID"),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to update item, %v", err)
+	}
+
+	return true, nil
+}
+
+// MarkAllNotificationsRead marks every one of a user's unread notifications as read, and returns how many there were.
+func (d *DynamoDBService) MarkAllNotificationsRead(userID string) (int, error) {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Notifications"),
+		IndexName:              aws.String("UnreadIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, record := range records {
+		_, err := d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+			TableName: aws.String("Notifications"),
+			Key: map[string]types.AttributeValue{
+				"UserID": record["UserID"],
+				"ID":     record["ID"],
+			},
+			UpdateExpression: aws.String("REMOVE UnreadID"),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to update item, %v", err)
+		}
+	}
+
+	return len(records), nil
+}
+
+// DeleteUserNotifications deletes all of a user's notifications.
+func (d *DynamoDBService) DeleteUserNotifications(userID string) error {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Notifications"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ProjectionExpression:   aws.String("UserID, ID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.deleteRecords("Notifications", records, "UserID", "ID")
+}
+
+// notificationFromItem converts a record from the Notifications table to a Notification.
+func notificationFromItem(item map[string]types.AttributeValue) models.Notification {
+	_, unread := item["UnreadID"]
+
+	notification := models.Notification{
+		ID:             item["ID"].(*types.AttributeValueMemberS).Value,
+		Type:           models.NotificationType(item["Type"].(*types.AttributeValueMemberS).Value),
+		ChecklistID:    item["ChecklistID"].(*types.AttributeValueMemberS).Value,
+		ChecklistTitle: item["ChecklistTitle"].(*types.AttributeValueMemberS).Value,
+		Actor: models.Collaborator{
+			Email:   item["ActorEmail"].(*types.AttributeValueMemberS).Value,
+			Picture: item["ActorPicture"].(*types.AttributeValueMemberS).Value,
+		},
+		Read:      !unread,
+		CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
+	}
+
+	if itemID, ok := item["ItemID"].(*types.AttributeValueMemberS); ok {
+		notification.ItemID = itemID.Value
+		notification.ItemContent = item["ItemContent"].(*types.AttributeValueMemberS).Value
+	}
+
+	return notification
+}
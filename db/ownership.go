@@ -0,0 +1,367 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxTransactItems is the most actions DynamoDB accepts in a single TransactWriteItems call.
+const maxTransactItems = 100
+
+// ErrNotCollaborator is returned when a user is expected to collaborate on a checklist, but doesn't.
+var ErrNotCollaborator = errors.New("user is not a collaborator on this checklist")
+
+// ErrTransferInProgress is returned when a checklist is transferred while an unfinished transfer to another
+// collaborator is pending.
+var ErrTransferInProgress = errors.New("checklist is already being transferred to another collaborator")
+
+// GetCollaboratorIDByEmail finds the ID of the collaborator on a checklist with the given email, whatever its case.
+// It returns an empty string if no collaborator has that email.
+func (d *DynamoDBService) GetCollaboratorIDByEmail(userID string, checklistID string, email string) (string, error) {
+	records, err := d.getCollaboratorRecords(userID, checklistID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, record := range records {
+		collaboratorID := record["CollaboratorID"].(*types.AttributeValueMemberS).Value
+		user, err := d.GetUser(collaboratorID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get user, %v", err)
+		}
+
+		if strings.EqualFold(user.Email, email) {
+			return collaboratorID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// TransferChecklist makes a collaborator the owner of a checklist.
+// The checklist and its items move to the new owner's partition, every collaborator record and pending invitation is
+// pointed at the new owner, and the previous owner stays on as a collaborator. A transaction holds at most 100
+// actions, so the move is split into transactions: the items go first, then the invitations and collaborators, and
+// the checklist itself moves last, which is when the transfer takes effect. The checklist is marked with its new
+// owner before anything moves, and a transfer that fails part way is resumed by running it again for the same
+// collaborator. Until then, the items already moved are missing from the checklist.
+// Every record is marked with the new owner before it is deleted, and its copy with the previous owner, so readers of
+// the change streams can tell the move from deletions and creations.
+func (d *DynamoDBService) TransferChecklist(userID string, checklistID string, newOwnerID string) error {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Checklists"),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			":sk": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var checklistRecord map[string]types.AttributeValue
+	itemRecords := make([]map[string]types.AttributeValue, 0, len(records))
+	for _, record := range records {
+		if record["SK"].(*types.AttributeValueMemberS).Value == "CHECKLIST#"+checklistID {
+			checklistRecord = record
+		} else {
+			itemRecords = append(itemRecords, record)
+		}
+	}
+
+	if checklistRecord == nil {
+		return fmt.Errorf("checklist does not exist")
+	}
+
+	// collaborators already pointed at the new owner by an earlier attempt are found under the new owner
+	collaboratorRecords, err := d.getCollaboratorRecords(userID, checklistID)
+	if err != nil {
+		return err
+	}
+
+	movedCollaboratorRecords, err := d.getCollaboratorRecords(newOwnerID, checklistID)
+	if err != nil {
+		return err
+	}
+	collaboratorRecords = append(collaboratorRecords, movedCollaboratorRecords...)
+
+	var newOwnerRecord map[string]types.AttributeValue
+	for _, record := range collaboratorRecords {
+		if record["CollaboratorID"].(*types.AttributeValueMemberS).Value == newOwnerID {
+			newOwnerRecord = record
+			break
+		}
+	}
+
+	if newOwnerRecord == nil {
+		return ErrNotCollaborator
+	}
+
+	invitations, err := d.GetChecklistInvitations(checklistID)
+	if err != nil {
+		return err
+	}
+
+	// mark the checklist, so a transfer that fails part way can only be finished for the same collaborator
+	_, err = d.Client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String("Checklists"),
+		Key: map[string]types.AttributeValue{
+			"PK": checklistRecord["PK"],
+			"SK": checklistRecord["SK"],
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":newOwnerID": &types.AttributeValueMemberS{Value: newOwnerID},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK) AND (attribute_not_exists(TransferTo) OR TransferTo = :newOwnerID)"),
+		UpdateExpression:    aws.String("SET TransferTo = :newOwnerID"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrTransferInProgress
+	} else if err != nil {
+		return fmt.Errorf("failed to mark checklist for transfer, %v", err)
+	}
+
+	// mark the items and the new owner's membership too, in transactions of their own, since a transaction can't
+	// mark a record and delete it
+	marks := []types.TransactWriteItem{}
+	for _, record := range itemRecords {
+		if _, marked := record["TransferTo"]; !marked {
+			marks = append(marks, markRecord("Checklists", record, newOwnerID))
+		}
+	}
+	if _, marked := newOwnerRecord["TransferTo"]; !marked {
+		marks = append(marks, markRecord("ChecklistCollaborators", newOwnerRecord, newOwnerID))
+	}
+
+	for start := 0; start < len(marks); start += maxTransactItems {
+		if err := d.transactWriteItems(marks[start:min(start+maxTransactItems, len(marks))]); err != nil {
+			return err
+		}
+	}
+
+	// each group is written in a single transaction, and the groups are written in order
+	groups := [][]types.TransactWriteItem{}
+
+	// move the items to the new owner's partition
+	for _, record := range itemRecords {
+		groups = append(groups, moveRecord(record, newOwnerID))
+	}
+
+	// point pending invitations at the new owner, so accepting one joins the checklist where it now lives
+	for _, invitation := range invitations {
+		groups = append(groups, []types.TransactWriteItem{{
+			Update: &types.Update{
+				TableName: aws.String("Invitations"),
+				Key: map[string]types.AttributeValue{
+					"ID": &types.AttributeValueMemberS{Value: invitation.ID},
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":ownerID": &types.AttributeValueMemberS{Value: newOwnerID},
+				},
+				ConditionExpression: aws.String("attribute_exists(ID)"),
+				UpdateExpression:    aws.String("SET OwnerID = :ownerID"),
+			},
+		}})
+	}
+
+	// point the remaining collaborators at the new owner
+	for _, record := range collaboratorRecords {
+		if record["CollaboratorID"].(*types.AttributeValueMemberS).Value == newOwnerID {
+			continue
+		}
+
+		groups = append(groups, []types.TransactWriteItem{{
+			Update: &types.Update{
+				TableName: aws.String("ChecklistCollaborators"),
+				Key: map[string]types.AttributeValue{
+					"PK": record["PK"],
+					"SK": record["SK"],
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":ownerID": &types.AttributeValueMemberS{Value: newOwnerID},
+					":gsi1pk":  &types.AttributeValueMemberS{Value: "USER#" + newOwnerID},
+				},
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+				UpdateExpression:    aws.String("SET OwnerID = :ownerID, GSI1PK = :gsi1pk"),
+			},
+		}})
+	}
+
+	// move the checklist with its public link and ingest URL, drop the new owner's own membership, and make the
+	// previous owner a collaborator
+	checklistMove := moveRecord(checklistRecord, newOwnerID)
+
+	if token, ok := checklistRecord["PublicToken"].(*types.AttributeValueMemberS); ok {
+		checklistMove = append(checklistMove, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String("Checklists"),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token.Value},
+					"SK": &types.AttributeValueMemberS{Value: "PUBLIC#" + token.Value},
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":ownerID": &types.AttributeValueMemberS{Value: newOwnerID},
+				},
+				UpdateExpression: aws.String("SET OwnerID = :ownerID"),
+			},
+		})
+	}
+
+	if hash, ok := checklistRecord["IngestHash"].(*types.AttributeValueMemberS); ok {
+		checklistMove = append(checklistMove, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String("Checklists"),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: "INGEST#" + hash.Value},
+					"SK": &types.AttributeValueMemberS{Value: "INGEST#" + hash.Value},
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":ownerID": &types.AttributeValueMemberS{Value: newOwnerID},
+				},
+				UpdateExpression: aws.String("SET OwnerID = :ownerID"),
+			},
+		})
+	}
+
+	checklistMove = append(checklistMove,
+		types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: aws.String("ChecklistCollaborators"),
+				Key: map[string]types.AttributeValue{
+					"PK": newOwnerRecord["PK"],
+					"SK": newOwnerRecord["SK"],
+				},
+			},
+		},
+		types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String("ChecklistCollaborators"),
+				Item: map[string]types.AttributeValue{
+					"PK":             &types.AttributeValueMemberS{Value: "USER#" + userID},
+					"SK":             &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+					"OwnerID":        &types.AttributeValueMemberS{Value: newOwnerID},
+					"GSI1PK":         &types.AttributeValueMemberS{Value: "USER#" + newOwnerID},
+					"GSI1SK":         &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+					"CollaboratorID": &types.AttributeValueMemberS{Value: userID},
+					"Role":           &types.AttributeValueMemberS{Value: string(models.RoleEditor)},
+				},
+			},
+		},
+	)
+	groups = append(groups, checklistMove)
+
+	// pack the groups into as few transactions as fit, keeping each group whole
+	transactItems := []types.TransactWriteItem{}
+	for _, group := range groups {
+		if len(transactItems)+len(group) > maxTransactItems {
+			if err := d.transactWriteItems(transactItems); err != nil {
+				return err
+			}
+			transactItems = []types.TransactWriteItem{}
+		}
+		transactItems = append(transactItems, group...)
+	}
+
+	return d.transactWriteItems(transactItems)
+}
+
+// markRecord returns the action that marks a record as being transferred to the new owner.
+func markRecord(tableName string, record map[string]types.AttributeValue, newOwnerID string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(tableName),
+			Key: map[string]types.AttributeValue{
+				"PK": record["PK"],
+				"SK": record["SK"],
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":newOwnerID": &types.AttributeValueMemberS{Value: newOwnerID},
+			},
+			ConditionExpression: aws.String("attribute_exists(PK)"),
+			UpdateExpression:    aws.String("SET TransferTo = :newOwnerID"),
+		},
+	}
+}
+
+// moveRecord returns the actions that move a record of the Checklists table to another user's partition. The copy
+// drops the transfer mark, and records who it was transferred from instead.
+func moveRecord(record map[string]types.AttributeValue, newOwnerID string) []types.TransactWriteItem {
+	moved := make(map[string]types.AttributeValue, len(record))
+	for key, value := range record {
+		moved[key] = value
+	}
+	moved["PK"] = &types.AttributeValueMemberS{Value: "USER#" + newOwnerID}
+	moved["TransferredFrom"] = &types.AttributeValueMemberS{Value: strings.TrimPrefix(record["PK"].(*types.AttributeValueMemberS).Value, "USER#")}
+	delete(moved, "TransferTo")
+
+	return []types.TransactWriteItem{
+		{
+			Put: &types.Put{
+				TableName:           aws.String("Checklists"),
+				Item:                moved,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			},
+		},
+		{
+			Delete: &types.Delete{
+				TableName: aws.String("Checklists"),
+				Key: map[string]types.AttributeValue{
+					"PK": record["PK"],
+					"SK": record["SK"],
+				},
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+			},
+		},
+	}
+}
+
+// transactWriteItems writes the actions in a single transaction.
+func (d *DynamoDBService) transactWriteItems(transactItems []types.TransactWriteItem) error {
+	_, err := d.Client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to transfer checklist, %v", err)
+	}
+
+	return nil
+}
+
+// getCollaboratorRecords retrieves the raw ChecklistCollaborators records for a checklist, using the GSI.
+// userID is the owner of the checklist.
+func (d *DynamoDBService) getCollaboratorRecords(userID string, checklistID string) ([]map[string]types.AttributeValue, error) {
+	return d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("ChecklistCollaborators"),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk AND GSI1SK = :sk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			":sk": &types.AttributeValueMemberS{Value: "CHECKLIST#" + checklistID},
+		},
+	})
+}
+
+// GetCollaboratorIDs retrieves the IDs of a checklist's collaborators. userID is the owner of the checklist.
+func (d *DynamoDBService) GetCollaboratorIDs(userID string, checklistID string) ([]string, error) {
+	records, err := d.getCollaboratorRecords(userID, checklistID)
+	if err != nil {
+		return nil, err
+	}
+
+	collaboratorIDs := make([]string, 0, len(records))
+	for _, record := range records {
+		collaboratorIDs = append(collaboratorIDs, record["CollaboratorID"].(*types.AttributeValueMemberS).Value)
+	}
+
+	return collaboratorIDs, nil
+}
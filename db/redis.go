@@ -70,3 +70,524 @@ func (rs *RedisService) GetJWTFromShortCode(shortCode string) (string, error) {
 
 	return val, nil
 }
+
+// userShareCodesKey is the set of share codes a user has created, so they can be found when the user is deleted.
+func userShareCodesKey(userID string) string {
+	return "sharecodes:USER#" + userID
+}
+
+// AddUserShareCode records a share code as created by a user. The set expires with the newest code in it.
+func (rs *RedisService) AddUserShareCode(userID string, shortCode string) error {
+	key := userShareCodesKey(userID)
+
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, shortCode)
+		pipe.Expire(ctx, key, 12*time.Hour)
+		return nil
+	})
+
+	return err
+}
+
+// DeleteUserShareCodes deletes every share code a user has created.
+func (rs *RedisService) DeleteUserShareCodes(userID string) error {
+	key := userShareCodesKey(userID)
+
+	shortCodes, err := rs.Client.SMembers(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, shortCode := range shortCodes {
+		if err := rs.Client.Del(ctx, shortCode).Err(); err != nil {
+			return err
+		}
+	}
+
+	return rs.Client.Del(ctx, key).Err()
+}
+
+// exportKey is the hash that tracks an account export's owner and status.
+func exportKey(exportID string) string {
+	return "export:" + exportID
+}
+
+// pendingExportsKey is the set of account exports that are still being built.
+const pendingExportsKey = "exports:pending"
+
+// exportLeaseKey is held by the API instance building an account export, for as long as it keeps renewing it.
+func exportLeaseKey(exportID string) string {
+	return exportKey(exportID) + ":lease"
+}
+
+// SetExportStatus records the owner and status of an account export, which expires after the TTL.
+// The error is only kept for failed exports.
+func (rs *RedisService) SetExportStatus(exportID string, userID string, status string, exportErr string, ttl time.Duration) error {
+	key := exportKey(exportID)
+
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user", userID, "status", status, "error", exportErr)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+
+	return err
+}
+
+// AddPendingExport tracks an account export while it is built, and gives it a lease for the TTL.
+func (rs *RedisService) AddPendingExport(exportID string, ttl time.Duration) error {
+	if err := rs.Client.Set(ctx, exportLeaseKey(exportID), "1", ttl).Err(); err != nil {
+		return err
+	}
+
+	return rs.Client.SAdd(ctx, pendingExportsKey, exportID).Err()
+}
+
+// RenewExportLease records that an account export is still being built, for the TTL.
+func (rs *RedisService) RenewExportLease(exportID string, ttl time.Duration) error {
+	return rs.Client.Set(ctx, exportLeaseKey(exportID), "1", ttl).Err()
+}
+
+// GetAbandonedExports retrieves the pending account exports whose lease has run out, because the instance building
+// them stopped.
+func (rs *RedisService) GetAbandonedExports() ([]string, error) {
+	exportIDs, err := rs.Client.SMembers(ctx, pendingExportsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	abandoned := []string{}
+	for _, exportID := range exportIDs {
+		held, err := rs.Client.Exists(ctx, exportLeaseKey(exportID)).Result()
+		if err != nil {
+			return nil, err
+		} else if held == 0 {
+			abandoned = append(abandoned, exportID)
+		}
+	}
+
+	return abandoned, nil
+}
+
+// RemovePendingExport stops tracking an account export, once it is ready or has failed.
+func (rs *RedisService) RemovePendingExport(exportID string) error {
+	if err := rs.Client.SRem(ctx, pendingExportsKey, exportID).Err(); err != nil {
+		return err
+	}
+
+	return rs.Client.Del(ctx, exportLeaseKey(exportID)).Err()
+}
+
+// GetExportStatus retrieves the owner, status and error of an account export. The owner is empty if the export
+// doesn't exist or has expired.
+func (rs *RedisService) GetExportStatus(exportID string) (string, string, string, error) {
+	fields, err := rs.Client.HGetAll(ctx, exportKey(exportID)).Result()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return fields["user"], fields["status"], fields["error"], nil
+}
+
+// SetExportArchive stores a finished account export, which expires after the TTL.
+func (rs *RedisService) SetExportArchive(exportID string, archive []byte, ttl time.Duration) error {
+	return rs.Client.Set(ctx, exportKey(exportID)+":archive", archive, ttl).Err()
+}
+
+// GetExportArchive retrieves a finished account export. It is nil if the archive has expired.
+func (rs *RedisService) GetExportArchive(exportID string) ([]byte, error) {
+	archive, err := rs.Client.Get(ctx, exportKey(exportID)+":archive").Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return archive, err
+}
+
+// checklistEventsChannel is the pub/sub channel that carries a checklist's events between API instances.
+func checklistEventsChannel(checklistID string) string {
+	return "events:CHECKLIST#" + checklistID
+}
+
+// PublishChecklistEvent sends an encoded event to every API instance watching the checklist.
+func (rs *RedisService) PublishChecklistEvent(checklistID string, payload []byte) error {
+	return rs.Client.Publish(ctx, checklistEventsChannel(checklistID), payload).Err()
+}
+
+// SubscribeChecklistEvents subscribes to a checklist's events. The subscription must be closed when done with.
+func (rs *RedisService) SubscribeChecklistEvents(checklistID string) *redis.PubSub {
+	return rs.Client.Subscribe(ctx, checklistEventsChannel(checklistID))
+}
+
+// userFeedKey is the stream of events for one user, across all their checklists.
+func userFeedKey(userID string) string {
+	return "feed:USER#" + userID
+}
+
+// AppendUserFeed adds an encoded event to a user's feed. Entries older than the retention window are trimmed,
+// and the feed expires altogether once nothing has been added to it for that long.
+func (rs *RedisService) AppendUserFeed(userID string, payload []byte, retention time.Duration) error {
+	key := userFeedKey(userID)
+	minID := strconv.FormatInt(time.Now().Add(-retention).UnixMilli(), 10)
+
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: key,
+			MinID:  minID,
+			Approx: true,
+			Values: map[string]interface{}{"event": payload},
+		})
+		pipe.Expire(ctx, key, retention)
+		return nil
+	})
+
+	return err
+}
+
+// GetUserFeedBounds returns the IDs of the oldest and newest entries in a user's feed. Both are empty if it is empty.
+func (rs *RedisService) GetUserFeedBounds(userID string) (string, string, error) {
+	key := userFeedKey(userID)
+
+	oldest, err := rs.Client.XRangeN(ctx, key, "-", "+", 1).Result()
+	if err != nil || len(oldest) == 0 {
+		return "", "", err
+	}
+
+	newest, err := rs.Client.XRevRangeN(ctx, key, "+", "-", 1).Result()
+	if err != nil || len(newest) == 0 {
+		return "", "", err
+	}
+
+	return oldest[0].ID, newest[0].ID, nil
+}
+
+// ReadUserFeed returns the entries in a user's feed after the given ID, waiting up to block for one to arrive.
+// It returns no entries if none arrived in time.
+func (rs *RedisService) ReadUserFeed(readCtx context.Context, userID string, afterID string, block time.Duration) ([]redis.XMessage, error) {
+	streams, err := rs.Client.XRead(readCtx, &redis.XReadArgs{
+		Streams: []string{userFeedKey(userID), afterID},
+		Block:   block,
+		Count:   100,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return streams[0].Messages, nil
+}
+
+// presenceKey is the sorted set of users viewing a checklist, scored by when their presence expires.
+func presenceKey(checklistID string) string {
+	return "presence:CHECKLIST#" + checklistID
+}
+
+// SetPresence records a user as viewing a checklist until expiresAt. It returns true if they weren't already recorded.
+func (rs *RedisService) SetPresence(checklistID string, userID string, expiresAt time.Time) (bool, error) {
+	key := presenceKey(checklistID)
+
+	var added *redis.IntCmd
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiresAt.UnixMilli()), Member: userID})
+		pipe.ExpireAt(ctx, key, expiresAt)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return added.Val() == 1, nil
+}
+
+// RemovePresence records a user as no longer viewing a checklist. It returns false if they weren't recorded,
+// so only one caller reports them leaving.
+func (rs *RedisService) RemovePresence(checklistID string, userID string) (bool, error) {
+	removed, err := rs.Client.ZRem(ctx, presenceKey(checklistID), userID).Result()
+	return removed == 1, err
+}
+
+// GetPresence returns the users whose presence on a checklist expires after now, and those whose has expired.
+func (rs *RedisService) GetPresence(checklistID string, now time.Time) ([]string, []string, error) {
+	key := presenceKey(checklistID)
+	cutoff := strconv.FormatInt(now.UnixMilli(), 10)
+
+	present, err := rs.Client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "(" + cutoff, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expired, err := rs.Client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "-inf", Max: cutoff}).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return present, expired, nil
+}
+
+// presenceStreamsKey counts a user's open event streams on a checklist, like one for each browser tab.
+func presenceStreamsKey(checklistID string, userID string) string {
+	return presenceKey(checklistID) + ":USER#" + userID + ":streams"
+}
+
+// AddPresenceStream counts another open event stream of a user on a checklist. The count expires after the TTL,
+// unless it is renewed, so streams on an instance that stopped aren't counted forever.
+func (rs *RedisService) AddPresenceStream(checklistID string, userID string, ttl time.Duration) error {
+	key := presenceStreamsKey(checklistID, userID)
+
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+
+	return err
+}
+
+// RenewPresenceStreams keeps the count of a user's open event streams on a checklist for another TTL.
+func (rs *RedisService) RenewPresenceStreams(checklistID string, userID string, ttl time.Duration) error {
+	return rs.Client.Expire(ctx, presenceStreamsKey(checklistID, userID), ttl).Err()
+}
+
+// RemovePresenceStream counts one fewer open event stream of a user on a checklist, and returns how many are left.
+func (rs *RedisService) RemovePresenceStream(checklistID string, userID string) (int64, error) {
+	key := presenceStreamsKey(checklistID, userID)
+
+	left, err := rs.Client.Decr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	} else if left <= 0 {
+		return 0, rs.Client.Del(ctx, key).Err()
+	}
+
+	return left, nil
+}
+
+// itemEditsKey is the sorted set of recent edits to an item's content, scored by the version each was made against.
+func itemEditsKey(itemID string) string {
+	return "edits:ITEM#" + itemID
+}
+
+// AddItemEdit records the edit that moved an item's content on from version. Only the last keep edits are kept,
+// and they all expire after ttl without further edits.
+func (rs *RedisService) AddItemEdit(itemID string, version int, payload []byte, keep int, ttl time.Duration) error {
+	key := itemEditsKey(itemID)
+
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(version), Member: payload})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.Itoa(version-keep+1))
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+
+	return err
+}
+
+// GetItemEdits returns the recorded edits to an item's content made against versions from fromVersion up to,
+// but not including, toVersion, oldest first.
+func (rs *RedisService) GetItemEdits(itemID string, fromVersion int, toVersion int) ([]string, error) {
+	return rs.Client.ZRangeByScore(ctx, itemEditsKey(itemID), &redis.ZRangeBy{
+		Min: strconv.Itoa(fromVersion),
+		Max: "(" + strconv.Itoa(toVersion),
+	}).Result()
+}
+
+// streamCheckpointKey holds the sequence number of the last record processed from a shard of a table's stream.
+func streamCheckpointKey(streamARN string, shardID string) string {
+	return "streams:checkpoint:" + streamARN + ":" + shardID
+}
+
+// GetStreamCheckpoint returns the checkpoint recorded for a stream shard, or an empty string if there is none.
+func (rs *RedisService) GetStreamCheckpoint(streamARN string, shardID string) (string, error) {
+	checkpoint, err := rs.Client.Get(ctx, streamCheckpointKey(streamARN, shardID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+
+	return checkpoint, err
+}
+
+// SetStreamCheckpoint records a stream shard's checkpoint. It expires after ttl, which should outlast the shard's
+// records.
+func (rs *RedisService) SetStreamCheckpoint(streamARN string, shardID string, checkpoint string, ttl time.Duration) error {
+	return rs.Client.Set(ctx, streamCheckpointKey(streamARN, shardID), checkpoint, ttl).Err()
+}
+
+// streamLeaseKey holds the ID of the API instance that reads the tables' streams.
+const streamLeaseKey = "streams:lease"
+
+// AcquireStreamLease makes holder the instance that reads the streams for ttl, or extends its lease. It returns
+// false if another instance holds the lease.
+func (rs *RedisService) AcquireStreamLease(holder string, ttl time.Duration) (bool, error) {
+	acquired, err := rs.Client.SetNX(ctx, streamLeaseKey, holder, ttl).Result()
+	if err != nil || acquired {
+		return acquired, err
+	}
+
+	current, err := rs.Client.Get(ctx, streamLeaseKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil || current != holder {
+		return false, err
+	}
+
+	return true, rs.Client.Expire(ctx, streamLeaseKey, ttl).Err()
+}
+
+// webhookDeliveryKey holds a webhook delivery, as JSON.
+func webhookDeliveryKey(deliveryID string) string {
+	return "webhooks:delivery:" + deliveryID
+}
+
+// webhookDeliveriesKey lists a webhook's recent deliveries, newest first.
+func webhookDeliveriesKey(webhookID string) string {
+	return "webhooks:deliveries:" + webhookID
+}
+
+// webhookQueueKey is the sorted set of deliveries waiting to be attempted, scored by when they are due.
+const webhookQueueKey = "webhooks:queue"
+
+// SaveWebhookDelivery stores a delivery for ttl. New deliveries are added to the front of their webhook's list,
+// which keeps the latest keep deliveries.
+func (rs *RedisService) SaveWebhookDelivery(webhookID string, deliveryID string, payload []byte, isNew bool, keep int, ttl time.Duration) error {
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, webhookDeliveryKey(deliveryID), payload, ttl)
+		if isNew {
+			pipe.LPush(ctx, webhookDeliveriesKey(webhookID), deliveryID)
+			pipe.LTrim(ctx, webhookDeliveriesKey(webhookID), 0, int64(keep-1))
+			pipe.Expire(ctx, webhookDeliveriesKey(webhookID), ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// GetWebhookDelivery returns a stored delivery, or nil if it doesn't exist or has expired.
+func (rs *RedisService) GetWebhookDelivery(deliveryID string) ([]byte, error) {
+	payload, err := rs.Client.Get(ctx, webhookDeliveryKey(deliveryID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return payload, err
+}
+
+// GetWebhookDeliveries returns a webhook's recent deliveries, newest first. Deliveries that have expired are left out.
+func (rs *RedisService) GetWebhookDeliveries(webhookID string) ([][]byte, error) {
+	deliveryIDs, err := rs.Client.LRange(ctx, webhookDeliveriesKey(webhookID), 0, -1).Result()
+	if err != nil || len(deliveryIDs) == 0 {
+		return nil, err
+	}
+
+	// deliveries are fetched one by one, since their keys can be on different cluster nodes
+	deliveries := [][]byte{}
+	for _, deliveryID := range deliveryIDs {
+		payload, err := rs.Client.Get(ctx, webhookDeliveryKey(deliveryID)).Bytes()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, payload)
+	}
+
+	return deliveries, nil
+}
+
+// QueueWebhookDelivery schedules a delivery to be attempted at the given time.
+func (rs *RedisService) QueueWebhookDelivery(deliveryID string, at time.Time) error {
+	return rs.Client.ZAdd(ctx, webhookQueueKey, redis.Z{Score: float64(at.UnixMilli()), Member: deliveryID}).Err()
+}
+
+// DequeueWebhookDelivery removes a delivery from the queue, once it has succeeded or run out of attempts.
+func (rs *RedisService) DequeueWebhookDelivery(deliveryID string) error {
+	return rs.Client.ZRem(ctx, webhookQueueKey, deliveryID).Err()
+}
+
+// claimWebhookDelivery moves a due delivery's time on, so no other instance attempts it at the same time. If the
+// instance that claimed it stops before rescheduling or dequeueing it, it comes due again.
+var claimWebhookDelivery = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) <= tonumber(ARGV[2]) then
+	redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// ClaimDueWebhookDeliveries claims up to limit deliveries that are due at now, until claimedUntil.
+func (rs *RedisService) ClaimDueWebhookDeliveries(now time.Time, claimedUntil time.Time, limit int) ([]string, error) {
+	due, err := rs.Client.ZRangeByScore(ctx, webhookQueueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := []string{}
+	for _, deliveryID := range due {
+		won, err := claimWebhookDelivery.Run(ctx, rs.Client, []string{webhookQueueKey}, deliveryID, now.UnixMilli(), claimedUntil.UnixMilli()).Int()
+		if err != nil {
+			return nil, err
+		} else if won == 1 {
+			claimed = append(claimed, deliveryID)
+		}
+	}
+
+	return claimed, nil
+}
+
+// checklistCompletedKey is the set of reporters told that a checklist was completed, so each is only told once until
+// it is reopened.
+func checklistCompletedKey(checklistID string) string {
+	return "completed:CHECKLIST#" + checklistID
+}
+
+// MarkChecklistCompleted records that a reporter was told a checklist has every item checked. It returns false if
+// they already were. The mark expires after the TTL.
+func (rs *RedisService) MarkChecklistCompleted(checklistID string, reporter string, ttl time.Duration) (bool, error) {
+	key := checklistCompletedKey(checklistID)
+
+	var added *redis.IntCmd
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.SAdd(ctx, key, reporter)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return added.Val() == 1, nil
+}
+
+// ClearChecklistCompleted records that a checklist has an unchecked item again, for every reporter.
+func (rs *RedisService) ClearChecklistCompleted(checklistID string) error {
+	return rs.Client.Del(ctx, checklistCompletedKey(checklistID)).Err()
+}
+
+// ingestRateKey counts the requests made with an ingest secret in one window, which starts at the given Unix time.
+func ingestRateKey(hash string, windowStart int64) string {
+	return "ingest:rate:" + hash + ":" + strconv.FormatInt(windowStart, 10)
+}
+
+// IncrementIngestRate counts a request made with an ingest secret in the window starting at windowStart, and returns
+// the number made in that window so far. The count expires once the window is over.
+func (rs *RedisService) IncrementIngestRate(hash string, windowStart time.Time, window time.Duration) (int64, error) {
+	key := ingestRateKey(hash, windowStart.Unix())
+
+	var count *redis.IntCmd
+	_, err := rs.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.Incr(ctx, key)
+		pipe.ExpireAt(ctx, key, windowStart.Add(window))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count.Val(), nil
+}
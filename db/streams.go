@@ -0,0 +1,80 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+)
+
+// NewDynamoDBStreamsClient creates a client for reading the tables' change streams. In development it reads the
+// streams of DynamoDB Local.
+func NewDynamoDBStreamsClient() (*dynamodbstreams.Client, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("us-east-1"))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration, %v", err)
+	}
+
+	return dynamodbstreams.NewFromConfig(cfg, func(o *dynamodbstreams.Options) {
+		str := os.Getenv("ENVIRONMENT")
+		if str == "development" {
+			o.BaseEndpoint = aws.String("http://localhost:8000")
+		}
+	}), nil
+}
+
+// EnableStream turns on a table's change stream, with both the old and new images of each changed record,
+// if it isn't on already.
+func (d *DynamoDBService) EnableStream(tableName string) error {
+	output, err := d.Client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe table, %v", err)
+	}
+
+	spec := output.Table.StreamSpecification
+	if spec != nil && aws.ToBool(spec.StreamEnabled) {
+		if spec.StreamViewType != types.StreamViewTypeNewAndOldImages {
+			return fmt.Errorf("table %s already streams %s, but new and old images are needed", tableName, spec.StreamViewType)
+		}
+		return nil
+	}
+
+	_, err = d.Client.UpdateTable(context.TODO(), &dynamodb.UpdateTableInput{
+		TableName: aws.String(tableName),
+		StreamSpecification: &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeNewAndOldImages,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update table, %v", err)
+	}
+
+	return nil
+}
+
+// GetStreamARN returns the ARN of a table's change stream, or an empty string if its stream is off.
+func (d *DynamoDBService) GetStreamARN(tableName string) (string, error) {
+	output, err := d.Client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe table, %v", err)
+	}
+
+	spec := output.Table.StreamSpecification
+	if spec == nil || !aws.ToBool(spec.StreamEnabled) {
+		return "", nil
+	}
+
+	return aws.ToString(output.Table.LatestStreamArn), nil
+}
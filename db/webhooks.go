@@ -0,0 +1,181 @@
+// Package db sets up the database connection and provides the query functions for the application.
+package db
+
+import (
+	"checklist-api/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateWebhook stores a user's webhook, along with the secret its deliveries are signed with.
+func (d *DynamoDBService) CreateWebhook(userID string, secret string, webhook *models.Webhook) error {
+	item := map[string]types.AttributeValue{
+		"ID":        &types.AttributeValueMemberS{Value: webhook.ID},
+		"UserID":    &types.AttributeValueMemberS{Value: userID},
+		"URL":       &types.AttributeValueMemberS{Value: webhook.URL},
+		"Events":    &types.AttributeValueMemberSS{Value: webhook.Events},
+		"Secret":    &types.AttributeValueMemberS{Value: secret},
+		"CreatedAt": &types.AttributeValueMemberS{Value: webhook.CreatedAt},
+	}
+	if webhook.ChecklistID != "" {
+		item["ChecklistID"] = &types.AttributeValueMemberS{Value: webhook.ChecklistID}
+	}
+
+	_, err := d.Client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:           aws.String("Webhooks"),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item, %v", err)
+	}
+
+	return nil
+}
+
+// GetWebhooks retrieves all of a user's webhooks, oldest first.
+func (d *DynamoDBService) GetWebhooks(userID string) ([]models.Webhook, error) {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Webhooks"),
+		IndexName:              aws.String("UserIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := []models.Webhook{}
+	for _, record := range records {
+		webhooks = append(webhooks, webhookFromItem(record))
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhook retrieves a webhook by its ID, along with the ID of the user it belongs to and its secret.
+// The user ID is empty if there is no such webhook.
+func (d *DynamoDBService) GetWebhook(webhookID string) (string, models.Webhook, string, error) {
+	output, err := d.Client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("Webhooks"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: webhookID},
+		},
+	})
+	if err != nil {
+		return "", models.Webhook{}, "", fmt.Errorf("failed to get item, %v", err)
+	}
+
+	if output.Item == nil {
+		return "", models.Webhook{}, "", nil
+	}
+
+	userID := output.Item["UserID"].(*types.AttributeValueMemberS).Value
+	secret := output.Item["Secret"].(*types.AttributeValueMemberS).Value
+
+	return userID, webhookFromItem(output.Item), secret, nil
+}
+
+// GetWebhookSubscribers finds the webhooks that get a checklist's events: those limited to the checklist, and those
+// of the given users that aren't limited to one checklist. It returns each webhook's user, keyed by webhook ID.
+func (d *DynamoDBService) GetWebhookSubscribers(checklistID string, userIDs []string) (map[string]models.Webhook, map[string]string, error) {
+	webhooks := map[string]models.Webhook{}
+	owners := map[string]string{}
+
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Webhooks"),
+		IndexName:              aws.String("ChecklistIndex"),
+		KeyConditionExpression: aws.String("ChecklistID = :checklistID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":checklistID": &types.AttributeValueMemberS{Value: checklistID},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, userID := range userIDs {
+		userRecords, err := d.queryAll(&dynamodb.QueryInput{
+			TableName:              aws.String("Webhooks"),
+			IndexName:              aws.String("UserIndex"),
+			KeyConditionExpression: aws.String("UserID = :userID"),
+			FilterExpression:       aws.String("attribute_not_exists(ChecklistID)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":userID": &types.AttributeValueMemberS{Value: userID},
+			},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, userRecords...)
+	}
+
+	for _, record := range records {
+		webhook := webhookFromItem(record)
+		webhooks[webhook.ID] = webhook
+		owners[webhook.ID] = record["UserID"].(*types.AttributeValueMemberS).Value
+	}
+
+	return webhooks, owners, nil
+}
+
+// DeleteWebhook deletes one of a user's webhooks. It returns false if the user has no webhook with that ID.
+func (d *DynamoDBService) DeleteWebhook(userID string, webhookID string) (bool, error) {
+	_, err := d.Client.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+		TableName: aws.String("Webhooks"),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: webhookID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ConditionExpression: aws.String("UserID = :userID"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to delete item, %v", err)
+	}
+
+	return true, nil
+}
+
+// DeleteUserWebhooks deletes all of a user's webhooks.
+func (d *DynamoDBService) DeleteUserWebhooks(userID string) error {
+	records, err := d.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String("Webhooks"),
+		IndexName:              aws.String("UserIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.deleteRecords("Webhooks", records, "ID")
+}
+
+// webhookFromItem converts a Webhooks record to a Webhook, leaving out its secret.
+func webhookFromItem(item map[string]types.AttributeValue) models.Webhook {
+	webhook := models.Webhook{
+		ID:        item["ID"].(*types.AttributeValueMemberS).Value,
+		URL:       item["URL"].(*types.AttributeValueMemberS).Value,
+		Events:    item["Events"].(*types.AttributeValueMemberSS).Value,
+		CreatedAt: item["CreatedAt"].(*types.AttributeValueMemberS).Value,
+	}
+	if checklistID, ok := item["ChecklistID"].(*types.AttributeValueMemberS); ok {
+		webhook.ChecklistID = checklistID.Value
+	}
+
+	return webhook
+}
@@ -0,0 +1,83 @@
+// Package events dispatches changes to checklists to the sinks that deliver them, like realtime streams.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/models"
+)
+
+// Sink delivers events somewhere, like to the clients watching a checklist.
+type Sink interface {
+	Publish(event models.Event) error
+}
+
+var (
+	mu    sync.RWMutex
+	sinks []Sink
+	// fromStreams are the event types that come from the tables' change streams instead of the handlers
+	fromStreams map[models.EventType]bool
+)
+
+// Register adds a sink that every published event is delivered to.
+func Register(sink Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	sinks = append(sinks, sink)
+}
+
+// Publish delivers an event to every registered sink, filling in its ID and time if they are empty.
+// The change has already happened when it is published, so a sink that fails is logged rather than failing the request.
+// Events of the types set with SourceFromStreams are dropped, since the stream delivers them instead.
+func Publish(event models.Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if !fromStreams[event.Type] {
+		deliver(event)
+	}
+}
+
+// SourceFromStreams makes events of the given types come from the tables' change streams, through PublishFromStream,
+// instead of from the handlers that make the changes.
+func SourceFromStreams(types ...models.EventType) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fromStreams = map[models.EventType]bool{}
+	for _, eventType := range types {
+		fromStreams[eventType] = true
+	}
+}
+
+// PublishFromStream delivers an event read from a table's change stream, like Publish. Only the types set with
+// SourceFromStreams are delivered, so no event reaches the sinks twice.
+func PublishFromStream(event models.Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if fromStreams[event.Type] {
+		deliver(event)
+	}
+}
+
+// deliver fills in an event's ID and time, and hands it to every sink. The caller must hold mu.
+func deliver(event models.Event) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Time == "" {
+		event.Time = time.Now().Format(time.RFC3339)
+	}
+
+	for _, sink := range sinks {
+		if err := sink.Publish(event); err != nil {
+			fmt.Printf("Error publishing %s event for checklist %s: %v\n", event.Type, event.ChecklistID, err)
+		}
+	}
+}
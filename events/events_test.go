@@ -0,0 +1,62 @@
+// Package events dispatches changes to checklists to the sinks that deliver them, like realtime streams.
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"checklist-api/models"
+)
+
+type recordingSink struct {
+	events []models.Event
+	err    error
+}
+
+func (s *recordingSink) Publish(event models.Event) error {
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestPublish(t *testing.T) {
+	t.Cleanup(func() { sinks = nil })
+
+	failing := &recordingSink{err: errors.New("unavailable")}
+	recording := &recordingSink{}
+	Register(failing)
+	Register(recording)
+
+	Publish(models.Event{Type: models.EventItemCreated, ChecklistID: "groceries"})
+
+	if len(recording.events) != 1 {
+		t.Fatalf("Expected the event to reach every sink, even after one failed, but got %d events", len(recording.events))
+	}
+
+	event := recording.events[0]
+	if event.ID == "" || event.Time == "" {
+		t.Fatalf("Expected the event's ID and time to be filled in, but got %+v", event)
+	}
+	if failing.events[0].ID != event.ID {
+		t.Fatal("Expected every sink to get the same event")
+	}
+}
+
+func TestSourceFromStreams(t *testing.T) {
+	t.Cleanup(func() { sinks, fromStreams = nil, nil })
+
+	recording := &recordingSink{}
+	Register(recording)
+	SourceFromStreams(models.EventItemCreated)
+
+	Publish(models.Event{Type: models.EventItemCreated, ChecklistID: "groceries"})
+	PublishFromStream(models.Event{Type: models.EventItemUpdated, ChecklistID: "groceries"})
+	if len(recording.events) != 0 {
+		t.Fatalf("Expected each type to come from one source only, but got %+v", recording.events)
+	}
+
+	Publish(models.Event{Type: models.EventItemUpdated, ChecklistID: "groceries"})
+	PublishFromStream(models.Event{Type: models.EventItemCreated, ChecklistID: "groceries"})
+	if len(recording.events) != 2 {
+		t.Fatalf("Expected both events to be delivered, but got %+v", recording.events)
+	}
+}
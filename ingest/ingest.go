@@ -0,0 +1,125 @@
+// Package ingest turns text POSTed to a checklist's secret ingest URL into items. The URL works without logging in,
+// so its secret is stored hashed, and requests made with it are rate limited.
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/tokens"
+)
+
+// SecretPrefix starts every ingest secret, so they can be told apart from other tokens.
+const SecretPrefix = "listo_ingest_"
+
+// The limits on what one secret can add. Each request can add up to MaxItems items, and each secret can be used
+// RateLimit times in every RateWindow.
+const (
+	MaxBodyBytes = 64 << 10
+	MaxItems     = 100
+	RateLimit    = 30
+	RateWindow   = time.Minute
+)
+
+var (
+	// ErrNoItems is returned when a request has no lines with any text on them.
+	ErrNoItems = errors.New("no items to add")
+	// ErrTooManyItems is returned when a request has more than MaxItems items.
+	ErrTooManyItems = fmt.Errorf("at most %d items can be added at once", MaxItems)
+	// ErrInvalidJSON is returned when a JSON request isn't an object with content or items.
+	ErrInvalidJSON = errors.New(`JSON requests must be an object with "content" or "items"`)
+)
+
+// Request is the JSON form of a request. Content is split into items one per line, like a plain text request, and
+// each of Items is one item.
+type Request struct {
+	Content string   `json:"content"`
+	Items   []string `json:"items"`
+}
+
+// NewSecret generates an ingest secret, and the hash it should be stored under.
+// Secrets are looked up by their tokens.Hash.
+func NewSecret() (string, string, error) {
+	return tokens.New(SecretPrefix)
+}
+
+// Parse reads the items from a request's body. JSON bodies are read as a Request, and anything else as text, with
+// one item per line.
+func Parse(contentType string, body []byte) ([]string, error) {
+	var contents []string
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" {
+		var request Request
+		if err := json.Unmarshal(body, &request); err != nil {
+			return nil, ErrInvalidJSON
+		}
+
+		contents = Lines(request.Content)
+		for _, item := range request.Items {
+			if content := strings.TrimSpace(item); content != "" {
+				contents = append(contents, content)
+			}
+		}
+	} else {
+		contents = Lines(string(body))
+	}
+
+	if len(contents) == 0 {
+		return nil, ErrNoItems
+	} else if len(contents) > MaxItems {
+		return nil, ErrTooManyItems
+	}
+
+	return contents, nil
+}
+
+// listMarkers are stripped from the start of lines, so lists pasted from notes or Markdown don't keep their bullets.
+var listMarkers = []string{"- [ ] ", "* [ ] ", "[ ] ", "- ", "* ", "• "}
+
+// Lines splits text into items, one per line. Surrounding whitespace and list markers are trimmed, and blank lines
+// are skipped.
+func Lines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		for _, marker := range listMarkers {
+			if trimmed, ok := strings.CutPrefix(line+" ", marker); ok {
+				line = strings.TrimSpace(trimmed)
+				break
+			}
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// Allow counts a request made with the secret with the given hash, and reports whether it is within the rate limit.
+// If it isn't, it also returns how long until the next window starts.
+func Allow(hash string, now time.Time) (bool, time.Duration, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return false, 0, err
+	}
+	defer redisService.Client.Close()
+
+	windowStart := now.Truncate(RateWindow)
+
+	count, err := redisService.IncrementIngestRate(hash, windowStart, RateWindow)
+	if err != nil {
+		return false, 0, err
+	} else if count > RateLimit {
+		return false, windowStart.Add(RateWindow).Sub(now), nil
+	}
+
+	return true, 0, nil
+}
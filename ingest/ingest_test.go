@@ -0,0 +1,56 @@
+// Package ingest turns text POSTed to a checklist's secret ingest URL into items.
+package ingest
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"checklist-api/tokens"
+)
+
+func TestLines(t *testing.T) {
+	text := "Milk\r\n\n  - Eggs \n* [ ] Bread\n• Oat milk\n-5 degrees\n   \n"
+
+	expected := []string{"Milk", "Eggs", "Bread", "Oat milk", "-5 degrees"}
+	if lines := Lines(text); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("Expected %q, but got %q", expected, lines)
+	}
+}
+
+func TestParse(t *testing.T) {
+	items, err := Parse("text/plain; charset=utf-8", []byte("Milk\nEggs\n"))
+	if err != nil || !reflect.DeepEqual(items, []string{"Milk", "Eggs"}) {
+		t.Fatalf("Expected a line per item from text, but got %q and %v", items, err)
+	}
+
+	items, err = Parse("application/json", []byte(`{"content": "Milk\nEggs", "items": ["Bread\nrolls", " "]}`))
+	if err != nil || !reflect.DeepEqual(items, []string{"Milk", "Eggs", "Bread\nrolls"}) {
+		t.Fatalf("Expected content split into lines and items kept whole, but got %q and %v", items, err)
+	}
+
+	if _, err := Parse("application/json", []byte(`["Milk"]`)); !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("Expected ErrInvalidJSON, but got %v", err)
+	}
+	if _, err := Parse("text/plain", []byte("\n  \n- \n")); !errors.Is(err, ErrNoItems) {
+		t.Fatalf("Expected ErrNoItems, but got %v", err)
+	}
+	if _, err := Parse("", []byte(strings.Repeat("Milk\n", MaxItems+1))); !errors.Is(err, ErrTooManyItems) {
+		t.Fatalf("Expected ErrTooManyItems, but got %v", err)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, hash, err := NewSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+
+	if !strings.HasPrefix(secret, SecretPrefix) {
+		t.Fatalf("Expected the secret to start with %s, but got %s", SecretPrefix, secret)
+	}
+	if hash != tokens.Hash(secret) {
+		t.Fatal("Expected the secret's hash to be returned")
+	}
+}
@@ -0,0 +1,254 @@
+// Package invitations invites people to collaborate on checklists by email address. People who already have an
+// account get the invitation in the app, and anyone else gets an email with a link to sign up and join. The link's
+// token is only stored hashed.
+package invitations
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/mailer"
+	"checklist-api/models"
+	"checklist-api/notifications"
+	"checklist-api/tokens"
+)
+
+// TokenPrefix starts every invitation token, so they can be told apart from other tokens.
+const TokenPrefix = "listo_invite_"
+
+// Expiry is how long an invitation can be accepted for, from when it was last sent.
+const Expiry = 14 * 24 * time.Hour
+
+var (
+	// ErrInvalidEmail is returned when the address to invite isn't a plain email address.
+	ErrInvalidEmail = errors.New("email must be a valid email address")
+	// ErrInvalidRole is returned when the role to invite with isn't one collaborators can have.
+	ErrInvalidRole = fmt.Errorf("role must be %q or %q", models.RoleEditor, models.RoleViewer)
+	// ErrInviteSelf is returned when the owner of a checklist is invited to it.
+	ErrInviteSelf = errors.New("you can't invite yourself to your own checklist")
+	// ErrAlreadyCollaborator is returned when the invitee already collaborates on the checklist.
+	ErrAlreadyCollaborator = errors.New("user already collaborates on this checklist")
+	// ErrAlreadyInvited is returned when the address already has a pending invitation to the checklist.
+	ErrAlreadyInvited = errors.New("email already has a pending invitation to this checklist, resend it instead")
+	// ErrEmailsOff is returned when someone without an account is invited, but emails can't be sent.
+	ErrEmailsOff = errors.New("emails are off, so only people with an account can be invited")
+	// ErrChecklistGone is returned when an invitation is accepted after its checklist was deleted.
+	ErrChecklistGone = errors.New("checklist does not exist")
+)
+
+// ParseRole reads the role to invite with. It defaults to editor.
+func ParseRole(role string) (models.Role, error) {
+	switch models.Role(role) {
+	case "", models.RoleEditor:
+		return models.RoleEditor, nil
+	case models.RoleViewer:
+		return models.RoleViewer, nil
+	default:
+		return "", ErrInvalidRole
+	}
+}
+
+// ParseEmail reads the address to invite, which must be a bare address without a display name. It is returned in
+// lower case, like users' emails are stored.
+func ParseEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(email), nil
+}
+
+// NewToken generates the token for an invitation's join link, and the hash it should be stored under.
+// Invitations are looked up by their token's tokens.Hash.
+func NewToken() (string, string, error) {
+	return tokens.New(TokenPrefix)
+}
+
+// JoinURL is the web app page where an email invitation's token is accepted, after signing up or logging in.
+func JoinURL(token string) string {
+	return mailer.AppURL() + "/invitations/accept?token=" + token
+}
+
+// Invite invites an email address to collaborate on a checklist with the given role, and sends the invitation.
+// Invitations come from the checklist's owner. If it can't be sent, it isn't kept, so it can be tried again.
+func Invite(service *db.DynamoDBService, ownerID string, checklist models.Checklist, email string, role models.Role) (models.Invitation, error) {
+	owner, err := service.GetUser(ownerID)
+	if err != nil {
+		return models.Invitation{}, err
+	} else if strings.EqualFold(email, owner.Email) {
+		return models.Invitation{}, ErrInviteSelf
+	}
+
+	pending, err := service.GetChecklistInvitations(checklist.ID)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+	for _, invitation := range pending {
+		if strings.EqualFold(invitation.Email, email) {
+			return models.Invitation{}, ErrAlreadyInvited
+		}
+	}
+
+	invitation := models.Invitation{
+		ID:             uuid.New().String(),
+		ChecklistID:    checklist.ID,
+		ChecklistTitle: checklist.Title,
+		OwnerID:        ownerID,
+		Email:          email,
+		Role:           role,
+		InvitedBy:      models.Collaborator{Email: owner.Email, Picture: owner.Picture},
+		CreatedAt:      time.Now().Format(time.RFC3339),
+	}
+
+	token, err := address(service, &invitation)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	if err := service.CreateInvitation(invitation); err != nil {
+		return models.Invitation{}, err
+	}
+
+	// an invitation that never arrived would only stop the address being invited again, so it is taken back
+	if err := deliver(invitation, token); err != nil {
+		return models.Invitation{}, errors.Join(err, service.DeleteInvitation(invitation.ID))
+	}
+
+	return invitation, nil
+}
+
+// Resend sends an invitation again, and restarts its expiry. An emailed invitation gets a new token, so the link in
+// the previous email stops working, and if the invitee has signed up since, it is delivered in the app instead. If it
+// can't be sent, it is left as it was.
+func Resend(service *db.DynamoDBService, invitation models.Invitation) (models.Invitation, error) {
+	previous := invitation
+
+	token, err := address(service, &invitation)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	if err := service.UpdateInvitation(invitation); err != nil {
+		return models.Invitation{}, err
+	}
+
+	// if it can't be sent, the invitation goes back to how it was, so the link that was already sent keeps working
+	if err := deliver(invitation, token); err != nil {
+		return models.Invitation{}, errors.Join(err, service.UpdateInvitation(previous))
+	}
+
+	return invitation, nil
+}
+
+// address works out how to deliver an invitation, and stamps it as sent now. It returns the join link's token for
+// invitations delivered by email.
+func address(service *db.DynamoDBService, invitation *models.Invitation) (string, error) {
+	inviteeID, err := service.GetUserIDByEmail(invitation.Email)
+	if err != nil {
+		return "", err
+	}
+
+	token := ""
+	if inviteeID != "" {
+		if inviteeID == invitation.OwnerID {
+			return "", ErrInviteSelf
+		}
+
+		_, role, err := service.GetCollaboratorRole(inviteeID, invitation.ChecklistID)
+		if err != nil {
+			return "", err
+		} else if role != "" {
+			return "", ErrAlreadyCollaborator
+		}
+
+		invitation.Delivery = models.InvitationInApp
+		invitation.InviteeID = inviteeID
+		invitation.TokenHash = ""
+	} else {
+		if !mailer.Enabled() || mailer.AppURL() == "" {
+			return "", ErrEmailsOff
+		}
+
+		token, invitation.TokenHash, err = NewToken()
+		if err != nil {
+			return "", err
+		}
+		invitation.Delivery = models.InvitationEmail
+		invitation.InviteeID = ""
+	}
+
+	now := time.Now()
+	invitation.SentAt = now.Format(time.RFC3339)
+	invitation.ExpiresAt = now.Add(Expiry).Format(time.RFC3339)
+
+	return token, nil
+}
+
+// invitationEmail is the data of the invitation email.
+type invitationEmail struct {
+	Invitation models.Invitation
+	URL        string
+	Expires    string
+}
+
+// deliver sends an invitation to the invitee, as a notification in the app or by email.
+func deliver(invitation models.Invitation, token string) error {
+	if invitation.Delivery == models.InvitationInApp {
+		_, err := notifications.Notify(invitation.InviteeID, models.Notification{
+			Type:           models.NotificationInvitationReceived,
+			ChecklistID:    invitation.ChecklistID,
+			ChecklistTitle: invitation.ChecklistTitle,
+			Actor:          invitation.InvitedBy,
+		})
+		return err
+	}
+
+	expiresAt, _ := time.Parse(time.RFC3339, invitation.ExpiresAt)
+
+	return mailer.Queue(invitation.Email, "invitation", invitationEmail{
+		Invitation: invitation,
+		URL:        JoinURL(token),
+		Expires:    expiresAt.Format("January 2, 2006"),
+	})
+}
+
+// Accept adds a user to the checklist an invitation is for, with the invitation's role, and deletes the invitation.
+// It returns the checklist. The caller checks that the user may accept the invitation.
+func Accept(service *db.DynamoDBService, invitation models.Invitation, userID string) (models.Checklist, error) {
+	if userID == invitation.OwnerID {
+		return models.Checklist{}, ErrInviteSelf
+	}
+
+	checklist, err := service.GetChecklist(invitation.OwnerID, invitation.ChecklistID)
+	if err != nil {
+		return models.Checklist{}, err
+	} else if checklist.ID == "" {
+		return models.Checklist{}, ErrChecklistGone
+	}
+
+	_, role, err := service.GetCollaboratorRole(userID, invitation.ChecklistID)
+	if err != nil {
+		return models.Checklist{}, err
+	} else if role != "" {
+		// joining with a share code doesn't clear invitations, so one can be left over
+		if err := service.DeleteInvitation(invitation.ID); err != nil {
+			return models.Checklist{}, err
+		}
+		return models.Checklist{}, ErrAlreadyCollaborator
+	}
+
+	if err := service.AddCollaborator(invitation.OwnerID, invitation.ChecklistID, userID, invitation.Role); err != nil {
+		return models.Checklist{}, err
+	}
+
+	return checklist, service.DeleteInvitation(invitation.ID)
+}
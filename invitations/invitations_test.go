@@ -0,0 +1,235 @@
+// Package invitations invites people to collaborate on checklists by email address.
+package invitations
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/db/migrate"
+	"checklist-api/models"
+	"checklist-api/tokens"
+)
+
+func TestParseRole(t *testing.T) {
+	for role, expected := range map[string]models.Role{"": models.RoleEditor, "editor": models.RoleEditor, "viewer": models.RoleViewer} {
+		if parsed, err := ParseRole(role); err != nil || parsed != expected {
+			t.Fatalf("Expected %q to be read as %s, but got %s and %v", role, expected, parsed, err)
+		}
+	}
+
+	for _, role := range []string{"owner", "admin", "Viewer"} {
+		if _, err := ParseRole(role); !errors.Is(err, ErrInvalidRole) {
+			t.Fatalf("Expected %q to be rejected, but got %v", role, err)
+		}
+	}
+}
+
+func TestParseEmail(t *testing.T) {
+	if email, err := ParseEmail(" alice@example.com "); err != nil || email != "alice@example.com" {
+		t.Fatalf("Expected the address trimmed, but got %q and %v", email, err)
+	}
+	if email, err := ParseEmail("Alice@Example.COM"); err != nil || email != "alice@example.com" {
+		t.Fatalf("Expected the address in lower case, but got %q and %v", email, err)
+	}
+
+	for _, email := range []string{"", "alice", "Alice <alice@example.com>", "alice@example.com, bob@example.com"} {
+		if _, err := ParseEmail(email); !errors.Is(err, ErrInvalidEmail) {
+			t.Fatalf("Expected %q to be rejected, but got %v", email, err)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	if !strings.HasPrefix(token, TokenPrefix) {
+		t.Fatalf("Expected the token to start with %s, but got %s", TokenPrefix, token)
+	}
+	if hash != tokens.Hash(token) {
+		t.Fatal("Expected the token's hash to be returned")
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	t.Setenv("APP_URL", "https://listo.example.com/")
+
+	if url := JoinURL("listo_invite_abc"); url != "https://listo.example.com/invitations/accept?token=listo_invite_abc" {
+		t.Fatalf("Unexpected join URL %q", url)
+	}
+}
+
+// localService connects to the local DynamoDB that docker-compose starts, and sets up its tables. Like the sharing
+// tests with Redis, these tests need it running, and they are skipped without it.
+func localService(t *testing.T) *db.DynamoDBService {
+	t.Helper()
+
+	conn, err := net.DialTimeout("tcp", "localhost:8000", time.Second)
+	if err != nil {
+		t.Skip("Local DynamoDB isn't running, start it with docker-compose up")
+	}
+	conn.Close()
+
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "local")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+
+	if err := migrate.RunMigrations(); err != nil {
+		t.Fatalf("Failed to set up tables: %v", err)
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		t.Fatalf("Failed to set up DynamoDBService: %v", err)
+	}
+
+	return service
+}
+
+// testUser stores a user with a unique email, and deletes them after the test.
+func testUser(t *testing.T, service *db.DynamoDBService, name string) (string, string) {
+	t.Helper()
+
+	userID := name + "-" + uuid.New().String()
+	email := userID + "@example.com"
+	if err := service.CreateUser(userID, email, ""); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() { service.DeleteUser(userID) })
+
+	return userID, email
+}
+
+// testChecklist stores a checklist for the owner, and deletes it, with its invitations, after the test.
+func testChecklist(t *testing.T, service *db.DynamoDBService, ownerID string) models.Checklist {
+	t.Helper()
+
+	now := time.Now().Format(time.RFC3339)
+	checklist := models.Checklist{ID: uuid.New().String(), Title: "Groceries", CreatedAt: now, UpdatedAt: now}
+	if err := service.CreateChecklist(ownerID, &checklist); err != nil {
+		t.Fatalf("Failed to create checklist: %v", err)
+	}
+	t.Cleanup(func() { service.DeleteChecklist(ownerID, checklist.ID) })
+
+	return checklist
+}
+
+func TestInviteRules(t *testing.T) {
+	service := localService(t)
+	ownerID, ownerEmail := testUser(t, service, "alice")
+	bobID, bobEmail := testUser(t, service, "bob")
+	carolID, carolEmail := testUser(t, service, "carol")
+	checklist := testChecklist(t, service, ownerID)
+
+	if _, err := Invite(service, ownerID, checklist, strings.ToUpper(ownerEmail), models.RoleEditor); !errors.Is(err, ErrInviteSelf) {
+		t.Fatalf("Expected inviting yourself to be refused, but got %v", err)
+	}
+
+	invitation, err := Invite(service, ownerID, checklist, bobEmail, models.RoleViewer)
+	if err != nil {
+		t.Fatalf("Failed to invite: %v", err)
+	} else if invitation.Delivery != models.InvitationInApp || invitation.InviteeID != bobID {
+		t.Fatalf("Expected an existing user to be invited in the app, but got %+v", invitation)
+	}
+
+	if _, err := Invite(service, ownerID, checklist, strings.ToUpper(bobEmail), models.RoleEditor); !errors.Is(err, ErrAlreadyInvited) {
+		t.Fatalf("Expected a second invitation to the same address to be refused, but got %v", err)
+	}
+
+	if err := service.AddCollaborator(ownerID, checklist.ID, carolID, models.RoleEditor); err != nil {
+		t.Fatalf("Failed to add collaborator: %v", err)
+	}
+	if _, err := Invite(service, ownerID, checklist, carolEmail, models.RoleEditor); !errors.Is(err, ErrAlreadyCollaborator) {
+		t.Fatalf("Expected inviting a collaborator to be refused, but got %v", err)
+	}
+
+	if _, err := Invite(service, ownerID, checklist, "nobody-"+uuid.New().String()+"@example.com", models.RoleEditor); !errors.Is(err, ErrEmailsOff) {
+		t.Fatalf("Expected inviting someone without an account to need emails, but got %v", err)
+	}
+
+	pending, err := service.GetChecklistInvitations(checklist.ID)
+	if err != nil {
+		t.Fatalf("Failed to get invitations: %v", err)
+	} else if len(pending) != 1 || pending[0].ID != invitation.ID {
+		t.Fatalf("Expected only the invitation that was sent to be kept, but got %+v", pending)
+	}
+}
+
+func TestResendRules(t *testing.T) {
+	service := localService(t)
+	ownerID, _ := testUser(t, service, "alice")
+	bobID, bobEmail := testUser(t, service, "bob")
+	checklist := testChecklist(t, service, ownerID)
+
+	invitation, err := Invite(service, ownerID, checklist, bobEmail, models.RoleEditor)
+	if err != nil {
+		t.Fatalf("Failed to invite: %v", err)
+	}
+
+	resent, err := Resend(service, invitation)
+	if err != nil {
+		t.Fatalf("Failed to resend: %v", err)
+	} else if resent.ID != invitation.ID || resent.ExpiresAt < invitation.ExpiresAt {
+		t.Fatalf("Expected the same invitation with its expiry restarted, but got %+v", resent)
+	}
+
+	if err := service.AddCollaborator(ownerID, checklist.ID, bobID, models.RoleEditor); err != nil {
+		t.Fatalf("Failed to add collaborator: %v", err)
+	}
+	if _, err := Resend(service, invitation); !errors.Is(err, ErrAlreadyCollaborator) {
+		t.Fatalf("Expected resending to someone who joined since to be refused, but got %v", err)
+	}
+
+	if err := service.DeleteInvitation(invitation.ID); err != nil {
+		t.Fatalf("Failed to revoke invitation: %v", err)
+	}
+	if err := service.RemoveCollaborator(bobID, checklist.ID); err != nil {
+		t.Fatalf("Failed to remove collaborator: %v", err)
+	}
+	if _, err := Resend(service, invitation); !errors.Is(err, db.ErrInvitationNotFound) {
+		t.Fatalf("Expected resending a revoked invitation to fail, but got %v", err)
+	}
+}
+
+func TestAcceptRules(t *testing.T) {
+	service := localService(t)
+	ownerID, _ := testUser(t, service, "alice")
+	bobID, bobEmail := testUser(t, service, "bob")
+	checklist := testChecklist(t, service, ownerID)
+
+	invitation, err := Invite(service, ownerID, checklist, bobEmail, models.RoleViewer)
+	if err != nil {
+		t.Fatalf("Failed to invite: %v", err)
+	}
+
+	if _, err := Accept(service, invitation, ownerID); !errors.Is(err, ErrInviteSelf) {
+		t.Fatalf("Expected the owner to be refused, but got %v", err)
+	}
+
+	accepted, err := Accept(service, invitation, bobID)
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	} else if accepted.ID != checklist.ID {
+		t.Fatalf("Expected the checklist %s, but got %s", checklist.ID, accepted.ID)
+	}
+
+	if _, role, err := service.GetCollaboratorRole(bobID, checklist.ID); err != nil || role != models.RoleViewer {
+		t.Fatalf("Expected the invitation's role to be applied, but got %q and %v", role, err)
+	}
+	if stored, err := service.GetInvitation(invitation.ID); err != nil || stored.ID != "" {
+		t.Fatalf("Expected the accepted invitation to be deleted, but got %+v and %v", stored, err)
+	}
+
+	if _, err := Accept(service, invitation, bobID); !errors.Is(err, ErrAlreadyCollaborator) {
+		t.Fatalf("Expected accepting again to be refused, but got %v", err)
+	}
+}
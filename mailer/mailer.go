@@ -0,0 +1,231 @@
+// Package mailer sends the app's emails over SMTP. Emails are rendered from templates when they are queued, and wait
+// in the MailOutbox table until a worker sends them, so sends survive restarts and are retried when SMTP fails.
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Templates are the emails that can be sent. Each has a .txt file that defines its "subject" and plain text "body",
+// and a .html file that defines its HTML "body". Both are wrapped in the layout of their kind, and can replace its
+// "footer".
+var Templates = []string{"checklist_completed", "digest", "invitation"}
+
+// smtpTimeout is how long talking to the SMTP server can take, for each email.
+const smtpTimeout = 30 * time.Second
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var textTemplates, htmlTemplates = mustLoadTemplates()
+
+// mustLoadTemplates parses every email's templates. It panics if one is invalid, since that is a mistake in the build.
+func mustLoadTemplates() (map[string]*texttemplate.Template, map[string]*htmltemplate.Template) {
+	texts := map[string]*texttemplate.Template{}
+	htmls := map[string]*htmltemplate.Template{}
+
+	for _, name := range Templates {
+		texts[name] = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/layout.txt", "templates/"+name+".txt"))
+		htmls[name] = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html"))
+	}
+
+	return texts, htmls
+}
+
+// Config is how to reach the SMTP server, and who emails are from.
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// ConfigFromEnv reads the SMTP configuration. The port defaults to 587, and the sender to no-reply@ the host.
+func ConfigFromEnv() Config {
+	config := Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	if config.From == "" {
+		config.From = "Listo <no-reply@" + config.Host + ">"
+	}
+
+	return config
+}
+
+// Enabled reports whether an SMTP server is configured. Without one, no emails are queued.
+func Enabled() bool {
+	return os.Getenv("SMTP_HOST") != ""
+}
+
+// AppURL is the address of the web app, which emails link to. It is empty if APP_URL isn't set, and then emails
+// have no links.
+func AppURL() string {
+	return strings.TrimSuffix(os.Getenv("APP_URL"), "/")
+}
+
+// Message is a rendered email.
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// templateData is what the templates are executed with. Data is the email's own data.
+type templateData struct {
+	AppURL string
+	Data   interface{}
+}
+
+// Render renders one of the Templates with the given data.
+func Render(name string, data interface{}) (Message, error) {
+	textTemplate, ok := textTemplates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+	htmlTemplate := htmlTemplates[name]
+
+	wrapped := templateData{AppURL: AppURL(), Data: data}
+
+	var subject, text, html bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", wrapped); err != nil {
+		return Message{}, fmt.Errorf("failed to render subject, %v", err)
+	}
+	if err := textTemplate.ExecuteTemplate(&text, "layout", wrapped); err != nil {
+		return Message{}, fmt.Errorf("failed to render text, %v", err)
+	}
+	if err := htmlTemplate.ExecuteTemplate(&html, "layout", wrapped); err != nil {
+		return Message{}, fmt.Errorf("failed to render HTML, %v", err)
+	}
+
+	return Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// buildMessage builds the raw email, with both the text and HTML versions of the message as alternatives.
+func buildMessage(from string, to string, messageID string, date time.Time, message Message) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var raw bytes.Buffer
+	headers := [][2]string{
+		{"From", from},
+		{"To", to},
+		// headers can't hold line breaks, and encoding the subject takes care of them along with non-ASCII text
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&raw, "%s: %s\r\n", header[0], header[1])
+	}
+	raw.WriteString("\r\n")
+	raw.Write(body.Bytes())
+
+	return raw.Bytes(), nil
+}
+
+// send delivers a raw email over SMTP. The connection is upgraded with STARTTLS when the server offers it, which
+// local stand-ins like MailHog don't, and authenticates only when a username is configured.
+func send(config Config, to string, raw []byte) error {
+	fromAddress, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAIL_FROM, %v", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(config.Host, config.Port), smtpTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: config.Host}); err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(fromAddress.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(raw); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
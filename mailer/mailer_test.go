@@ -0,0 +1,152 @@
+// Package mailer sends the app's emails over SMTP. Emails are rendered from templates when they are queued, and wait
+// in the MailOutbox table until a worker sends them, so sends survive restarts and are retried when SMTP fails.
+package mailer
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	t.Setenv("APP_URL", "https://listo.example.com/")
+
+	message, err := Render("checklist_completed", map[string]interface{}{
+		"ChecklistTitle": "Groceries <weekly>",
+		"Actor":          map[string]string{"Email": "bob@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if message.Subject != `"Groceries <weekly>" is complete` {
+		t.Fatalf("Unexpected subject %q", message.Subject)
+	}
+	if !strings.Contains(message.Text, "bob@example.com checked the last item") {
+		t.Fatalf("Expected the text to name the collaborator, but got %q", message.Text)
+	}
+	if !strings.Contains(message.Text, "https://listo.example.com") {
+		t.Fatalf("Expected the text to link to the app, but got %q", message.Text)
+	}
+	if strings.Contains(message.HTML, "<weekly>") || !strings.Contains(message.HTML, "&lt;weekly&gt;") {
+		t.Fatalf("Expected the HTML to escape the title, but got %q", message.HTML)
+	}
+
+	if _, err := Render("missing", nil); err == nil {
+		t.Fatal("Expected an unknown template to be rejected")
+	}
+}
+
+func TestRenderEveryTemplate(t *testing.T) {
+	data := map[string]interface{}{
+		"ChecklistTitle": "Groceries",
+		"Actor":          map[string]string{"Email": "bob@example.com"},
+		"Date":           "2024-05-01",
+		"Checklists": []map[string]interface{}{
+			{"Title": "Groceries", "Added": 2, "Changed": 0, "Checked": 1, "Total": 3},
+		},
+		"Invitation": map[string]interface{}{
+			"ChecklistTitle": "Groceries",
+			"Email":          "carol@example.com",
+			"Role":           "viewer",
+			"InvitedBy":      map[string]string{"Email": "alice@example.com"},
+		},
+		"URL":     "https://listo.example.com/invitations/accept?token=listo_invite_abc",
+		"Expires": "May 15, 2024",
+	}
+
+	for _, name := range Templates {
+		message, err := Render(name, data)
+		if err != nil {
+			t.Fatalf("Expected %s to render, but got %v", name, err)
+		}
+		if message.Subject == "" || message.Text == "" || message.HTML == "" {
+			t.Fatalf("Expected %s to have a subject, text and HTML, but got %+v", name, message)
+		}
+	}
+}
+
+func TestRenderInvitation(t *testing.T) {
+	message, err := Render("invitation", map[string]interface{}{
+		"Invitation": map[string]interface{}{
+			"ChecklistTitle": "Groceries",
+			"Email":          "carol@example.com",
+			"Role":           "editor",
+			"InvitedBy":      map[string]string{"Email": "alice@example.com"},
+		},
+		"URL":     "https://listo.example.com/invitations/accept?token=listo_invite_abc",
+		"Expires": "May 15, 2024",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	for _, content := range []string{message.Text, message.HTML} {
+		if strings.Contains(content, "notification settings") || !strings.Contains(content, "alice@example.com invited carol@example.com") {
+			t.Fatalf("Expected the invitation's own footer, but got %q", content)
+		}
+		if !strings.Contains(content, "listo_invite_abc") {
+			t.Fatalf("Expected the join link, but got %q", content)
+		}
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	date := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	raw, err := buildMessage("Listo <no-reply@example.com>", "alice@example.com", "<1@example.com>", date, Message{
+		Subject: "Café\r\nBcc: eve@example.com",
+		Text:    "Everything is done.\n",
+		HTML:    "<p>Everything is done.</p>",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("Expected a valid message, but got %v", err)
+	}
+	if message.Header.Get("Bcc") != "" {
+		t.Fatal("Expected the subject not to inject headers")
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != "Café\r\nBcc: eve@example.com" {
+		t.Fatalf("Expected the subject to round trip, but got %q, %v", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected a multipart/alternative message, but got %q, %v", mediaType, err)
+	}
+
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	expected := []struct{ contentType, content string }{
+		// quoted-printable text has CRLF line breaks, as email requires
+		{"text/plain; charset=utf-8", "Everything is done.\r\n"},
+		{"text/html; charset=utf-8", "<p>Everything is done.</p>"},
+	}
+	for _, want := range expected {
+		part, err := parts.NextRawPart()
+		if err != nil {
+			t.Fatalf("Expected a %s part, but got %v", want.contentType, err)
+		}
+		if part.Header.Get("Content-Type") != want.contentType {
+			t.Fatalf("Expected a %s part, but got %s", want.contentType, part.Header.Get("Content-Type"))
+		}
+		content, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil || string(content) != want.content {
+			t.Fatalf("Expected %q, but got %q, %v", want.content, content, err)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if retryDelay(1) != time.Minute || retryDelay(4) != 8*time.Minute {
+		t.Fatalf("Expected the delay to double from a minute, but got %v and %v", retryDelay(1), retryDelay(4))
+	}
+}
@@ -0,0 +1,127 @@
+// Package mailer sends the app's emails over SMTP. Emails are rendered from templates when they are queued, and wait
+// in the MailOutbox table until a worker sends them, so sends survive restarts and are retried when SMTP fails.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+const (
+	// maxAttempts is how many times an email is tried before it fails. Retries back off from a minute, so an SMTP
+	// server has about two hours to come back before its emails are given up on.
+	maxAttempts = 8
+	// firstRetryDelay is the wait before the first retry, which doubles with each one after.
+	firstRetryDelay = time.Minute
+	// emailTTL is how long sent and failed emails are kept in the outbox.
+	emailTTL = 7 * 24 * time.Hour
+	// claimTimeout is how long an attempt has before another instance may try the email again.
+	claimTimeout = 2 * time.Minute
+	// workerInterval is how often the outbox is checked for due emails.
+	workerInterval = 5 * time.Second
+	// emailsPerPoll is the most emails sent at once by each instance.
+	emailsPerPoll = 20
+)
+
+// Queue renders one of the Templates for a recipient, and adds it to the outbox to be sent.
+func Queue(to string, name string, data interface{}) error {
+	if _, err := mail.ParseAddress(to); err != nil || strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", to)
+	}
+
+	message, err := Render(name, data)
+	if err != nil {
+		return err
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return service.QueueEmail(models.Email{
+		ID:        uuid.New().String(),
+		To:        to,
+		Subject:   message.Subject,
+		Text:      message.Text,
+		HTML:      message.HTML,
+		Status:    models.EmailPending,
+		CreatedAt: now.Format(time.RFC3339),
+	}, now)
+}
+
+// retryDelay is the wait before retrying an email that has failed the given number of attempts.
+func retryDelay(attempts int) time.Duration {
+	return firstRetryDelay << (attempts - 1)
+}
+
+// RunWorker sends the emails waiting in the outbox, retrying failed ones with backoff, until the context is done.
+// Every instance can run it, since each email is claimed before it is sent.
+func RunWorker(ctx context.Context) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		fmt.Println("Emails are off: " + err.Error())
+		return
+	}
+
+	config := ConfigFromEnv()
+
+	ticker := time.NewTicker(workerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		emails, err := service.ClaimDueEmails(now, now.Add(claimTimeout), emailsPerPoll)
+		if err != nil {
+			fmt.Println("Error claiming emails: " + err.Error())
+			continue
+		}
+
+		for _, email := range emails {
+			if err := attempt(service, config, email); err != nil {
+				fmt.Printf("Error recording attempt of email %s: %v\n", email.ID, err)
+			}
+		}
+	}
+}
+
+// attempt sends a claimed email, and records the outcome. A failed attempt is retried later, unless the email has
+// run out of attempts.
+func attempt(service *db.DynamoDBService, config Config, email models.Email) error {
+	messageID := "<" + email.ID + "@" + config.Host + ">"
+
+	raw, err := buildMessage(config.From, email.To, messageID, time.Now(), Message{
+		Subject: email.Subject,
+		Text:    email.Text,
+		HTML:    email.HTML,
+	})
+	if err == nil {
+		err = send(config, email.To, raw)
+	}
+
+	expiresAt := time.Now().Add(emailTTL)
+	switch {
+	case err == nil:
+		return service.FinishEmail(email.ID, models.EmailSent, "", expiresAt)
+	case email.Attempts >= maxAttempts:
+		fmt.Printf("Email %s failed after %d attempts: %v\n", email.ID, email.Attempts, err)
+		return service.FinishEmail(email.ID, models.EmailFailed, err.Error(), expiresAt)
+	default:
+		return service.RetryEmail(email.ID, err.Error(), time.Now().Add(retryDelay(email.Attempts)))
+	}
+}
@@ -2,9 +2,19 @@
 package main
 
 import (
+	"checklist-api/accounts"
 	"checklist-api/db/migrate"
+	"checklist-api/events"
+	"checklist-api/mailer"
 	"checklist-api/middleware"
+	"checklist-api/models"
+	"checklist-api/notifications"
+	"checklist-api/realtime"
 	"checklist-api/routehandlers"
+	"checklist-api/streams"
+	"checklist-api/webhooks"
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
@@ -24,6 +34,42 @@ func main() {
 		panic(err)
 	}
 
+	// Delete the accounts whose grace period has passed, and resume failed deletions
+	if _, err := accounts.DeletionGracePeriod(); err != nil {
+		panic(err)
+	}
+	go accounts.RunDeletionWorker(context.Background())
+
+	// Fail the background exports that were interrupted, like by a restart, instead of leaving them pending
+	go accounts.RunExportSweeper(context.Background())
+
+	// Send the emails waiting in the outbox, and the daily digests
+	if mailer.Enabled() {
+		go mailer.RunWorker(context.Background())
+		go notifications.RunDigestWorker(context.Background())
+	} else {
+		fmt.Println("Emails are off, SMTP_HOST is not set")
+	}
+
+	// Push checklist changes to the clients watching them, on every API instance
+	sink, err := realtime.NewRedisSink()
+	if err != nil {
+		fmt.Println("Realtime updates are off, Redis is unavailable: " + err.Error())
+	} else {
+		events.Register(sink)
+	}
+	feedSink, err := realtime.NewFeedSink()
+	if err != nil {
+		fmt.Println("Event feeds are off, Redis is unavailable: " + err.Error())
+	} else {
+		events.Register(feedSink)
+	}
+
+	// Read the tables' change streams for webhooks, and optionally take realtime updates from them instead of the handlers
+	if streams.Enabled() {
+		startStreams()
+	}
+
 	r := gin.Default()
 
 	// health check
@@ -34,39 +80,127 @@ func main() {
 	})
 
 	r.Use(middleware.CORSMiddleware())
+
+	// Public links, readable without logging in
+	r.GET("/public/:token", routehandlers.GetPublicChecklist)
+
+	// Ingest URLs, which add items to a checklist without logging in
+	r.POST("/ingest/:secret", routehandlers.PostIngest)
+
+	// Account exports, downloaded through signed, time-limited links
+	r.GET("/exports/:exportID", routehandlers.GetExportDownload)
+
+	// Local token issuer, only in the development auth mode
+	if middleware.DevAuthEnabled() {
+		r.GET("/dev/.well-known/jwks.json", routehandlers.GetDevJWKS)
+		r.POST("/dev/token", routehandlers.PostDevToken)
+	}
+
 	r.Use(middleware.AuthMiddleware())
 
+	// Every route declares the token scopes it needs, so read-only tokens can be issued to dashboards
+	readScope := middleware.RequireScopes(models.ScopeChecklistsRead)
+	writeScope := middleware.RequireScopes(models.ScopeChecklistsWrite)
+	shareScope := middleware.RequireScopes(models.ScopeChecklistsShare)
+
+	// Checklist routes work for owned and shared checklists alike, with the caller's access checked per route
+	read := middleware.ChecklistAccess(models.ActionRead)
+	write := middleware.ChecklistAccess(models.ActionWrite)
+	duplicate := middleware.ChecklistAccess(models.ActionCopy)
+	share := middleware.ChecklistAccess(models.ActionShare)
+	manage := middleware.ChecklistAccess(models.ActionManage)
+
 	// Checklists
-	r.GET("/checklists", routehandlers.GetChecklists)
-	r.GET("/checklist/:id", routehandlers.GetChecklist)
-	r.PUT("/checklist/:id", routehandlers.PutChecklist)
-	r.POST("/checklist", routehandlers.PostChecklist)
-	r.DELETE("/checklist/:id", routehandlers.DeleteChecklist)
+	r.GET("/checklists", readScope, routehandlers.GetChecklists)
+	r.GET("/checklist/:id", readScope, read, routehandlers.GetChecklist)
+	r.PUT("/checklist/:id", writeScope, write, routehandlers.PutChecklist)
+	r.POST("/checklist", writeScope, routehandlers.PostChecklist)
+	r.DELETE("/checklist/:id", writeScope, manage, routehandlers.DeleteChecklist)
+	r.POST("/checklist/:id/transfer", shareScope, manage, routehandlers.PostChecklistTransfer)
+	r.POST("/checklist/:id/duplicate", writeScope, duplicate, routehandlers.PostDuplicateChecklist)
 
 	// Items
-	r.POST("/checklist/:id/item", routehandlers.PostItem)
-	r.PUT("/checklist/:id/items", routehandlers.PutAllItems)
-	r.PUT("/checklist/:id/item/:itemID", routehandlers.PutItem)
-	r.DELETE("/checklist/:id/item/:itemID", routehandlers.DeleteItem)
+	r.POST("/checklist/:id/item", writeScope, write, routehandlers.PostItem)
+	r.PUT("/checklist/:id/items", writeScope, write, routehandlers.PutAllItems)
+	r.PUT("/checklist/:id/item/:itemID", writeScope, write, routehandlers.PutItem)
+	r.POST("/checklist/:id/item/:itemID/operations", writeScope, write, routehandlers.PostItemOperation)
+	r.DELETE("/checklist/:id/item/:itemID", writeScope, write, routehandlers.DeleteItem)
+
+	// Realtime
+	r.GET("/checklist/:id/events", readScope, read, routehandlers.GetChecklistEvents)
+	r.GET("/events", readScope, routehandlers.GetEvents)
+	r.GET("/checklist/:id/presence", readScope, read, routehandlers.GetPresence)
+	r.POST("/checklist/:id/presence", readScope, read, routehandlers.PostPresence)
+	r.DELETE("/checklist/:id/presence", readScope, read, routehandlers.DeletePresence)
 
 	// Sharing
-	r.GET("/checklist/:id/share", routehandlers.GetShareCode)
-	r.POST("/checklist/share/:code", routehandlers.PostUserToSharedChecklist)
+	r.GET("/checklist/:id/share", shareScope, share, routehandlers.GetShareCode)
+	r.POST("/checklist/share/:code", shareScope, routehandlers.PostUserToSharedChecklist)
+	r.GET("/checklist/:id/public", shareScope, manage, routehandlers.GetPublicLink)
+	r.PUT("/checklist/:id/public", shareScope, manage, routehandlers.PutPublicLink)
+	r.DELETE("/checklist/:id/public", shareScope, manage, routehandlers.DeletePublicLink)
+	r.GET("/checklist/:id/ingest", shareScope, manage, routehandlers.GetIngestURL)
+	r.PUT("/checklist/:id/ingest", shareScope, manage, routehandlers.PutIngestURL)
+	r.DELETE("/checklist/:id/ingest", shareScope, manage, routehandlers.DeleteIngestURL)
+	r.GET("/checklist/:id/invitations", shareScope, share, routehandlers.GetChecklistInvitations)
+	r.POST("/checklist/:id/invitations", shareScope, share, routehandlers.PostChecklistInvitation)
+	r.POST("/checklist/:id/invitations/:invitationID/resend", shareScope, share, routehandlers.PostChecklistInvitationResend)
+	r.DELETE("/checklist/:id/invitations/:invitationID", shareScope, share, routehandlers.DeleteChecklistInvitation)
+
+	// Invitations
+	r.GET("/invitations", readScope, routehandlers.GetInvitations)
+	r.POST("/invitations/accept", shareScope, routehandlers.PostInvitationAcceptToken)
+	r.POST("/invitations/:invitationID/accept", shareScope, routehandlers.PostInvitationAccept)
+	r.DELETE("/invitations/:invitationID", shareScope, routehandlers.DeleteInvitation)
 
 	// Shared Checklists
-	r.GET("/checklists/shared", routehandlers.GetSharedChecklists)
-	r.GET("/checklist/:id/shared", routehandlers.GetSharedChecklist)
-	r.PUT("/checklist/:id/shared", routehandlers.PutSharedChecklist)
-	r.DELETE("/checklist/:id/shared/user", routehandlers.LeaveSharedChecklist)
+	r.GET("/checklists/shared", readScope, routehandlers.GetSharedChecklists)
+	r.DELETE("/checklist/:id/shared/user", shareScope, read, routehandlers.LeaveSharedChecklist)
+
+	// Deprecated shared routes, kept for older clients. They behave exactly like the routes above.
+	r.GET("/checklist/:id/shared", readScope, read, routehandlers.GetChecklist)
+	r.PUT("/checklist/:id/shared", writeScope, write, routehandlers.PutChecklist)
+	r.POST("/checklist/:id/shared/fork", writeScope, duplicate, routehandlers.PostDuplicateChecklist)
+	r.POST("/checklist/:id/shared/item", writeScope, write, routehandlers.PostItem)
+	r.PUT("/checklist/:id/shared/items", writeScope, write, routehandlers.PutAllItems)
+	r.PUT("/checklist/:id/shared/item/:itemID", writeScope, write, routehandlers.PutItem)
+	r.DELETE("/checklist/:id/shared/item/:itemID", writeScope, write, routehandlers.DeleteItem)
 
-	// Shared Items
-	r.POST("/checklist/:id/shared/item", routehandlers.PostSharedItem)
-	r.PUT("/checklist/:id/shared/items", routehandlers.PutAllSharedItems)
-	r.PUT("/checklist/:id/shared/item/:itemID", routehandlers.PutSharedItem)
-	r.DELETE("/checklist/:id/shared/item/:itemID", routehandlers.DeleteSharedItem)
+	// Personal access tokens
+	r.GET("/tokens", readScope, routehandlers.GetAccessTokens)
+	r.POST("/tokens", writeScope, routehandlers.PostAccessToken)
+	r.DELETE("/tokens/:tokenID", writeScope, routehandlers.DeleteAccessToken)
+
+	// Webhooks
+	r.GET("/webhooks", readScope, routehandlers.GetWebhooks)
+	r.POST("/webhooks", writeScope, routehandlers.PostWebhook)
+	r.DELETE("/webhooks/:webhookID", writeScope, routehandlers.DeleteWebhook)
+	r.GET("/webhooks/:webhookID/deliveries", readScope, routehandlers.GetWebhookDeliveries)
+	r.POST("/webhooks/:webhookID/deliveries/:deliveryID/redeliver", writeScope, routehandlers.PostWebhookRedelivery)
+
+	// Notifications
+	r.GET("/notifications", readScope, routehandlers.GetNotifications)
+	r.POST("/notifications/:notificationID/read", writeScope, routehandlers.PostNotificationRead)
+	r.POST("/notifications/read-all", writeScope, routehandlers.PostNotificationsReadAll)
 
 	// Users
-	r.POST("/user", routehandlers.PostUser)
+	r.GET("/me", readScope, routehandlers.GetMe)
+	r.PUT("/me", writeScope, routehandlers.PutMe)
+	r.GET("/me/preferences", readScope, routehandlers.GetMePreferences)
+	r.PATCH("/me/preferences", writeScope, routehandlers.PatchMePreferences)
+	r.POST("/me/quick-add", writeScope, routehandlers.PostQuickAdd)
+	r.POST("/me/onboarding", writeScope, routehandlers.PostMeOnboarding)
+	r.GET("/me/export", readScope, routehandlers.GetMeExport)
+	r.GET("/me/exports/:exportID", readScope, routehandlers.GetMeExportStatus)
+	r.DELETE("/me", writeScope, routehandlers.DeleteMe)
+	r.DELETE("/me/deletion", writeScope, routehandlers.DeleteMeDeletion)
+	r.POST("/user", writeScope, routehandlers.PutMe) // deprecated, kept for older clients
+
+	// Admin
+	adminScope := middleware.RequireScopes(models.ScopeAdmin)
+	r.GET("/admin/users/:id", adminScope, routehandlers.GetAdminUser)
+	r.PUT("/admin/users/:id", adminScope, routehandlers.PutAdminUser)
+	r.DELETE("/admin/users/:id", adminScope, routehandlers.DeleteAdminUser)
 
 	err = r.Run(":80")
 
@@ -74,3 +208,33 @@ func main() {
 		panic(err)
 	}
 }
+
+// startStreams starts reading the tables' change streams in the background.
+func startStreams() {
+	consumer, err := streams.NewConsumer()
+	if err != nil {
+		fmt.Println("Change streams are off: " + err.Error())
+		return
+	}
+
+	if streams.RealtimeFromStreams() {
+		bridge, err := streams.NewEventsBridge()
+		if err != nil {
+			fmt.Println("Realtime updates come from the handlers, the streams bridge failed: " + err.Error())
+		} else {
+			events.SourceFromStreams(streams.StreamedEventTypes...)
+			streams.Register(bridge)
+		}
+	}
+
+	handler, err := webhooks.NewHandler()
+	if err != nil {
+		fmt.Println("Webhooks are off: " + err.Error())
+	} else {
+		streams.Register(handler)
+		go webhooks.RunWorker(context.Background())
+		webhooks.Enable()
+	}
+
+	go consumer.Run(context.Background())
+}
@@ -0,0 +1,52 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+// ChecklistAccess is a middleware that resolves the user's access to the checklist in the :id param,
+// whether they own it or it is shared with them. The owner and the user's role are made available
+// via the gin context as "ownerID" and "role". Users without access get a 404, so checklist IDs can't be probed,
+// and users whose role doesn't allow the action get a 403.
+func ChecklistAccess(action models.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("sub")
+		checklistID := c.Param("id")
+
+		service, err := db.NewDynamoDBService()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Error setting up DynamoDBService: " + err.Error(),
+			})
+			return
+		}
+
+		ownerID, role, err := service.GetChecklistAccess(userID, checklistID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Error checking access to checklist: " + err.Error(),
+			})
+			return
+		} else if ownerID == "" {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"message": "Checklist does not exist",
+			})
+			return
+		} else if !role.Can(action) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "Your role on this checklist does not allow this",
+			})
+			return
+		}
+
+		c.Set("ownerID", ownerID)
+		c.Set("role", role)
+		c.Next()
+	}
+}
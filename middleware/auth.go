@@ -5,103 +5,91 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
 )
 
-var (
-	auth0domain   string
-	auth0audience string
-	jwksURL       string
-)
-
-func initAuth() {
-	auth0domain = os.Getenv("AUTH0_DOMAIN")
-	auth0audience = os.Getenv("AUTH0_AUDIENCE")
-	jwksURL = fmt.Sprintf("https://%s/.well-known/jwks.json", auth0domain)
+// allowedAlgorithms are the signature algorithms accepted on tokens.
+// Symmetric algorithms and "none" are never accepted, since the keys come from a public key set.
+var allowedAlgorithms = map[jwa.SignatureAlgorithm]bool{
+	jwa.RS256: true,
+	jwa.RS384: true,
+	jwa.RS512: true,
+	jwa.PS256: true,
+	jwa.PS384: true,
+	jwa.PS512: true,
+	jwa.ES256: true,
+	jwa.ES384: true,
+	jwa.ES512: true,
+	jwa.EdDSA: true,
 }
 
-func fetchJWKS() (jwk.Set, error) {
-	set, err := jwk.Fetch(context.Background(), jwksURL)
-
+// verifyToken finds the trusted provider that issued the token, and verifies the token with that provider's keys.
+func verifyToken(ctx context.Context, providers map[string]*provider, tokenString string) (*provider, jwt.Token, error) {
+	message, err := jws.ParseString(tokenString)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse JWKS: %w", err)
+		return nil, nil, fmt.Errorf("invalid token")
+	} else if len(message.Signatures()) != 1 {
+		return nil, nil, fmt.Errorf("invalid token")
 	}
 
-	return set, nil
-}
-
-func verifyToken(tokenString string) (*jwt.Token, error) {
-	keySet, err := fetchJWKS()
-	if err != nil {
-		return nil, err
+	headers := message.Signatures()[0].ProtectedHeaders()
+	alg := headers.Algorithm()
+	if !allowedAlgorithms[alg] {
+		return nil, nil, fmt.Errorf("unexpected signing method: %v", alg)
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		kid, found := token.Header["kid"].(string)
-		if !found {
-			return nil, fmt.Errorf("kid not found in token header")
-		}
-
-		key, found := keySet.LookupKeyID(kid)
-		if !found {
-			return nil, fmt.Errorf("key not found in key set")
-		}
-
-		var rawKey interface{}
-		if err := key.Raw(&rawKey); err != nil {
-			return nil, fmt.Errorf("failed to get raw key: %w", err)
-		}
-
-		return rawKey, err
-	})
+	kid := headers.KeyID()
+	if kid == "" {
+		return nil, nil, fmt.Errorf("kid not found in token header")
+	}
 
+	// the issuer is only read here to pick the provider, whose verification checks it again
+	unverified, err := jwt.ParseString(tokenString)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("invalid token")
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		aud, ok := claims["aud"].([]interface{})
-		if !ok {
-			// if aud is not an array, convert it to an array for uniformity
-			aud = []interface{}{claims["aud"]}
-		}
-
-		validAudience := false
-		for _, audience := range aud {
-			if audience == auth0audience {
-				validAudience = true
-				break
-			}
-		}
-
-		if !validAudience {
-			return nil, fmt.Errorf("invalid audience")
-		}
+	p, found := providers[unverified.Issuer()]
+	if !found {
+		return nil, nil, fmt.Errorf("invalid issuer")
+	}
 
-		if claims["iss"] != fmt.Sprintf("https://%s/", auth0domain) {
-			return nil, fmt.Errorf("invalid issuer")
-		}
-	} else {
-		return nil, fmt.Errorf("invalid token")
+	token, err := p.verify(ctx, tokenString, alg, kid)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return token, nil
+	return p, token, nil
 }
 
 // AuthMiddleware is a middleware that checks the Authorization header,
-// validates the claims made, and makes those claims available via the gin context
+// validates the claims made, and makes those claims available via the gin context.
+// Tokens from any of the providers in loadProviderConfigs are accepted, and their signing keys are cached.
+// In the development auth mode, tokens minted by MintDevToken are accepted too.
+// Personal access tokens are accepted alongside JWTs, and set the same "sub" on the context.
+// It panics if the providers are misconfigured, since no request could be authenticated.
 func AuthMiddleware() gin.HandlerFunc {
+	configs, err := loadProviderConfigs()
+	if err != nil {
+		panic(err)
+	}
+	providers := newProviders(configs)
+
+	if DevAuthEnabled() {
+		p, err := devProvider()
+		if err != nil {
+			panic(err)
+		}
+		providers[DevIssuer] = p
+		fmt.Println("Development auth mode is on, tokens from POST /dev/token are accepted")
+	}
+
 	return func(c *gin.Context) {
-		initAuth()
 		authHeader := c.GetHeader("Authorization")
 
 		if authHeader == "" {
@@ -111,20 +99,49 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := verifyToken(tokenString)
+
+		if strings.HasPrefix(tokenString, PersonalAccessTokenPrefix) {
+			userID, scopes, err := verifyPersonalAccessToken(tokenString)
+			if err != nil {
+				fmt.Print("token not verified: ", err.Error())
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.Set("sub", userID)
+			c.Set("scopes", scopes)
+			c.Set("authMethod", "pat")
+			c.Next()
+			return
+		}
+
+		p, token, err := verifyToken(c.Request.Context(), providers, tokenString)
 		if err != nil {
 			fmt.Print("token not verified: ", err.Error())
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		userID, err := p.userID(token)
+		if err != nil {
 			fmt.Print("claims invalid")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			return
+		}
+
+		claims, err := token.AsMap(c.Request.Context())
+		if err != nil {
+			fmt.Print("claims invalid")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			return
 		}
 
-		c.Set("sub", claims["sub"])
+		c.Set("sub", userID)
+		c.Set("scopes", scopesFromClaims(claims))
+		c.Set("claims", claims)
+		c.Set("issuer", p.config.Issuer)
+		c.Set("provider", p)
+		c.Set("authMethod", "jwt")
 		c.Next()
 	}
 }
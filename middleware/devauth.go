@@ -0,0 +1,151 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+const (
+	// DevIssuer is the issuer of tokens minted by the local development token issuer.
+	DevIssuer = "listo-dev"
+	// devAudience is the audience of tokens minted by the local development token issuer.
+	devAudience = "listo-api"
+	// devKeyID is the key ID of the development issuer's signing key.
+	devKeyID = "listo-dev-key"
+	// devTokenLifetime is how long minted development tokens are valid for.
+	devTokenLifetime = 12 * time.Hour
+)
+
+var (
+	devIssuerOnce sync.Once
+	devSigningKey jwk.Key
+	devPublicKeys jwk.Set
+	devIssuerErr  error
+)
+
+// staticKeys is a keySource for a fixed set of keys, that never needs fetching.
+type staticKeys struct {
+	set jwk.Set
+}
+
+func (s staticKeys) lookupKey(_ context.Context, kid string) (jwk.Key, error) {
+	key, found := s.set.LookupKeyID(kid)
+	if !found {
+		return nil, fmt.Errorf("key not found in key set")
+	}
+
+	return key, nil
+}
+
+// DevAuthEnabled reports whether the local development auth mode is on, which is when AUTH_MODE is "dev".
+// It panics if the mode is turned on while ENVIRONMENT is "production", since anyone could mint a token for any user.
+func DevAuthEnabled() bool {
+	if os.Getenv("AUTH_MODE") != "dev" {
+		return false
+	}
+
+	if os.Getenv("ENVIRONMENT") == "production" {
+		panic("AUTH_MODE=dev is refused when ENVIRONMENT is production")
+	}
+
+	return true
+}
+
+// DevJWKS returns the public keys of the development token issuer.
+func DevJWKS() (jwk.Set, error) {
+	if err := initDevIssuer(); err != nil {
+		return nil, err
+	}
+
+	return devPublicKeys, nil
+}
+
+// MintDevToken creates a token for any subject, signed by the development token issuer.
+// The email and picture are included as claims, the same way an identity provider would include them.
+func MintDevToken(subject string, email string, picture string) (string, error) {
+	if err := initDevIssuer(); err != nil {
+		return "", err
+	}
+
+	token := jwt.New()
+	_ = token.Set(jwt.IssuerKey, DevIssuer)
+	_ = token.Set(jwt.AudienceKey, devAudience)
+	_ = token.Set(jwt.SubjectKey, subject)
+	_ = token.Set(jwt.IssuedAtKey, time.Now())
+	_ = token.Set(jwt.ExpirationKey, time.Now().Add(devTokenLifetime))
+	if email != "" {
+		_ = token.Set("email", email)
+	}
+	if picture != "" {
+		_ = token.Set("picture", picture)
+	}
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.KeyIDKey, devKeyID)
+
+	signed, err := jwt.Sign(token, jwa.RS256, devSigningKey, jwt.WithHeaders(headers))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return string(signed), nil
+}
+
+// devProvider returns the provider that verifies tokens minted by the development token issuer.
+func devProvider() (*provider, error) {
+	if err := initDevIssuer(); err != nil {
+		return nil, err
+	}
+
+	return &provider{
+		config: ProviderConfig{
+			Issuer:      DevIssuer,
+			Audience:    devAudience,
+			UserIDClaim: "sub",
+		},
+		keys: staticKeys{set: devPublicKeys},
+	}, nil
+}
+
+// initDevIssuer generates the development issuer's signing key, once per process.
+// Restarting the API invalidates every development token minted before.
+func initDevIssuer() error {
+	devIssuerOnce.Do(func() {
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			devIssuerErr = fmt.Errorf("failed to generate dev signing key: %w", err)
+			return
+		}
+
+		devSigningKey, err = jwk.New(privateKey)
+		if err != nil {
+			devIssuerErr = fmt.Errorf("failed to create dev signing key: %w", err)
+			return
+		}
+
+		publicKey, err := jwk.New(&privateKey.PublicKey)
+		if err != nil {
+			devIssuerErr = fmt.Errorf("failed to create dev public key: %w", err)
+			return
+		}
+		_ = publicKey.Set(jwk.KeyIDKey, devKeyID)
+		_ = publicKey.Set(jwk.AlgorithmKey, jwa.RS256)
+		_ = publicKey.Set(jwk.KeyUsageKey, "sig")
+
+		devPublicKeys = jwk.NewSet()
+		devPublicKeys.Add(publicKey)
+	})
+
+	return devIssuerErr
+}
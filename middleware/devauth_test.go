@@ -0,0 +1,45 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMintDevTokenVerifies(t *testing.T) {
+	p, err := devProvider()
+	if err != nil {
+		t.Fatalf("Failed to create dev provider: %v", err)
+	}
+
+	tokenString, err := MintDevToken("alice", "alice@example.com", "")
+	if err != nil {
+		t.Fatalf("Failed to mint token: %v", err)
+	}
+
+	_, token, err := verifyToken(context.Background(), map[string]*provider{DevIssuer: p}, tokenString)
+	if err != nil {
+		t.Fatalf("Expected dev token to verify, but got: %v", err)
+	}
+
+	if token.Subject() != "alice" {
+		t.Fatalf("Expected subject alice, but got %s", token.Subject())
+	}
+
+	if email, _ := token.Get("email"); email != "alice@example.com" {
+		t.Fatalf("Expected email alice@example.com, but got %v", email)
+	}
+}
+
+func TestDevAuthRefusedInProduction(t *testing.T) {
+	t.Setenv("AUTH_MODE", "dev")
+	t.Setenv("ENVIRONMENT", "production")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected DevAuthEnabled to panic in production")
+		}
+	}()
+
+	DevAuthEnabled()
+}
@@ -0,0 +1,147 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const (
+	// jwksRefreshInterval is how often the key set is refreshed in the background.
+	jwksRefreshInterval = 15 * time.Minute
+	// jwksMinRefetchInterval is the least time between refetches caused by tokens signed with an unknown key.
+	jwksMinRefetchInterval = 30 * time.Second
+	// jwksFetchTimeout bounds a single fetch of the key set.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// jwksCache keeps a JSON Web Key Set in memory, so tokens can be verified without fetching the keys on every request.
+// The keys are refreshed in the background, and refetched early when a token is signed with a key the cache
+// doesn't know yet, which happens right after the provider rotates its keys. If a fetch fails the previous keys
+// keep being served, so a slow or unavailable provider doesn't take the API down with it.
+type jwksCache struct {
+	url                string
+	refreshInterval    time.Duration
+	minRefetchInterval time.Duration
+	client             *http.Client
+
+	mu          sync.RWMutex
+	set         jwk.Set
+	lastAttempt time.Time
+
+	// fetchMu makes sure only one fetch runs at a time
+	fetchMu sync.Mutex
+}
+
+// newJWKSCache creates a cache for the key set at url. Nothing is fetched until the first lookup, or until run is called.
+func newJWKSCache(url string, refreshInterval time.Duration, minRefetchInterval time.Duration) *jwksCache {
+	return &jwksCache{
+		url:                url,
+		refreshInterval:    refreshInterval,
+		minRefetchInterval: minRefetchInterval,
+		client:             &http.Client{Timeout: jwksFetchTimeout},
+	}
+}
+
+// run refreshes the key set every refreshInterval until ctx is done.
+func (j *jwksCache) run(ctx context.Context) {
+	if err := j.refresh(ctx); err != nil {
+		fmt.Println("Error fetching JWKS: ", err.Error())
+	}
+
+	ticker := time.NewTicker(j.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.refresh(ctx); err != nil {
+				fmt.Println("Error refreshing JWKS, serving cached keys: ", err.Error())
+			}
+		}
+	}
+}
+
+// lookupKey returns the key with the given key ID. Unknown key IDs trigger a refetch, at most once per
+// minRefetchInterval, so a flood of tokens with made up key IDs can't turn into a flood of requests to the provider.
+// Lookups that arrive while a fetch is running wait for it, and are answered from the keys it fetched.
+func (j *jwksCache) lookupKey(ctx context.Context, kid string) (jwk.Key, error) {
+	if key, found := j.cachedKey(kid); found {
+		return key, nil
+	}
+
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	if key, found := j.cachedKey(kid); found {
+		return key, nil
+	}
+
+	if !j.refetchAllowed() {
+		return nil, fmt.Errorf("key not found in key set")
+	}
+
+	if err := j.fetch(ctx); err != nil {
+		return nil, err
+	}
+
+	key, found := j.cachedKey(kid)
+	if !found {
+		return nil, fmt.Errorf("key not found in key set")
+	}
+
+	return key, nil
+}
+
+// refresh fetches the key set and replaces the cached one. On failure the cached keys are left in place.
+func (j *jwksCache) refresh(ctx context.Context) error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	return j.fetch(ctx)
+}
+
+// fetch does the work of refresh. The caller must hold fetchMu.
+func (j *jwksCache) fetch(ctx context.Context) error {
+	j.mu.Lock()
+	j.lastAttempt = time.Now()
+	j.mu.Unlock()
+
+	set, err := jwk.Fetch(ctx, j.url, jwk.WithHTTPClient(j.client))
+	if err != nil {
+		return fmt.Errorf("Failed to parse JWKS: %w", err)
+	}
+
+	j.mu.Lock()
+	j.set = set
+	j.mu.Unlock()
+
+	return nil
+}
+
+// cachedKey looks up a key in the cached set, without fetching.
+func (j *jwksCache) cachedKey(kid string) (jwk.Key, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if j.set == nil {
+		return nil, false
+	}
+
+	return j.set.LookupKeyID(kid)
+}
+
+// refetchAllowed reports whether enough time has passed since the last fetch attempt to fetch again on demand.
+func (j *jwksCache) refetchAllowed() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return j.lastAttempt.IsZero() || time.Since(j.lastAttempt) >= j.minRefetchInterval
+}
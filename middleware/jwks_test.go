@@ -0,0 +1,264 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// jwksServer is a local stand-in for an identity provider. It serves its key set, and an OpenID Connect
+// discovery document pointing at it, and can sign tokens with its private keys.
+type jwksServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	set         jwk.Set
+	privateKeys map[string]interface{}
+	failing     bool
+	delay       time.Duration
+	fetches     atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, kids ...string) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{}
+	s.rotate(t, kids...)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":   s.URL + "/",
+				"jwks_uri": s.URL + "/.well-known/jwks.json",
+			})
+			return
+		}
+
+		s.fetches.Add(1)
+
+		s.mu.Lock()
+		delay := s.delay
+		s.mu.Unlock()
+		time.Sleep(delay)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.set)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// rotate replaces the served keys with new RSA keys with the given IDs.
+func (s *jwksServer) rotate(t *testing.T, kids ...string) {
+	t.Helper()
+
+	s.mu.Lock()
+	s.set = jwk.NewSet()
+	s.privateKeys = map[string]interface{}{}
+	s.mu.Unlock()
+
+	for _, kid := range kids {
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		s.addKey(t, kid, privateKey, &privateKey.PublicKey)
+	}
+}
+
+// addKey starts serving the public key, and keeps the private key for signing.
+func (s *jwksServer) addKey(t *testing.T, kid string, privateKey interface{}, publicKey interface{}) {
+	t.Helper()
+
+	key, err := jwk.New(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to create JWK: %v", err)
+	}
+	_ = key.Set(jwk.KeyIDKey, kid)
+
+	s.mu.Lock()
+	s.set.Add(key)
+	s.privateKeys[kid] = privateKey
+	s.mu.Unlock()
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	s.failing = failing
+	s.mu.Unlock()
+}
+
+func (s *jwksServer) setDelay(delay time.Duration) {
+	s.mu.Lock()
+	s.delay = delay
+	s.mu.Unlock()
+}
+
+// lookupConcurrently looks up the key from several goroutines at once, and returns the errors they got.
+func lookupConcurrently(cache *jwksCache, kid string) []error {
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cache.lookupKey(context.Background(), kid)
+		}(i)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func TestJWKSCacheFetchesOnce(t *testing.T) {
+	server := newJWKSServer(t, "key-1")
+	cache := newJWKSCache(server.URL, time.Hour, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		if _, err := cache.lookupKey(context.Background(), "key-1"); err != nil {
+			t.Fatalf("Expected key-1 to be found, but got: %v", err)
+		}
+	}
+
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Fatalf("Expected 1 fetch, but got %d", fetches)
+	}
+}
+
+func TestJWKSCacheConcurrentLookups(t *testing.T) {
+	server := newJWKSServer(t, "key-1")
+	server.setDelay(100 * time.Millisecond)
+	cache := newJWKSCache(server.URL, time.Hour, time.Minute)
+
+	for _, err := range lookupConcurrently(cache, "key-1") {
+		if err != nil {
+			t.Fatalf("Expected lookups during a fetch to wait for it, but got: %v", err)
+		}
+	}
+
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Fatalf("Expected 1 fetch, but got %d", fetches)
+	}
+}
+
+func TestJWKSCacheLookupsDuringStartupFetch(t *testing.T) {
+	server := newJWKSServer(t, "key-1")
+	server.setDelay(100 * time.Millisecond)
+	cache := newJWKSCache(server.URL, time.Hour, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.run(ctx)
+
+	for server.fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, err := range lookupConcurrently(cache, "key-1") {
+		if err != nil {
+			t.Fatalf("Expected lookups during the startup fetch to wait for it, but got: %v", err)
+		}
+	}
+
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Fatalf("Expected 1 fetch, but got %d", fetches)
+	}
+}
+
+func TestJWKSCacheRefetchesOnUnknownKey(t *testing.T) {
+	server := newJWKSServer(t, "key-1")
+	cache := newJWKSCache(server.URL, time.Hour, 0)
+
+	if _, err := cache.lookupKey(context.Background(), "key-1"); err != nil {
+		t.Fatalf("Expected key-1 to be found, but got: %v", err)
+	}
+
+	server.rotate(t, "key-2")
+
+	if _, err := cache.lookupKey(context.Background(), "key-2"); err != nil {
+		t.Fatalf("Expected rotated key-2 to be found, but got: %v", err)
+	}
+
+	if fetches := server.fetches.Load(); fetches != 2 {
+		t.Fatalf("Expected 2 fetches, but got %d", fetches)
+	}
+}
+
+func TestJWKSCacheRateLimitsRefetches(t *testing.T) {
+	server := newJWKSServer(t, "key-1")
+	cache := newJWKSCache(server.URL, time.Hour, time.Minute)
+
+	if _, err := cache.lookupKey(context.Background(), "key-1"); err != nil {
+		t.Fatalf("Expected key-1 to be found, but got: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := cache.lookupKey(context.Background(), "made-up-key"); err == nil {
+			t.Fatalf("Expected an error for an unknown key, but got nil")
+		}
+	}
+
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Fatalf("Expected unknown keys not to refetch within the interval, but got %d fetches", fetches)
+	}
+}
+
+func TestJWKSCacheServesStaleKeysWhenFetchFails(t *testing.T) {
+	server := newJWKSServer(t, "key-1")
+	cache := newJWKSCache(server.URL, time.Hour, 0)
+
+	if err := cache.refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to fetch keys: %v", err)
+	}
+
+	server.setFailing(true)
+
+	if err := cache.refresh(context.Background()); err == nil {
+		t.Fatalf("Expected an error while the server is failing, but got nil")
+	}
+
+	if _, err := cache.lookupKey(context.Background(), "key-1"); err != nil {
+		t.Fatalf("Expected the stale key-1 to still be served, but got: %v", err)
+	}
+}
+
+func TestJWKSCacheBackgroundRefresh(t *testing.T) {
+	server := newJWKSServer(t, "key-1")
+	cache := newJWKSCache(server.URL, 10*time.Millisecond, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.run(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	server.rotate(t, "key-2")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, found := cache.cachedKey("key-2"); found {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected the background refresh to pick up key-2")
+}
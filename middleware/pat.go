@@ -0,0 +1,42 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/tokens"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so they can be told apart from JWTs.
+const PersonalAccessTokenPrefix = "listo_pat_"
+
+// NewPersonalAccessToken generates a personal access token, and the hash it should be stored under.
+func NewPersonalAccessToken() (string, string, error) {
+	return tokens.New(PersonalAccessTokenPrefix)
+}
+
+// verifyPersonalAccessToken looks up a personal access token, and returns the user it belongs to and its scopes.
+func verifyPersonalAccessToken(tokenString string) (string, []string, error) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return "", nil, fmt.Errorf("error setting up DynamoDBService: %w", err)
+	}
+
+	userID, token, err := service.GetAccessTokenByHash(tokens.Hash(tokenString))
+	if err != nil {
+		return "", nil, err
+	} else if userID == "" {
+		return "", nil, fmt.Errorf("invalid token")
+	}
+
+	if token.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+		if err != nil || time.Now().After(expiresAt) {
+			return "", nil, fmt.Errorf("token is expired")
+		}
+	}
+
+	return userID, token.Scopes, nil
+}
@@ -0,0 +1,123 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/models"
+)
+
+// ErrProfileUnverifiable is returned by VerifiedProfile when the request wasn't made with an identity provider's token,
+// so there are no claims to read the user's profile from.
+var ErrProfileUnverifiable = errors.New("the user's profile can only be read from an identity provider's token")
+
+// VerifiedProfile returns the authenticated user's ID, email and picture, as vouched for by their identity provider.
+// The email and picture are read from the token's claims, and from the provider's userinfo endpoint when the token
+// doesn't carry them, which is usual for access tokens. An email the provider marks as unverified is left out.
+func VerifiedProfile(c *gin.Context) (models.User, error) {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return models.User{}, ErrProfileUnverifiable
+	}
+
+	profile := claims.(map[string]interface{})
+
+	if _, hasEmail := profile["email"]; !hasEmail {
+		p, ok := c.Get("provider")
+		if !ok {
+			return models.User{}, ErrProfileUnverifiable
+		}
+
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		userInfo, err := p.(*provider).userInfo(c.Request.Context(), tokenString)
+		if err != nil {
+			return models.User{}, err
+		}
+
+		// the userinfo response has to be about the same subject as the token, or it can't be trusted for this user
+		if userInfo["sub"] != profile["sub"] {
+			return models.User{}, fmt.Errorf("userinfo is for subject %v, not %v", userInfo["sub"], profile["sub"])
+		}
+		profile = userInfo
+	}
+
+	user := models.User{ID: c.GetString("sub")}
+	if email, ok := profile["email"].(string); ok && profile["email_verified"] != false {
+		user.Email = email
+	}
+	if picture, ok := profile["picture"].(string); ok {
+		user.Picture = picture
+	}
+
+	return user, nil
+}
+
+// userInfo fetches the claims about the token's user from the provider's userinfo endpoint.
+func (p *provider) userInfo(ctx context.Context, tokenString string) (map[string]interface{}, error) {
+	url, err := p.userInfoEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+tokenString)
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch userinfo: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch userinfo: %s", response.Status)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse userinfo: %w", err)
+	}
+
+	return claims, nil
+}
+
+// userInfoEndpoint returns the provider's userinfo endpoint, from its config or its discovery document.
+// Discovery that fails is retried on a later request, at most once per jwksMinRefetchInterval.
+func (p *provider) userInfoEndpoint(ctx context.Context) (string, error) {
+	if p.config.UserInfoURL != "" {
+		return p.config.UserInfoURL, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.userInfoURL != "" {
+		return p.userInfoURL, nil
+	}
+
+	if !p.lastUserInfoCheck.IsZero() && time.Since(p.lastUserInfoCheck) < jwksMinRefetchInterval {
+		return "", fmt.Errorf("%s has no known userinfo endpoint", p.config.Issuer)
+	}
+	p.lastUserInfoCheck = time.Now()
+
+	document, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	if document.UserInfoEndpoint == "" {
+		return "", fmt.Errorf("discovery document for %s has no userinfo_endpoint", p.config.Issuer)
+	}
+	p.userInfoURL = document.UserInfoEndpoint
+
+	return p.userInfoURL, nil
+}
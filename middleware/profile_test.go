@@ -0,0 +1,94 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newProfileContext returns a gin context as AuthMiddleware would leave it for a JWT with the given claims.
+func newProfileContext(p *provider, claims map[string]interface{}) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/me", nil)
+	c.Request.Header.Set("Authorization", "Bearer access-token")
+	c.Set("sub", claims["sub"])
+	c.Set("claims", claims)
+	c.Set("provider", p)
+	return c
+}
+
+// newUserInfoServer serves the given claims from a userinfo endpoint, to requests with the expected access token.
+func newUserInfoServer(t *testing.T, claims map[string]interface{}) *provider {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(claims)
+	}))
+	t.Cleanup(server.Close)
+
+	return newProviders([]ProviderConfig{{Issuer: server.URL + "/", UserInfoURL: server.URL}})[server.URL+"/"]
+}
+
+func TestVerifiedProfileFromClaims(t *testing.T) {
+	c := newProfileContext(nil, map[string]interface{}{"sub": "alice", "email": "alice@example.com", "picture": "https://example.com/alice.png"})
+
+	user, err := VerifiedProfile(c)
+	if err != nil {
+		t.Fatalf("Expected the profile to be verified, but got: %v", err)
+	}
+	if user.ID != "alice" || user.Email != "alice@example.com" || user.Picture != "https://example.com/alice.png" {
+		t.Fatalf("Unexpected profile: %+v", user)
+	}
+}
+
+func TestVerifiedProfileSkipsUnverifiedEmail(t *testing.T) {
+	c := newProfileContext(nil, map[string]interface{}{"sub": "alice", "email": "alice@example.com", "email_verified": false})
+
+	user, err := VerifiedProfile(c)
+	if err != nil {
+		t.Fatalf("Expected the profile to be verified, but got: %v", err)
+	}
+	if user.Email != "" {
+		t.Fatalf("Expected the unverified email to be left out, but got: %s", user.Email)
+	}
+}
+
+func TestVerifiedProfileFromUserInfo(t *testing.T) {
+	p := newUserInfoServer(t, map[string]interface{}{"sub": "alice", "email": "alice@example.com"})
+	c := newProfileContext(p, map[string]interface{}{"sub": "alice"})
+
+	user, err := VerifiedProfile(c)
+	if err != nil {
+		t.Fatalf("Expected the profile to be verified, but got: %v", err)
+	}
+	if user.Email != "alice@example.com" {
+		t.Fatalf("Expected the email from userinfo, but got: %s", user.Email)
+	}
+}
+
+func TestVerifiedProfileRejectsUserInfoForOtherSubject(t *testing.T) {
+	p := newUserInfoServer(t, map[string]interface{}{"sub": "mallory", "email": "mallory@example.com"})
+	c := newProfileContext(p, map[string]interface{}{"sub": "alice"})
+
+	if _, err := VerifiedProfile(c); err == nil {
+		t.Fatal("Expected userinfo for another subject to be rejected")
+	}
+}
+
+func TestVerifiedProfileNeedsClaims(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("sub", "alice")
+
+	if _, err := VerifiedProfile(c); err != ErrProfileUnverifiable {
+		t.Fatalf("Expected ErrProfileUnverifiable, but got: %v", err)
+	}
+}
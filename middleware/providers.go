@@ -0,0 +1,212 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// ProviderConfig describes an identity provider whose tokens the API trusts.
+type ProviderConfig struct {
+	// Issuer must match the "iss" claim of the provider's tokens exactly.
+	Issuer string `json:"issuer"`
+	// Audience must be one of the "aud" claims of the provider's tokens.
+	Audience string `json:"audience"`
+	// JWKSURL is where the provider publishes its signing keys.
+	// When empty it is read from the provider's OpenID Connect discovery document.
+	JWKSURL string `json:"jwks_url"`
+	// UserIDClaim is the claim that holds the user's ID. Defaults to "sub".
+	UserIDClaim string `json:"user_id_claim"`
+	// UserInfoURL is the provider's OpenID Connect userinfo endpoint, used for profile claims the token doesn't carry.
+	// When empty it is read from the provider's discovery document.
+	UserInfoURL string `json:"userinfo_url"`
+}
+
+// keySource looks up the keys that tokens are signed with.
+type keySource interface {
+	lookupKey(ctx context.Context, kid string) (jwk.Key, error)
+}
+
+// provider verifies the tokens of one trusted issuer.
+type provider struct {
+	config ProviderConfig
+	client *http.Client
+
+	mu                sync.Mutex
+	keys              keySource
+	lastDiscovery     time.Time
+	userInfoURL       string
+	lastUserInfoCheck time.Time
+}
+
+// discoveryDocument holds the parts of an OpenID Connect discovery document the API uses.
+type discoveryDocument struct {
+	Issuer           string `json:"issuer"`
+	JWKSURI          string `json:"jwks_uri"`
+	UserInfoEndpoint string `json:"userinfo_endpoint"`
+}
+
+// loadProviderConfigs reads the trusted providers from AUTH_PROVIDERS, a JSON array of ProviderConfig.
+// Without it, the Auth0 tenant in AUTH0_DOMAIN and AUTH0_AUDIENCE is the only trusted provider.
+func loadProviderConfigs() ([]ProviderConfig, error) {
+	var configs []ProviderConfig
+
+	if raw := os.Getenv("AUTH_PROVIDERS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+			return nil, fmt.Errorf("failed to parse AUTH_PROVIDERS: %w", err)
+		}
+	} else if domain := os.Getenv("AUTH0_DOMAIN"); domain != "" {
+		configs = append(configs, ProviderConfig{
+			Issuer:      fmt.Sprintf("https://%s/", domain),
+			Audience:    os.Getenv("AUTH0_AUDIENCE"),
+			JWKSURL:     fmt.Sprintf("https://%s/.well-known/jwks.json", domain),
+			UserInfoURL: fmt.Sprintf("https://%s/userinfo", domain),
+		})
+	}
+
+	for i, config := range configs {
+		if config.Issuer == "" || config.Audience == "" {
+			return nil, fmt.Errorf("auth provider %d needs both an issuer and an audience", i)
+		}
+		if config.UserIDClaim == "" {
+			configs[i].UserIDClaim = "sub"
+		}
+	}
+
+	return configs, nil
+}
+
+// newProviders creates a provider for each config, keyed by issuer.
+func newProviders(configs []ProviderConfig) map[string]*provider {
+	providers := make(map[string]*provider, len(configs))
+	for _, config := range configs {
+		providers[config.Issuer] = &provider{
+			config: config,
+			client: &http.Client{Timeout: jwksFetchTimeout},
+		}
+	}
+
+	return providers
+}
+
+// verify checks the token's signature with the provider's key, and validates its issuer, audience and expiry.
+func (p *provider) verify(ctx context.Context, tokenString string, alg jwa.SignatureAlgorithm, kid string) (jwt.Token, error) {
+	keys, err := p.keySource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keys.lookupKey(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Algorithm() != "" && key.Algorithm() != alg.String() {
+		return nil, fmt.Errorf("key %s can't be used with %s", kid, alg)
+	}
+
+	var rawKey interface{}
+	if err := key.Raw(&rawKey); err != nil {
+		return nil, fmt.Errorf("failed to get raw key: %w", err)
+	}
+
+	token, err := jwt.ParseString(tokenString,
+		jwt.WithVerify(alg, rawKey),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.Audience),
+		jwt.WithAcceptableSkew(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// userID reads the user's ID from a verified token, using the provider's claim mapping.
+func (p *provider) userID(token jwt.Token) (string, error) {
+	value, _ := token.Get(p.config.UserIDClaim)
+	userID, ok := value.(string)
+	if !ok || userID == "" {
+		return "", fmt.Errorf("token has no %s claim", p.config.UserIDClaim)
+	}
+
+	return userID, nil
+}
+
+// keySource returns the provider's signing keys, running discovery first if the JWKS URL isn't configured.
+// Discovery that fails is retried on a later request, at most once per jwksMinRefetchInterval.
+func (p *provider) keySource(ctx context.Context) (keySource, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		return p.keys, nil
+	}
+
+	if !p.lastDiscovery.IsZero() && time.Since(p.lastDiscovery) < jwksMinRefetchInterval {
+		return nil, fmt.Errorf("discovery for %s failed recently", p.config.Issuer)
+	}
+	p.lastDiscovery = time.Now()
+
+	jwksURL := p.config.JWKSURL
+	if jwksURL == "" {
+		document, err := p.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		jwksURL = document.JWKSURI
+		p.userInfoURL = document.UserInfoEndpoint
+	}
+
+	cache := newJWKSCache(jwksURL, jwksRefreshInterval, jwksMinRefetchInterval)
+	go cache.run(context.Background())
+	p.keys = cache
+
+	return cache, nil
+}
+
+// discover fetches the provider's OpenID Connect discovery document.
+func (p *provider) discover(ctx context.Context) (discoveryDocument, error) {
+	url := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return discoveryDocument{}, err
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return discoveryDocument{}, fmt.Errorf("failed to fetch discovery document: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return discoveryDocument{}, fmt.Errorf("failed to fetch discovery document: %s", response.Status)
+	}
+
+	var document discoveryDocument
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		return discoveryDocument{}, fmt.Errorf("failed to parse discovery document: %w", err)
+	}
+
+	// the discovery document has to be about the issuer we asked for, or its keys can't be trusted for it
+	if document.Issuer != p.config.Issuer {
+		return discoveryDocument{}, fmt.Errorf("discovery document is for issuer %s, not %s", document.Issuer, p.config.Issuer)
+	} else if document.JWKSURI == "" {
+		return discoveryDocument{}, fmt.Errorf("discovery document for %s has no jwks_uri", p.config.Issuer)
+	}
+
+	return document, nil
+}
@@ -0,0 +1,130 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// signToken signs a token for the test provider with the server's key, overriding claims as given.
+func signToken(t *testing.T, server *jwksServer, kid string, alg jwa.SignatureAlgorithm, claims map[string]interface{}) string {
+	t.Helper()
+
+	token := jwt.New()
+	_ = token.Set(jwt.IssuerKey, server.URL+"/")
+	_ = token.Set(jwt.AudienceKey, "listo-api")
+	_ = token.Set(jwt.SubjectKey, "user-1")
+	_ = token.Set(jwt.ExpirationKey, time.Now().Add(time.Hour))
+	for name, value := range claims {
+		_ = token.Set(name, value)
+	}
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.KeyIDKey, kid)
+
+	server.mu.Lock()
+	privateKey := server.privateKeys[kid]
+	server.mu.Unlock()
+
+	signed, err := jwt.Sign(token, alg, privateKey, jwt.WithHeaders(headers))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	return string(signed)
+}
+
+func newTestProviders(server *jwksServer, userIDClaim string) map[string]*provider {
+	return newProviders([]ProviderConfig{{
+		Issuer:      server.URL + "/",
+		Audience:    "listo-api",
+		UserIDClaim: userIDClaim,
+	}})
+}
+
+func TestVerifyTokenAlgorithms(t *testing.T) {
+	server := newJWKSServer(t, "rsa")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	server.addKey(t, "ec", ecKey, &ecKey.PublicKey)
+
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	server.addKey(t, "ed", edPrivateKey, edPublicKey)
+
+	providers := newTestProviders(server, "sub")
+
+	for kid, alg := range map[string]jwa.SignatureAlgorithm{"rsa": jwa.RS256, "ec": jwa.ES256, "ed": jwa.EdDSA} {
+		tokenString := signToken(t, server, kid, alg, nil)
+
+		p, token, err := verifyToken(context.Background(), providers, tokenString)
+		if err != nil {
+			t.Fatalf("Expected %s token to verify, but got: %v", alg, err)
+		}
+
+		userID, err := p.userID(token)
+		if err != nil || userID != "user-1" {
+			t.Fatalf("Expected user-1 from %s token, but got %q, %v", alg, userID, err)
+		}
+	}
+}
+
+func TestVerifyTokenRejectsInvalidClaims(t *testing.T) {
+	server := newJWKSServer(t, "rsa")
+	providers := newTestProviders(server, "sub")
+
+	tests := map[string]map[string]interface{}{
+		"wrong audience": {jwt.AudienceKey: "someone-else"},
+		"unknown issuer": {jwt.IssuerKey: "https://untrusted.example.com/"},
+		"expired":        {jwt.ExpirationKey: time.Now().Add(-time.Hour)},
+	}
+
+	for name, claims := range tests {
+		tokenString := signToken(t, server, "rsa", jwa.RS256, claims)
+		if _, _, err := verifyToken(context.Background(), providers, tokenString); err == nil {
+			t.Fatalf("Expected %s token to be rejected, but got nil", name)
+		}
+	}
+}
+
+func TestVerifyTokenRejectsSymmetricAlgorithms(t *testing.T) {
+	server := newJWKSServer(t)
+	server.addKey(t, "hmac", []byte("secret"), []byte("secret"))
+	providers := newTestProviders(server, "sub")
+
+	tokenString := signToken(t, server, "hmac", jwa.HS256, nil)
+	if _, _, err := verifyToken(context.Background(), providers, tokenString); err == nil {
+		t.Fatalf("Expected HS256 token to be rejected, but got nil")
+	}
+}
+
+func TestVerifyTokenUserIDClaim(t *testing.T) {
+	server := newJWKSServer(t, "rsa")
+	providers := newTestProviders(server, "https://listo.app/user_id")
+
+	tokenString := signToken(t, server, "rsa", jwa.RS256, map[string]interface{}{"https://listo.app/user_id": "mapped-user"})
+
+	p, token, err := verifyToken(context.Background(), providers, tokenString)
+	if err != nil {
+		t.Fatalf("Expected token to verify, but got: %v", err)
+	}
+
+	userID, err := p.userID(token)
+	if err != nil || userID != "mapped-user" {
+		t.Fatalf("Expected mapped-user, but got %q, %v", userID, err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/models"
+)
+
+// apiScopes are the scopes the API knows about. Other scopes on a token, like openid or profile, are ignored.
+var apiScopes = []string{
+	models.ScopeChecklistsRead,
+	models.ScopeChecklistsWrite,
+	models.ScopeChecklistsShare,
+	models.ScopeAdmin,
+}
+
+// defaultUserScopes are granted to tokens that carry no API scopes at all, which is how regular user tokens
+// were issued before scopes existed. AUTH_REQUIRE_SCOPES=true turns this off.
+var defaultUserScopes = []string{
+	models.ScopeChecklistsRead,
+	models.ScopeChecklistsWrite,
+	models.ScopeChecklistsShare,
+}
+
+// RequireScopes is a middleware that only lets a request through if the caller's token grants all of the scopes.
+// It relies on AuthMiddleware having set "scopes" on the context.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("scopes")
+
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token is missing the " + scope + " scope"})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// scopesFromClaims collects the API scopes granted by a JWT, from its space separated "scope" claim and
+// its "permissions" claim, which is where Auth0 puts RBAC permissions.
+func scopesFromClaims(claims map[string]interface{}) []string {
+	var scopes []string
+
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+
+	if permissions, ok := claims["permissions"].([]interface{}); ok {
+		for _, permission := range permissions {
+			if permission, ok := permission.(string); ok {
+				scopes = append(scopes, permission)
+			}
+		}
+	}
+
+	granted := []string{}
+	for _, scope := range scopes {
+		if slices.Contains(apiScopes, scope) && !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	if len(granted) == 0 && os.Getenv("AUTH_REQUIRE_SCOPES") != "true" {
+		return slices.Clone(defaultUserScopes)
+	}
+
+	return granted
+}
@@ -0,0 +1,47 @@
+// Package middleware provides the middleware for the application.
+package middleware
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScopesFromClaims(t *testing.T) {
+	tests := map[string]struct {
+		claims   map[string]interface{}
+		expected []string
+	}{
+		"scope claim": {
+			claims:   map[string]interface{}{"scope": "openid checklists:read profile"},
+			expected: []string{"checklists:read"},
+		},
+		"permissions claim": {
+			claims:   map[string]interface{}{"permissions": []interface{}{"checklists:read", "admin"}},
+			expected: []string{"checklists:read", "admin"},
+		},
+		"both claims": {
+			claims:   map[string]interface{}{"scope": "checklists:write", "permissions": []interface{}{"checklists:write", "checklists:share"}},
+			expected: []string{"checklists:write", "checklists:share"},
+		},
+		"no api scopes": {
+			claims:   map[string]interface{}{"scope": "openid profile email"},
+			expected: defaultUserScopes,
+		},
+	}
+
+	for name, test := range tests {
+		scopes := scopesFromClaims(test.claims)
+		if !slices.Equal(scopes, test.expected) {
+			t.Fatalf("%s: expected %v, but got %v", name, test.expected, scopes)
+		}
+	}
+}
+
+func TestScopesFromClaimsRequired(t *testing.T) {
+	t.Setenv("AUTH_REQUIRE_SCOPES", "true")
+
+	scopes := scopesFromClaims(map[string]interface{}{"scope": "openid profile"})
+	if len(scopes) != 0 {
+		t.Fatalf("Expected no scopes when scopes are required, but got %v", scopes)
+	}
+}
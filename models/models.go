@@ -6,11 +6,23 @@ type Checklist struct {
 	ID            string         `json:"id"`
 	Title         string         `json:"title"`
 	Locked        bool           `json:"locked"`
+	LockMode      LockMode       `json:"lock_mode"`
 	Collaborators []Collaborator `json:"collaborators"`
 	CreatedAt     string         `json:"created_at"`
 	UpdatedAt     string         `json:"updated_at"`
 }
 
+// LockMode controls what can still change on a locked checklist.
+type LockMode string
+
+// The ways a checklist can be locked. Only the owner can change a checklist's lock.
+const (
+	// LockModeChecks still lets items be checked and unchecked. It is the default for locked checklists.
+	LockModeChecks LockMode = "checks"
+	// LockModeFrozen doesn't allow any changes.
+	LockModeFrozen LockMode = "frozen"
+)
+
 // ChecklistItem is a single item in a checklist.
 type ChecklistItem struct {
 	ID        string `json:"id"`
@@ -19,6 +31,8 @@ type ChecklistItem struct {
 	Ordering  int    `json:"ordering"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+	// Version counts the edits to the item's content. Edits sent as operations name the version they were made against.
+	Version int `json:"version"`
 }
 
 // User is a user of the application. Most info is actually stored in Auth0.
@@ -26,6 +40,8 @@ type User struct {
 	ID      string `json:"id"`
 	Email   string `json:"email"`
 	Picture string `json:"picture"`
+	// DeletionScheduledFor is when the user's account will be deleted, if they asked for it to be.
+	DeletionScheduledFor string `json:"deletion_scheduled_for,omitempty"`
 }
 
 // Collaborator is a user that has been invited to collaborate on a checklist. The ID is removed for extra security, and becuase it's not needed on the client.
@@ -33,3 +49,278 @@ type Collaborator struct {
 	Email   string `json:"email"`
 	Picture string `json:"picture"`
 }
+
+// Role is the level of access a user has to a checklist.
+type Role string
+
+// The roles a user can have on a checklist. Collaborators without a stored role are editors.
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// Action is something a user can do with a checklist.
+type Action string
+
+// The actions that roles grant.
+const (
+	ActionRead   Action = "read"
+	ActionWrite  Action = "write"
+	ActionCopy   Action = "copy"
+	ActionShare  Action = "share"
+	ActionManage Action = "manage"
+)
+
+var rolePermissions = map[Role][]Action{
+	RoleOwner:  {ActionRead, ActionWrite, ActionCopy, ActionShare, ActionManage},
+	RoleEditor: {ActionRead, ActionWrite, ActionCopy},
+	RoleViewer: {ActionRead},
+}
+
+// Can reports whether the role allows the action.
+func (r Role) Can(action Action) bool {
+	for _, allowed := range rolePermissions[r] {
+		if allowed == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PublicChecklist is the read-only view of a checklist served on its public link. Collaborators are left out so no emails are exposed.
+type PublicChecklist struct {
+	Title     string          `json:"title"`
+	Locked    bool            `json:"locked"`
+	Items     []ChecklistItem `json:"items"`
+	UpdatedAt string          `json:"updated_at"`
+}
+
+// The scopes routes require. Tokens carry them in their scope or permissions claims, and personal access tokens
+// are limited to the ones they were created with.
+const (
+	ScopeChecklistsRead  = "checklists:read"
+	ScopeChecklistsWrite = "checklists:write"
+	ScopeChecklistsShare = "checklists:share"
+	ScopeAdmin           = "admin"
+)
+
+// AccessToken is a personal access token a user created for scripts and integrations.
+// The token itself is only shown once, when it is created. Only its hash is stored.
+type AccessToken struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at,omitempty"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// Preferences are a user's settings, used by the clients and by server-side features like item sorting.
+type Preferences struct {
+	DisplayName string `json:"display_name"`
+	// TimeZone is an IANA time zone name, like "Europe/London".
+	TimeZone string `json:"time_zone"`
+	// Locale is a BCP 47 language tag, like "en-GB". It picks the language of content the server writes for the user.
+	// When empty, the language the user's browser asks for is used.
+	Locale          string   `json:"locale"`
+	DefaultItemSort ItemSort `json:"default_item_sort"`
+	// DefaultChecklistID is the checklist that quick-added items go to.
+	DefaultChecklistID string               `json:"default_checklist_id"`
+	Notifications      NotificationSettings `json:"notifications"`
+}
+
+// NotificationSettings control which notifications a user gets.
+type NotificationSettings struct {
+	Email  bool `json:"email"`
+	Digest bool `json:"digest"`
+	// Muted are the types of notification the user doesn't want in their inbox.
+	Muted []NotificationType `json:"muted"`
+}
+
+// ItemSort is the order a checklist's items are listed in.
+type ItemSort string
+
+// The orders items can be sorted in.
+const (
+	// ItemSortManual keeps the order users arranged the items in.
+	ItemSortManual         ItemSort = "manual"
+	ItemSortCreated        ItemSort = "created"
+	ItemSortAlphabetical   ItemSort = "alphabetical"
+	ItemSortUncheckedFirst ItemSort = "unchecked_first"
+)
+
+// DefaultPreferences are the preferences of a user who hasn't changed any.
+func DefaultPreferences() Preferences {
+	return Preferences{
+		TimeZone:        "UTC",
+		DefaultItemSort: ItemSortManual,
+		Notifications:   NotificationSettings{Email: true, Muted: []NotificationType{}},
+	}
+}
+
+// EventType is the kind of change an Event describes.
+type EventType string
+
+// The changes to checklists that are pushed to clients.
+const (
+	EventChecklistUpdated EventType = "checklist.updated"
+	EventChecklistDeleted EventType = "checklist.deleted"
+	EventItemCreated      EventType = "item.created"
+	EventItemUpdated      EventType = "item.updated"
+	EventItemsUpdated     EventType = "items.updated"
+	EventItemDeleted      EventType = "item.deleted"
+	// EventChecklistTransferred tells the clients watching a checklist that it has a new owner. Its data is the
+	// checklist.
+	EventChecklistTransferred EventType = "checklist.transferred"
+	// EventMembershipAdded and EventMembershipRemoved tell a user they were added to or removed from a shared checklist.
+	EventMembershipAdded   EventType = "membership.added"
+	EventMembershipRemoved EventType = "membership.removed"
+	// EventPresenceJoined and EventPresenceLeft tell the clients watching a checklist who started or stopped viewing it.
+	// Their data is the viewer, as a Collaborator.
+	EventPresenceJoined EventType = "presence.joined"
+	EventPresenceLeft   EventType = "presence.left"
+)
+
+// Event is a change to a checklist, as pushed to the clients watching it.
+// Data holds the checklist or item after the change, or is empty for deletions.
+type Event struct {
+	ID          string      `json:"id"`
+	Type        EventType   `json:"type"`
+	ChecklistID string      `json:"checklist_id"`
+	ItemID      string      `json:"item_id,omitempty"`
+	Data        interface{} `json:"data,omitempty"`
+	Time        string      `json:"time"`
+	// OwnerID and ActorID are the checklist's owner and the user who made the change.
+	// They are user IDs, so they aren't sent to clients.
+	OwnerID string `json:"-"`
+	ActorID string `json:"-"`
+	// Recipients are the users whose feeds get the event. When empty, it goes to the checklist's owner
+	// and collaborators.
+	Recipients []string `json:"-"`
+}
+
+// Webhook is a subscription that POSTs events to a URL, for one checklist or for every checklist the user can see.
+// Its secret, used to sign deliveries, is only shown when the webhook is created.
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// ChecklistID limits the webhook to one checklist. When empty, it gets the events of every checklist the user
+	// owns or that is shared with them.
+	ChecklistID string `json:"checklist_id,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// WebhookDelivery is an attempt, with its retries, to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	Event     string `json:"event"`
+	// Payload is the JSON body that is POSTed.
+	Payload  string                `json:"payload"`
+	Status   WebhookDeliveryStatus `json:"status"`
+	Attempts int                   `json:"attempts"`
+	// ResponseStatus and Error describe the last attempt.
+	ResponseStatus int    `json:"response_status,omitempty"`
+	Error          string `json:"error,omitempty"`
+	// RedeliveryOf is the delivery this one was manually redelivered from.
+	RedeliveryOf  string `json:"redelivery_of,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	LastAttemptAt string `json:"last_attempt_at,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+}
+
+// WebhookDeliveryStatus is where a delivery has got to.
+type WebhookDeliveryStatus string
+
+// The statuses of a delivery. Pending deliveries are waiting for their first attempt or a retry.
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// Notification tells a user about something another user did to one of their checklists.
+type Notification struct {
+	ID   string           `json:"id"`
+	Type NotificationType `json:"type"`
+	// ChecklistTitle and ItemContent are as they were when the notification was sent.
+	ChecklistID    string `json:"checklist_id"`
+	ChecklistTitle string `json:"checklist_title"`
+	ItemID         string `json:"item_id,omitempty"`
+	ItemContent    string `json:"item_content,omitempty"`
+	// Actor is the user who did it.
+	Actor     Collaborator `json:"actor"`
+	Read      bool         `json:"read"`
+	CreatedAt string       `json:"created_at"`
+}
+
+// NotificationType is the kind of thing a Notification is about.
+type NotificationType string
+
+// The things users are notified about. Checklists' owners are told when collaborators join, leave or check items,
+// and when a collaborator checks the last unchecked item. An item.checked notification without an item is for every
+// item being checked at once. Users are told when they are invited to a checklist.
+const (
+	NotificationCollaboratorJoined NotificationType = "collaborator.joined"
+	NotificationCollaboratorLeft   NotificationType = "collaborator.left"
+	NotificationItemChecked        NotificationType = "item.checked"
+	NotificationChecklistCompleted NotificationType = "checklist.completed"
+	NotificationInvitationReceived NotificationType = "invitation.received"
+)
+
+// Invitation invites someone to collaborate on a checklist, by email address. People who already have an account
+// are invited in the app, and anyone else by email, with a link to sign up and join.
+type Invitation struct {
+	ID          string `json:"id"`
+	ChecklistID string `json:"checklist_id"`
+	// ChecklistTitle is as it was when the invitation was created.
+	ChecklistTitle string             `json:"checklist_title"`
+	Email          string             `json:"email"`
+	Role           Role               `json:"role"`
+	Delivery       InvitationDelivery `json:"delivery"`
+	InvitedBy      Collaborator       `json:"invited_by"`
+	CreatedAt      string             `json:"created_at"`
+	SentAt         string             `json:"sent_at"`
+	ExpiresAt      string             `json:"expires_at"`
+	// OwnerID is the owner of the checklist. InviteeID is the invited user, for invitations delivered in the app,
+	// and TokenHash the hash of the join link's token, for ones delivered by email.
+	OwnerID   string `json:"-"`
+	InviteeID string `json:"-"`
+	TokenHash string `json:"-"`
+}
+
+// InvitationDelivery is how an invitation reached the invitee.
+type InvitationDelivery string
+
+// The ways invitations are delivered.
+const (
+	InvitationInApp InvitationDelivery = "app"
+	InvitationEmail InvitationDelivery = "email"
+)
+
+// Email is a message waiting in the outbox to be sent, or one that was sent or failed. It is rendered when it is
+// queued, so sending it only needs what is stored here.
+type Email struct {
+	ID       string      `json:"id"`
+	To       string      `json:"to"`
+	Subject  string      `json:"subject"`
+	Text     string      `json:"text"`
+	HTML     string      `json:"html"`
+	Status   EmailStatus `json:"status"`
+	Attempts int         `json:"attempts"`
+	// Error describes the last failed attempt.
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// EmailStatus is where an email has got to.
+type EmailStatus string
+
+// The statuses of an email. Pending emails are waiting for their first attempt or a retry.
+const (
+	EmailPending EmailStatus = "pending"
+	EmailSent    EmailStatus = "sent"
+	EmailFailed  EmailStatus = "failed"
+)
@@ -0,0 +1,179 @@
+// Package notifications keeps each user's in-app inbox of the things other users did to their checklists, like
+// joining them, leaving them or checking items. Some are emailed too, along with an optional daily digest.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/mailer"
+	"checklist-api/models"
+)
+
+const (
+	// digestHour is the hour of the day, in each user's own time zone, from which their digest is sent.
+	digestHour = 8
+	// digestPeriod is how far back each digest looks for changes.
+	digestPeriod = 24 * time.Hour
+	// digestWorkerInterval is how often users are checked for a digest that is due.
+	digestWorkerInterval = 15 * time.Minute
+)
+
+// Digest is the data of the daily digest email.
+type Digest struct {
+	// Date is the day the digest is for, in the user's time zone.
+	Date       string
+	Checklists []ChecklistDigest
+}
+
+// ChecklistDigest is what changed on one checklist since the last digest.
+type ChecklistDigest struct {
+	Title   string
+	Added   int
+	Changed int
+	Checked int
+	Total   int
+}
+
+// summarize counts the items added and changed on a checklist since the given time, and how many are checked.
+// It returns false if nothing changed.
+func summarize(checklist models.Checklist, items []models.ChecklistItem, since time.Time) (ChecklistDigest, bool) {
+	summary := ChecklistDigest{Title: checklist.Title, Total: len(items)}
+
+	for _, item := range items {
+		if item.Checked {
+			summary.Checked++
+		}
+
+		createdAt, _ := time.Parse(time.RFC3339, item.CreatedAt)
+		updatedAt, _ := time.Parse(time.RFC3339, item.UpdatedAt)
+		if !createdAt.Before(since) {
+			summary.Added++
+		} else if !updatedAt.Before(since) {
+			summary.Changed++
+		}
+	}
+
+	return summary, summary.Added > 0 || summary.Changed > 0
+}
+
+// RunDigestWorker emails the daily digest to the users who asked for it, once a day each, until the context is done.
+// Every instance can run it, since each user's digest is claimed before it is sent.
+func RunDigestWorker(ctx context.Context) {
+	ticker := time.NewTicker(digestWorkerInterval)
+	defer ticker.Stop()
+
+	for {
+		sendDigests(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDigests sends the digests that are due. A failed digest is logged, and not retried until the next day.
+func sendDigests(now time.Time) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		fmt.Printf("Error setting up DynamoDBService: %v\n", err)
+		return
+	}
+
+	userIDs, err := service.GetUsersWithDigest()
+	if err != nil {
+		fmt.Printf("Error finding users with digests: %v\n", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := sendDigest(service, userID, now); err != nil {
+			fmt.Printf("Error sending digest to %s: %v\n", userID, err)
+		}
+	}
+}
+
+// sendDigest sends a user their digest, if it is past digestHour in their time zone and today's hasn't been sent.
+// Nothing is sent when none of their shared checklists changed.
+func sendDigest(service *db.DynamoDBService, userID string, now time.Time) error {
+	preferences, err := service.GetUserPreferences(userID)
+	if err != nil {
+		return err
+	} else if !preferences.Notifications.Digest || !preferences.Notifications.Email {
+		return nil
+	}
+
+	location, err := time.LoadLocation(preferences.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	if local.Hour() < digestHour {
+		return nil
+	}
+
+	date := local.Format("2006-01-02")
+	claimed, err := service.ClaimDigest(userID, date)
+	if err != nil || !claimed {
+		return err
+	}
+
+	digest, err := buildDigest(service, userID, date, now.Add(-digestPeriod))
+	if err != nil {
+		return err
+	} else if len(digest.Checklists) == 0 {
+		return nil
+	}
+
+	user, err := service.GetUser(userID)
+	if err != nil {
+		return err
+	} else if user.Email == "" {
+		return nil
+	}
+
+	return mailer.Queue(user.Email, "digest", digest)
+}
+
+// buildDigest summarizes the changes since the given time to the checklists a user shares: the ones they own that
+// have collaborators, and the ones shared with them.
+func buildDigest(service *db.DynamoDBService, userID string, date string, since time.Time) (Digest, error) {
+	digest := Digest{Date: date, Checklists: []ChecklistDigest{}}
+
+	owned, err := service.GetChecklists(userID)
+	if err != nil {
+		return Digest{}, err
+	}
+	shared, err := service.GetSharedChecklists(userID)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	for _, checklist := range append(owned, shared...) {
+		if len(checklist.Collaborators) == 0 {
+			continue
+		}
+
+		ownerID, _, err := service.GetChecklistAccess(userID, checklist.ID)
+		if err != nil {
+			return Digest{}, err
+		} else if ownerID == "" {
+			continue
+		}
+
+		items, err := service.GetChecklistItems(ownerID, checklist.ID)
+		if err != nil {
+			return Digest{}, err
+		}
+
+		if summary, changed := summarize(checklist, items, since); changed {
+			digest.Checklists = append(digest.Checklists, summary)
+		}
+	}
+
+	return digest, nil
+}
@@ -0,0 +1,183 @@
+// Package notifications keeps each user's in-app inbox of the things other users did to their checklists, like
+// joining them, leaving them or checking items. Some are emailed too, along with an optional daily digest.
+package notifications
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/completion"
+	"checklist-api/db"
+	"checklist-api/mailer"
+	"checklist-api/models"
+)
+
+// retention is how long a notification is kept before DynamoDB deletes it.
+const retention = 90 * 24 * time.Hour
+
+// Types are the types of notification, which users can mute in their preferences.
+var Types = []models.NotificationType{
+	models.NotificationCollaboratorJoined,
+	models.NotificationCollaboratorLeft,
+	models.NotificationItemChecked,
+	models.NotificationChecklistCompleted,
+	models.NotificationInvitationReceived,
+}
+
+// emailTemplates are the emails sent for the types of notification that are also emailed, to the users who have
+// email notifications on.
+var emailTemplates = map[models.NotificationType]string{
+	models.NotificationChecklistCompleted: "checklist_completed",
+}
+
+// ValidateMuted checks that the notification types a user wants to mute exist.
+func ValidateMuted(muted []models.NotificationType) error {
+	for _, notificationType := range muted {
+		if !slices.Contains(Types, notificationType) {
+			return fmt.Errorf("unknown notification type %q", notificationType)
+		}
+	}
+
+	return nil
+}
+
+// Notify records a notification in a user's inbox, unless they have muted its type. Its ID and time are filled in.
+// Types with an email template are emailed too, if the user has email notifications on and SMTP is configured.
+// It returns false if the notification was muted.
+func Notify(userID string, notification models.Notification) (bool, error) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return false, err
+	}
+
+	preferences, err := service.GetUserPreferences(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get preferences, %v", err)
+	} else if slices.Contains(preferences.Notifications.Muted, notification.Type) {
+		return false, nil
+	}
+
+	// version 7 UUIDs start with their time, so the inbox can be listed in order by ID
+	id, err := uuid.NewV7()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	notification.ID = id.String()
+	notification.CreatedAt = now.Format(time.RFC3339)
+
+	if err := service.CreateNotification(userID, &notification, now.Add(retention)); err != nil {
+		return false, err
+	}
+
+	if name, ok := emailTemplates[notification.Type]; ok && preferences.Notifications.Email && mailer.Enabled() {
+		user, err := service.GetUser(userID)
+		if err != nil {
+			return true, fmt.Errorf("failed to get user, %v", err)
+		} else if user.Email != "" {
+			if err := mailer.Queue(user.Email, name, notification); err != nil {
+				return true, fmt.Errorf("failed to queue email, %v", err)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// NotifyOwner tells a checklist's owner that a collaborator did something to it, optionally to one of its items.
+// Nothing is sent for the owner's own changes. It is called once the change has happened, so a failure is logged
+// rather than failing the request.
+func NotifyOwner(notificationType models.NotificationType, ownerID string, checklistID string, actorID string, item *models.ChecklistItem) {
+	if actorID == "" || actorID == ownerID {
+		return
+	}
+
+	err := notifyOwner(notificationType, ownerID, checklistID, actorID, item)
+	if err != nil {
+		fmt.Printf("Error sending %s notification for checklist %s: %v\n", notificationType, checklistID, err)
+	}
+}
+
+// NotifyIfCompleted tells a checklist's owner that a collaborator completed it, if every item on it is now checked.
+// Each completion is only notified once, until the checklist is reopened. Like NotifyOwner, nothing is sent for the
+// owner's own changes, and failures are logged.
+func NotifyIfCompleted(ownerID string, checklistID string, actorID string) {
+	if actorID == "" || actorID == ownerID {
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		fmt.Printf("Error setting up DynamoDBService: %v\n", err)
+		return
+	}
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		fmt.Printf("Error setting up RedisService: %v\n", err)
+		return
+	}
+	defer redisService.Client.Close()
+
+	completed, err := completion.Check(service, redisService, completion.Notifications, ownerID, checklistID)
+	if err != nil {
+		fmt.Printf("Error checking whether checklist %s is complete: %v\n", checklistID, err)
+		return
+	} else if !completed {
+		return
+	}
+
+	NotifyOwner(models.NotificationChecklistCompleted, ownerID, checklistID, actorID, nil)
+}
+
+// Reopened records that a checklist has an unchecked item again, after an item was unchecked or added, so its next
+// completion is notified. Failures are logged.
+func Reopened(checklistID string) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		fmt.Printf("Error setting up RedisService: %v\n", err)
+		return
+	}
+	defer redisService.Client.Close()
+
+	if err := completion.Reopen(redisService, checklistID); err != nil {
+		fmt.Printf("Error reopening checklist %s: %v\n", checklistID, err)
+	}
+}
+
+// notifyOwner looks up the checklist and collaborator a notification is about, and records it for the owner.
+func notifyOwner(notificationType models.NotificationType, ownerID string, checklistID string, actorID string, item *models.ChecklistItem) error {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		return err
+	}
+
+	checklist, err := service.GetChecklist(ownerID, checklistID)
+	if err != nil {
+		return err
+	} else if checklist.ID == "" {
+		return nil
+	}
+
+	actor, err := service.GetUser(actorID)
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		Type:           notificationType,
+		ChecklistID:    checklistID,
+		ChecklistTitle: checklist.Title,
+		Actor:          models.Collaborator{Email: actor.Email, Picture: actor.Picture},
+	}
+	if item != nil {
+		notification.ItemID = item.ID
+		notification.ItemContent = item.Content
+	}
+
+	_, err = Notify(ownerID, notification)
+	return err
+}
@@ -0,0 +1,46 @@
+// Package notifications keeps each user's in-app inbox of the things other users did to their checklists, like
+// joining them, leaving them or checking items. Some are emailed too, along with an optional daily digest.
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"checklist-api/models"
+)
+
+func TestValidateMuted(t *testing.T) {
+	if err := ValidateMuted(nil); err != nil {
+		t.Fatalf("Expected muting nothing to be valid, but got %v", err)
+	}
+	if err := ValidateMuted(Types); err != nil {
+		t.Fatalf("Expected every type to be mutable, but got %v", err)
+	}
+	if err := ValidateMuted([]models.NotificationType{models.NotificationItemChecked, "item.exploded"}); err == nil {
+		t.Fatal("Expected an unknown type to be rejected")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	since := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	before := since.Add(-time.Hour).Format(time.RFC3339)
+	after := since.Add(time.Hour).Format(time.RFC3339)
+
+	items := []models.ChecklistItem{
+		{CreatedAt: after, UpdatedAt: after, Checked: true},
+		{CreatedAt: before, UpdatedAt: after, Checked: true},
+		{CreatedAt: before, UpdatedAt: before},
+	}
+	summary, changed := summarize(models.Checklist{Title: "Groceries"}, items, since)
+	if !changed {
+		t.Fatal("Expected the checklist to have changed")
+	}
+	expected := ChecklistDigest{Title: "Groceries", Added: 1, Changed: 1, Checked: 2, Total: 3}
+	if summary != expected {
+		t.Fatalf("Expected %+v, but got %+v", expected, summary)
+	}
+
+	if _, changed := summarize(models.Checklist{}, items[2:], since); changed {
+		t.Fatal("Expected a checklist without new changes to be left out")
+	}
+}
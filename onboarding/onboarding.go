@@ -0,0 +1,140 @@
+// Package onboarding provides the checklist new users start out with, in their language.
+package onboarding
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+// currentVersion is the version of the onboarding content. Every template has to be updated to it together,
+// so users get the same onboarding whatever their language.
+const currentVersion = 1
+
+// defaultLocale is the template used when none matches the user's language.
+const defaultLocale = "en"
+
+//go:embed templates/*.yaml
+var templateFS embed.FS
+
+// Template is the content of an onboarding checklist in one language.
+type Template struct {
+	Version int      `yaml:"version"`
+	Locale  string   `yaml:"locale"`
+	Title   string   `yaml:"title"`
+	Items   []string `yaml:"items"`
+}
+
+// templates holds the parsed templates, the default locale's first, in the same order as the matcher's tags.
+var templates, matcher = mustLoadTemplates()
+
+// mustLoadTemplates parses the embedded templates, and builds a matcher over their locales.
+// It panics if a template is invalid, since that is a mistake in the build.
+func mustLoadTemplates() ([]Template, language.Matcher) {
+	files, err := fs.Glob(templateFS, "templates/*.yaml")
+	if err != nil {
+		panic(err)
+	}
+
+	var loaded []Template
+	var tags []language.Tag
+	for _, file := range files {
+		data, err := templateFS.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
+
+		var template Template
+		if err := yaml.Unmarshal(data, &template); err != nil {
+			panic(fmt.Errorf("failed to parse onboarding template %s: %w", file, err))
+		} else if template.Version != currentVersion {
+			panic(fmt.Errorf("onboarding template %s is version %d, not %d", file, template.Version, currentVersion))
+		} else if template.Title == "" || len(template.Items) == 0 {
+			panic(fmt.Errorf("onboarding template %s needs a title and items", file))
+		}
+
+		tag, err := language.Parse(template.Locale)
+		if err != nil {
+			panic(fmt.Errorf("onboarding template %s has an invalid locale: %w", file, err))
+		}
+
+		// the matcher falls back to its first tag, so the default goes first
+		if template.Locale == defaultLocale {
+			loaded = append([]Template{template}, loaded...)
+			tags = append([]language.Tag{tag}, tags...)
+		} else {
+			loaded = append(loaded, template)
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(loaded) == 0 || loaded[0].Locale != defaultLocale {
+		panic(fmt.Errorf("there is no onboarding template for the default locale %s", defaultLocale))
+	}
+
+	return loaded, language.NewMatcher(tags)
+}
+
+// Enabled reports whether new users get an onboarding checklist. It is on unless ONBOARDING_ENABLED is false.
+func Enabled() bool {
+	return os.Getenv("ONBOARDING_ENABLED") != "false"
+}
+
+// Select picks the template that best matches the user's locale preference, or their Accept-Language header
+// if they have no preference. It falls back to English.
+func Select(locale string, acceptLanguage string) Template {
+	var preferred []language.Tag
+	if tag, err := language.Parse(locale); err == nil && locale != "" {
+		preferred = append(preferred, tag)
+	}
+	if tags, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil {
+		preferred = append(preferred, tags...)
+	}
+
+	_, index, confidence := matcher.Match(preferred...)
+	if confidence == language.No {
+		return templates[0]
+	}
+
+	return templates[index]
+}
+
+// CreateChecklist creates the onboarding checklist for a user from the template, in one batched write.
+func CreateChecklist(service *db.DynamoDBService, userID string, template Template) (models.Checklist, error) {
+	now := time.Now().Format(time.RFC3339)
+	checklist := models.Checklist{
+		ID:        uuid.New().String(),
+		Title:     template.Title,
+		Locked:    false,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	items := make([]models.ChecklistItem, 0, len(template.Items))
+	for i, content := range template.Items {
+		items = append(items, models.ChecklistItem{
+			ID:        uuid.New().String(),
+			Content:   content,
+			Checked:   false,
+			Ordering:  i,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	err := service.CreateChecklistWithItems(userID, &checklist, items)
+	if err != nil {
+		return models.Checklist{}, fmt.Errorf("failed to create onboarding checklist, %v", err)
+	}
+
+	return checklist, nil
+}
@@ -0,0 +1,54 @@
+// Package onboarding provides the checklist new users start out with, in their language.
+package onboarding
+
+import (
+	"testing"
+)
+
+func TestTemplatesLoad(t *testing.T) {
+	if templates[0].Locale != defaultLocale {
+		t.Fatalf("Expected the default template first, but got %s", templates[0].Locale)
+	}
+
+	for _, template := range templates {
+		if len(template.Items) != len(templates[0].Items) {
+			t.Fatalf("Expected the %s template to have %d items like the default, but it has %d", template.Locale, len(templates[0].Items), len(template.Items))
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := map[string]struct {
+		locale         string
+		acceptLanguage string
+		expected       string
+	}{
+		"no preference":               {expected: "en"},
+		"accept language":             {acceptLanguage: "es-MX,es;q=0.9,en;q=0.8", expected: "es"},
+		"accept language fallback":    {acceptLanguage: "ja,de;q=0.5", expected: "de"},
+		"unsupported language":        {acceptLanguage: "ja", expected: "en"},
+		"preference over header":      {locale: "de-AT", acceptLanguage: "es", expected: "de"},
+		"invalid preference":          {locale: "not a locale!", acceptLanguage: "es", expected: "es"},
+		"invalid accept language":     {acceptLanguage: ";;;", expected: "en"},
+		"regional english preference": {locale: "en-GB", acceptLanguage: "de", expected: "en"},
+	}
+
+	for name, test := range tests {
+		template := Select(test.locale, test.acceptLanguage)
+		if template.Locale != test.expected {
+			t.Fatalf("%s: expected the %s template, but got %s", name, test.expected, template.Locale)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	t.Setenv("ONBOARDING_ENABLED", "")
+	if !Enabled() {
+		t.Fatal("Expected onboarding to be on by default")
+	}
+
+	t.Setenv("ONBOARDING_ENABLED", "false")
+	if Enabled() {
+		t.Fatal("Expected onboarding to be off")
+	}
+}
@@ -0,0 +1,268 @@
+// Package ot implements operational transformation for plain text, so concurrent edits to the same text
+// can be merged instead of one overwriting the other.
+//
+// An Operation walks the whole document from start to end, retaining, inserting and deleting text as it goes.
+// Lengths and positions count Unicode code points, not bytes.
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrInvalidOperation is returned for operations with empty or negative components.
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// ErrLengthMismatch is returned when an operation doesn't span the whole of the text it is applied or transformed against.
+var ErrLengthMismatch = errors.New("operation length does not match the text")
+
+// Component is one step of an Operation. Exactly one of its fields is set.
+type Component struct {
+	// Retain skips over that many code points, keeping them.
+	Retain int
+	// Insert adds the text at the cursor.
+	Insert string
+	// Delete removes that many code points at the cursor.
+	Delete int
+}
+
+// Operation is a sequence of components that turns one text into another.
+// In JSON it is an array where positive numbers retain, strings insert and negative numbers delete,
+// like [5, "hello", -3].
+type Operation []Component
+
+// Validate checks that every component sets exactly one field, to a non-empty value.
+func (op Operation) Validate() error {
+	for i, c := range op {
+		set := 0
+		if c.Retain != 0 {
+			set++
+		}
+		if c.Insert != "" {
+			set++
+		}
+		if c.Delete != 0 {
+			set++
+		}
+
+		if set != 1 || c.Retain < 0 || c.Delete < 0 {
+			return fmt.Errorf("%w: component %d must retain, insert or delete", ErrInvalidOperation, i)
+		}
+	}
+
+	return nil
+}
+
+// BaseLength is the length of the text the operation applies to.
+func (op Operation) BaseLength() int {
+	length := 0
+	for _, c := range op {
+		length += c.Retain + c.Delete
+	}
+
+	return length
+}
+
+// Apply runs the operation on the text, and returns the result.
+func Apply(text string, op Operation) (string, error) {
+	if err := op.Validate(); err != nil {
+		return "", err
+	}
+
+	runes := []rune(text)
+	if op.BaseLength() != len(runes) {
+		return "", fmt.Errorf("%w: operation spans %d, text is %d", ErrLengthMismatch, op.BaseLength(), len(runes))
+	}
+
+	result := make([]rune, 0, len(runes))
+	cursor := 0
+	for _, c := range op {
+		switch {
+		case c.Retain > 0:
+			result = append(result, runes[cursor:cursor+c.Retain]...)
+			cursor += c.Retain
+		case c.Insert != "":
+			result = append(result, []rune(c.Insert)...)
+		case c.Delete > 0:
+			cursor += c.Delete
+		}
+	}
+
+	return string(result), nil
+}
+
+// Transform takes two operations made concurrently against the same text, and returns a' and b' such that
+// applying a then b' gives the same text as applying b then a'. When both insert at the same place,
+// a's text goes first, so the operation that was sequenced first should be passed as a.
+func Transform(a Operation, b Operation) (Operation, Operation, error) {
+	if err := a.Validate(); err != nil {
+		return nil, nil, err
+	} else if err := b.Validate(); err != nil {
+		return nil, nil, err
+	} else if a.BaseLength() != b.BaseLength() {
+		return nil, nil, fmt.Errorf("%w: operations span %d and %d", ErrLengthMismatch, a.BaseLength(), b.BaseLength())
+	}
+
+	var aPrime, bPrime builder
+	i, j := 0, 0
+	var ca, cb *Component
+	next := func(op Operation, index *int) *Component {
+		if *index >= len(op) {
+			return nil
+		}
+		c := op[*index]
+		*index++
+		return &c
+	}
+	ca, cb = next(a, &i), next(b, &j)
+
+	for ca != nil || cb != nil {
+		if ca != nil && ca.Insert != "" {
+			aPrime.insert(ca.Insert)
+			bPrime.retain(utf8.RuneCountInString(ca.Insert))
+			ca = next(a, &i)
+			continue
+		}
+		if cb != nil && cb.Insert != "" {
+			aPrime.retain(utf8.RuneCountInString(cb.Insert))
+			bPrime.insert(cb.Insert)
+			cb = next(b, &j)
+			continue
+		}
+		if ca == nil || cb == nil {
+			return nil, nil, ErrLengthMismatch
+		}
+
+		n := min(ca.Retain+ca.Delete, cb.Retain+cb.Delete)
+		switch {
+		case ca.Retain > 0 && cb.Retain > 0:
+			aPrime.retain(n)
+			bPrime.retain(n)
+		case ca.Delete > 0 && cb.Retain > 0:
+			aPrime.delete(n)
+		case ca.Retain > 0 && cb.Delete > 0:
+			bPrime.delete(n)
+		}
+		// when both delete the same text, neither has anything left to do
+
+		if ca = shorten(ca, n); ca == nil {
+			ca = next(a, &i)
+		}
+		if cb = shorten(cb, n); cb == nil {
+			cb = next(b, &j)
+		}
+	}
+
+	return aPrime.op, bPrime.op, nil
+}
+
+// Diff returns an operation that turns one text into another, by replacing whatever lies between their
+// common prefix and suffix.
+func Diff(from string, to string) Operation {
+	a, b := []rune(from), []rune(to)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var op builder
+	op.retain(prefix)
+	op.delete(len(a) - prefix - suffix)
+	op.insert(string(b[prefix : len(b)-suffix]))
+	op.retain(suffix)
+
+	return op.op
+}
+
+// MarshalJSON encodes the component as a number or string, as described on Operation.
+func (c Component) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Insert != "":
+		return json.Marshal(c.Insert)
+	case c.Delete > 0:
+		return json.Marshal(-c.Delete)
+	default:
+		return json.Marshal(c.Retain)
+	}
+}
+
+// UnmarshalJSON decodes a component from a number or string, as described on Operation.
+func (c *Component) UnmarshalJSON(data []byte) error {
+	var insert string
+	if err := json.Unmarshal(data, &insert); err == nil {
+		*c = Component{Insert: insert}
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("%w: components must be numbers or strings", ErrInvalidOperation)
+	}
+
+	if n < 0 {
+		*c = Component{Delete: -n}
+	} else {
+		*c = Component{Retain: n}
+	}
+
+	return nil
+}
+
+// shorten removes n code points from a retain or delete component, and returns nil once it is used up.
+func shorten(c *Component, n int) *Component {
+	if c.Retain > 0 {
+		c.Retain -= n
+	} else {
+		c.Delete -= n
+	}
+
+	if c.Retain == 0 && c.Delete == 0 {
+		return nil
+	}
+	return c
+}
+
+// builder assembles an operation, merging neighbouring components of the same kind and dropping empty ones.
+type builder struct {
+	op Operation
+}
+
+func (b *builder) retain(n int) {
+	if n == 0 {
+		return
+	}
+	if last := len(b.op) - 1; last >= 0 && b.op[last].Retain > 0 {
+		b.op[last].Retain += n
+		return
+	}
+	b.op = append(b.op, Component{Retain: n})
+}
+
+func (b *builder) insert(text string) {
+	if text == "" {
+		return
+	}
+	if last := len(b.op) - 1; last >= 0 && b.op[last].Insert != "" {
+		b.op[last].Insert += text
+		return
+	}
+	b.op = append(b.op, Component{Insert: text})
+}
+
+func (b *builder) delete(n int) {
+	if n == 0 {
+		return
+	}
+	if last := len(b.op) - 1; last >= 0 && b.op[last].Delete > 0 {
+		b.op[last].Delete += n
+		return
+	}
+	b.op = append(b.op, Component{Delete: n})
+}
@@ -0,0 +1,168 @@
+// Package ot implements operational transformation for plain text, so concurrent edits to the same text
+// can be merged instead of one overwriting the other.
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	op := Operation{{Retain: 4}, {Delete: 5}, {Insert: "bread"}, {Retain: 3}}
+
+	result, err := Apply("Buy milks x2", op)
+	if err != nil {
+		t.Fatalf("Failed to apply operation: %v", err)
+	}
+	if result != "Buy bread x2" {
+		t.Fatalf("Expected \"Buy bread x2\", but got %q", result)
+	}
+}
+
+func TestApplyCountsCodePoints(t *testing.T) {
+	result, err := Apply("café ☕", Operation{{Retain: 4}, {Insert: "!"}, {Retain: 2}})
+	if err != nil {
+		t.Fatalf("Failed to apply operation: %v", err)
+	}
+	if result != "café! ☕" {
+		t.Fatalf("Expected \"café! ☕\", but got %q", result)
+	}
+}
+
+func TestApplyRejectsWrongLength(t *testing.T) {
+	if _, err := Apply("milk", Operation{{Retain: 3}}); !errors.Is(err, ErrLengthMismatch) {
+		t.Fatalf("Expected ErrLengthMismatch, but got %v", err)
+	}
+}
+
+func TestApplyRejectsInvalidComponents(t *testing.T) {
+	if _, err := Apply("milk", Operation{{Retain: 2, Insert: "x"}, {Retain: 2}}); !errors.Is(err, ErrInvalidOperation) {
+		t.Fatalf("Expected ErrInvalidOperation, but got %v", err)
+	}
+}
+
+func TestTransformConcurrentInserts(t *testing.T) {
+	a := Operation{{Retain: 4}, {Insert: "oat "}, {Retain: 4}}
+	b := Operation{{Retain: 4}, {Insert: "cold "}, {Retain: 4}}
+
+	assertConverges(t, "Buy milk", a, b, "Buy oat cold milk")
+}
+
+func TestTransformOverlappingDeletes(t *testing.T) {
+	a := Operation{{Retain: 2}, {Delete: 4}, {Retain: 2}}
+	b := Operation{{Retain: 4}, {Delete: 4}}
+
+	assertConverges(t, "abcdefgh", a, b, "ab")
+}
+
+func TestTransformConverges(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		text := randomText(random, random.Intn(20))
+		a := randomOperation(random, text)
+		b := randomOperation(random, text)
+		assertConverges(t, text, a, b, "")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{"Buy milk", "Buy oat milk"},
+		{"Buy milk", "Buy"},
+		{"", "Buy milk"},
+		{"Buy milk", ""},
+		{"aaa", "aaaa"},
+		{"café", "cafés"},
+	}
+
+	for _, test := range tests {
+		result, err := Apply(test.from, Diff(test.from, test.to))
+		if err != nil || result != test.to {
+			t.Fatalf("Expected the diff from %q to give %q, but got %q and %v", test.from, test.to, result, err)
+		}
+	}
+}
+
+func TestOperationJSON(t *testing.T) {
+	op := Operation{{Retain: 5}, {Insert: "hello"}, {Delete: 3}}
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatalf("Failed to marshal operation: %v", err)
+	}
+	if string(data) != `[5,"hello",-3]` {
+		t.Fatalf(`Expected [5,"hello",-3], but got %s`, data)
+	}
+
+	var decoded Operation
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal operation: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, op) {
+		t.Fatalf("Expected %v, but got %v", op, decoded)
+	}
+}
+
+// assertConverges checks that a and b, applied in either order after transforming, give the same text.
+// If expected isn't empty, that text has to be it.
+func assertConverges(t *testing.T, text string, a Operation, b Operation, expected string) {
+	t.Helper()
+
+	aPrime, bPrime, err := Transform(a, b)
+	if err != nil {
+		t.Fatalf("Failed to transform %v and %v: %v", a, b, err)
+	}
+
+	afterA, _ := Apply(text, a)
+	left, err := Apply(afterA, bPrime)
+	if err != nil {
+		t.Fatalf("Failed to apply b' %v: %v", bPrime, err)
+	}
+
+	afterB, _ := Apply(text, b)
+	right, err := Apply(afterB, aPrime)
+	if err != nil {
+		t.Fatalf("Failed to apply a' %v: %v", aPrime, err)
+	}
+
+	if left != right {
+		t.Fatalf("Operations %v and %v on %q diverged: %q and %q", a, b, text, left, right)
+	} else if expected != "" && left != expected {
+		t.Fatalf("Expected %q, but got %q", expected, left)
+	}
+}
+
+func randomText(random *rand.Rand, length int) string {
+	letters := []rune("abcdé☕ ")
+	text := make([]rune, length)
+	for i := range text {
+		text[i] = letters[random.Intn(len(letters))]
+	}
+	return string(text)
+}
+
+func randomOperation(random *rand.Rand, text string) Operation {
+	var op builder
+	remaining := len([]rune(text))
+	for remaining > 0 {
+		n := 1 + random.Intn(remaining)
+		switch random.Intn(3) {
+		case 0:
+			op.retain(n)
+			remaining -= n
+		case 1:
+			op.delete(n)
+			remaining -= n
+		case 2:
+			op.insert(randomText(random, 1+random.Intn(3)))
+		}
+	}
+	if random.Intn(2) == 0 {
+		op.insert(randomText(random, 1+random.Intn(3)))
+	}
+	return op.op
+}
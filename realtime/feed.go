@@ -0,0 +1,190 @@
+// Package realtime pushes checklist events to the clients watching them, across API instances, through Redis pub/sub.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+// feedRetention is how long events stay in a user's feed, and so how long a client can be away and still resume.
+const feedRetention = 24 * time.Hour
+
+// FeedSink is an events.Sink that appends each event to the feeds of the users it concerns.
+type FeedSink struct {
+	redis  *db.RedisService
+	dynamo *db.DynamoDBService
+}
+
+// NewFeedSink connects to Redis and DynamoDB, and returns a sink to register with events.Register.
+func NewFeedSink() (*FeedSink, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, err
+	}
+
+	dynamoService, err := db.NewDynamoDBService()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeedSink{redis: redisService, dynamo: dynamoService}, nil
+}
+
+// Publish appends the event to the feed of each of its recipients, or of the checklist's owner and collaborators
+// if it names none. Presence events are left out, since they only matter to clients viewing the checklist.
+func (s *FeedSink) Publish(event models.Event) error {
+	if event.Type == models.EventPresenceJoined || event.Type == models.EventPresenceLeft {
+		return nil
+	}
+
+	recipients := event.Recipients
+	if len(recipients) == 0 {
+		collaboratorIDs, err := s.dynamo.GetCollaboratorIDs(event.OwnerID, event.ChecklistID)
+		if err != nil {
+			return err
+		}
+		recipients = append([]string{event.OwnerID}, collaboratorIDs...)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range recipients {
+		if err := s.redis.AppendUserFeed(userID, payload, feedRetention); err != nil {
+			return fmt.Errorf("failed to append to feed of %s, %v", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// Feed reads a user's events in order, from a position in their feed.
+type Feed struct {
+	redis    *db.RedisService
+	userID   string
+	position string
+}
+
+// OpenFeed starts reading a user's feed after lastEventID, the ID of the last event the client saw, or from now
+// if it is empty. It reports a gap if events after lastEventID may have been trimmed from the feed, in which case
+// reading starts from now and the client should reload its data.
+func OpenFeed(userID string, lastEventID string) (*Feed, bool, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, false, err
+	}
+
+	oldest, newest, err := redisService.GetUserFeedBounds(userID)
+	if err != nil {
+		redisService.Client.Close()
+		return nil, false, err
+	}
+
+	position, gap := resumePosition(lastEventID, oldest, newest, time.Now())
+
+	return &Feed{redis: redisService, userID: userID, position: position}, gap, nil
+}
+
+// Next returns the events after the feed's position, waiting up to block for one to arrive, and moves past them.
+// It returns no events if none arrived in time.
+func (f *Feed) Next(ctx context.Context, block time.Duration) ([]models.Event, error) {
+	messages, err := f.redis.ReadUserFeed(ctx, f.userID, f.position, block)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.Event, 0, len(messages))
+	for _, message := range messages {
+		f.position = message.ID
+
+		payload, _ := message.Values["event"].(string)
+		var event models.Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			fmt.Printf("Error decoding feed event %s for %s: %v\n", message.ID, f.userID, err)
+			continue
+		}
+
+		// the stream ID is what clients send back as Last-Event-ID to resume
+		event.ID = message.ID
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Close releases the feed's Redis connection.
+func (f *Feed) Close() error {
+	return f.redis.Client.Close()
+}
+
+// resumePosition works out where to read a feed from, given the last event the client saw and the IDs of the
+// oldest and newest entries still in the feed. It reports a gap when the client's last event is older than
+// everything that was kept, or isn't a stream ID at all.
+func resumePosition(lastEventID string, oldest string, newest string, now time.Time) (string, bool) {
+	current := newest
+	if current == "" {
+		current = "0-0"
+	}
+
+	if lastEventID == "" {
+		return current, false
+	}
+
+	last, ok := parseStreamID(lastEventID)
+	if !ok {
+		return current, true
+	}
+
+	if oldest != "" {
+		first, _ := parseStreamID(oldest)
+		if compareStreamIDs(last, first) < 0 {
+			return current, true
+		}
+	} else if last[0] < uint64(now.Add(-feedRetention).UnixMilli()) {
+		// the feed expired while the client was away, so anything it missed is gone
+		return current, true
+	}
+
+	return lastEventID, false
+}
+
+// parseStreamID splits a Redis stream ID into its millisecond time and sequence number.
+func parseStreamID(id string) ([2]uint64, bool) {
+	ms, seq, found := strings.Cut(id, "-")
+	if !found {
+		return [2]uint64{}, false
+	}
+
+	msValue, err := strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return [2]uint64{}, false
+	}
+	seqValue, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return [2]uint64{}, false
+	}
+
+	return [2]uint64{msValue, seqValue}, true
+}
+
+// compareStreamIDs orders two parsed stream IDs, like strings.Compare.
+func compareStreamIDs(a [2]uint64, b [2]uint64) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+
+	return 0
+}
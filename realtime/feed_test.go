@@ -0,0 +1,39 @@
+// Package realtime pushes checklist events to the clients watching them, across API instances, through Redis pub/sub.
+package realtime
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResumePosition(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	recent := strconv.FormatInt(now.Add(-time.Hour).UnixMilli(), 10) + "-0"
+	expired := strconv.FormatInt(now.Add(-2*feedRetention).UnixMilli(), 10) + "-0"
+
+	tests := map[string]struct {
+		lastEventID      string
+		oldest           string
+		newest           string
+		expectedPosition string
+		expectedGap      bool
+	}{
+		"new connection":               {oldest: "100-0", newest: "200-0", expectedPosition: "200-0"},
+		"new connection to empty feed": {expectedPosition: "0-0"},
+		"resume within the feed":       {lastEventID: "150-3", oldest: "100-0", newest: "200-0", expectedPosition: "150-3"},
+		"resume from the oldest entry": {lastEventID: "100-0", oldest: "100-0", newest: "200-0", expectedPosition: "100-0"},
+		"resume from a trimmed entry":  {lastEventID: "99-9", oldest: "100-0", newest: "200-0", expectedPosition: "200-0", expectedGap: true},
+		"resume from an invalid ID":    {lastEventID: "not-an-id", oldest: "100-0", newest: "200-0", expectedPosition: "200-0", expectedGap: true},
+		"resume quiet feed":            {lastEventID: recent, expectedPosition: recent},
+		"resume feed that has expired": {lastEventID: expired, expectedPosition: "0-0", expectedGap: true},
+		"resume sequence number order": {lastEventID: "100-2", oldest: "100-10", newest: "200-0", expectedPosition: "200-0", expectedGap: true},
+	}
+
+	for name, test := range tests {
+		position, gap := resumePosition(test.lastEventID, test.oldest, test.newest, now)
+		if position != test.expectedPosition || gap != test.expectedGap {
+			t.Fatalf("%s: expected position %s and gap %v, but got %s and %v", name, test.expectedPosition, test.expectedGap, position, gap)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package realtime pushes checklist events to the clients watching them, across API instances, through Redis pub/sub.
+package realtime
+
+import (
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/events"
+	"checklist-api/models"
+)
+
+// PresenceTTL is how long a user counts as viewing a checklist after their last heartbeat.
+// Clients should send heartbeats at about half this interval.
+const PresenceTTL = 60 * time.Second
+
+// Heartbeat records a user as viewing a checklist for another PresenceTTL, and tells the other viewers if they
+// just arrived. ownerID is the owner of the checklist.
+func Heartbeat(ownerID string, checklistID string, userID string) error {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return err
+	}
+	defer redisService.Client.Close()
+
+	if err := sweepPresence(redisService, ownerID, checklistID); err != nil {
+		return err
+	}
+
+	joined, err := redisService.SetPresence(checklistID, userID, time.Now().Add(PresenceTTL))
+	if err != nil {
+		return err
+	} else if joined {
+		publishPresence(models.EventPresenceJoined, ownerID, checklistID, userID)
+	}
+
+	return nil
+}
+
+// OpenStream records a user as viewing a checklist while they have an event stream open on it. Users can have
+// several open, like in different tabs, so they are counted, and CloseStream only lets the user leave with the last.
+func OpenStream(ownerID string, checklistID string, userID string) error {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return err
+	}
+	defer redisService.Client.Close()
+
+	if err := redisService.AddPresenceStream(checklistID, userID, PresenceTTL); err != nil {
+		return err
+	}
+
+	return Heartbeat(ownerID, checklistID, userID)
+}
+
+// StreamHeartbeat keeps a user with an open event stream on a checklist recorded as viewing it.
+func StreamHeartbeat(ownerID string, checklistID string, userID string) error {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return err
+	}
+	defer redisService.Client.Close()
+
+	if err := redisService.RenewPresenceStreams(checklistID, userID, PresenceTTL); err != nil {
+		return err
+	}
+
+	return Heartbeat(ownerID, checklistID, userID)
+}
+
+// CloseStream records that a user closed an event stream on a checklist. They leave once their last one is closed.
+func CloseStream(ownerID string, checklistID string, userID string) error {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return err
+	}
+	defer redisService.Client.Close()
+
+	left, err := redisService.RemovePresenceStream(checklistID, userID)
+	if err != nil {
+		return err
+	} else if left > 0 {
+		return nil
+	}
+
+	return Leave(ownerID, checklistID, userID)
+}
+
+// Leave records a user as no longer viewing a checklist, and tells the other viewers.
+func Leave(ownerID string, checklistID string, userID string) error {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return err
+	}
+	defer redisService.Client.Close()
+
+	left, err := redisService.RemovePresence(checklistID, userID)
+	if err != nil {
+		return err
+	} else if left {
+		publishPresence(models.EventPresenceLeft, ownerID, checklistID, userID)
+	}
+
+	return nil
+}
+
+// Present returns the IDs of the users viewing a checklist.
+func Present(ownerID string, checklistID string) ([]string, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, err
+	}
+	defer redisService.Client.Close()
+
+	if err := sweepPresence(redisService, ownerID, checklistID); err != nil {
+		return nil, err
+	}
+
+	present, _, err := redisService.GetPresence(checklistID, time.Now())
+	return present, err
+}
+
+// sweepPresence removes the viewers whose heartbeats stopped, and tells the others they left.
+func sweepPresence(redisService *db.RedisService, ownerID string, checklistID string) error {
+	_, expired, err := redisService.GetPresence(checklistID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range expired {
+		left, err := redisService.RemovePresence(checklistID, userID)
+		if err != nil {
+			return err
+		} else if left {
+			publishPresence(models.EventPresenceLeft, ownerID, checklistID, userID)
+		}
+	}
+
+	return nil
+}
+
+// publishPresence tells the clients watching a checklist that a user started or stopped viewing it.
+func publishPresence(eventType models.EventType, ownerID string, checklistID string, userID string) {
+	viewer := models.Collaborator{}
+	if service, err := db.NewDynamoDBService(); err == nil {
+		if user, err := service.GetUser(userID); err == nil {
+			viewer = models.Collaborator{Email: user.Email, Picture: user.Picture}
+		}
+	}
+
+	events.Publish(models.Event{
+		Type:        eventType,
+		ChecklistID: checklistID,
+		Data:        viewer,
+		OwnerID:     ownerID,
+		ActorID:     userID,
+	})
+}
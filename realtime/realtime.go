@@ -0,0 +1,85 @@
+// Package realtime pushes checklist events to the clients watching them, across API instances, through Redis pub/sub.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+// RedisSink is an events.Sink that publishes each event on its checklist's Redis channel.
+type RedisSink struct {
+	redis *db.RedisService
+}
+
+// NewRedisSink connects to Redis, and returns a sink to register with events.Register.
+func NewRedisSink() (*RedisSink, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisSink{redis: redisService}, nil
+}
+
+// Publish sends the event to every API instance with clients watching its checklist.
+func (s *RedisSink) Publish(event models.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.redis.PublishChecklistEvent(event.ChecklistID, payload)
+}
+
+// Subscribe delivers a checklist's events until the context is done, when the channel is closed.
+// It returns once the subscription is active, so no event published after that is missed.
+func Subscribe(ctx context.Context, checklistID string) (<-chan models.Event, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := redisService.SubscribeChecklistEvents(checklistID)
+	if _, err := subscription.Receive(ctx); err != nil {
+		subscription.Close()
+		redisService.Client.Close()
+		return nil, fmt.Errorf("failed to subscribe, %v", err)
+	}
+
+	events := make(chan models.Event)
+	go func() {
+		defer close(events)
+		defer redisService.Client.Close()
+		defer subscription.Close()
+
+		messages := subscription.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var event models.Event
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					fmt.Printf("Error decoding event for checklist %s: %v\n", checklistID, err)
+					continue
+				}
+
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
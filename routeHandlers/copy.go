@@ -0,0 +1,69 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/db"
+)
+
+// copyRequest is the optional body for duplicating or forking a checklist.
+type copyRequest struct {
+	Title        string `json:"title"`
+	ResetChecked bool   `json:"reset_checked"`
+}
+
+// PostDuplicateChecklist handles the request to copy a checklist, owned or shared, into the user's own checklists.
+func PostDuplicateChecklist(c *gin.Context) {
+	userID := getUserID(c)
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	var request copyRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	if request.Title == "" {
+		original, err := service.GetChecklist(ownerID, checklistID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error getting checklist: " + err.Error(),
+			})
+			return
+		}
+
+		// forks keep their title, since they end up in a different list of checklists
+		request.Title = original.Title
+		if ownerID == userID {
+			request.Title += " (copy)"
+		}
+	}
+
+	checklist, err := service.CopyChecklist(ownerID, checklistID, userID, request.Title, request.ResetChecked)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error copying checklist: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Checklist copied",
+			"checklist": checklist,
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/middleware"
+)
+
+// GetDevJWKS handles the request for the public keys of the development token issuer.
+func GetDevJWKS(c *gin.Context) {
+	set, err := middleware.DevJWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting dev keys: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, set)
+}
+
+// PostDevToken handles the request to mint a development token for any user.
+// It is only routed in the development auth mode.
+func PostDevToken(c *gin.Context) {
+	var request struct {
+		Sub     string `json:"sub"`
+		Email   string `json:"email"`
+		Picture string `json:"picture"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	} else if request.Sub == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Sub is required",
+		})
+		return
+	}
+
+	token, err := middleware.MintDevToken(request.Sub, request.Email, request.Picture)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error minting token: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"access_token": token,
+			"token_type":   "Bearer",
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/db"
+	"checklist-api/models"
+	"checklist-api/realtime"
+)
+
+// streamHeartbeatInterval is how often idle event streams send a comment, so proxies don't close them.
+// The user's access is checked again at the same time.
+const streamHeartbeatInterval = 25 * time.Second
+
+// GetChecklistEvents handles the request to stream a checklist's changes as Server-Sent Events.
+// The user counts as viewing the checklist while any of their streams on it is open.
+// The stream ends when the checklist is deleted, or when the user loses access to it.
+func GetChecklistEvents(c *gin.Context) {
+	userID := getUserID(c)
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	stream, err := realtime.Subscribe(c.Request.Context(), checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error subscribing to events: " + err.Error(),
+		})
+		return
+	}
+
+	startEventStream(c)
+
+	if err := realtime.OpenStream(ownerID, checklistID, userID); err != nil {
+		fmt.Printf("Error recording presence on %s: %v\n", checklistID, err)
+	}
+	defer func() {
+		if err := realtime.CloseStream(ownerID, checklistID, userID); err != nil {
+			fmt.Printf("Error removing presence on %s: %v\n", checklistID, err)
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-stream:
+			if !ok {
+				return false
+			}
+			writeEvent(c, event)
+			return event.Type != models.EventChecklistDeleted
+		case <-heartbeat.C:
+			_, role, err := service.GetChecklistAccess(userID, checklistID)
+			if err != nil || !role.Can(models.ActionRead) {
+				return false
+			}
+			if err := realtime.StreamHeartbeat(ownerID, checklistID, userID); err != nil {
+				fmt.Printf("Error recording presence on %s: %v\n", checklistID, err)
+			}
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		}
+	})
+}
+
+// startEventStream sends the headers that start a Server-Sent Events stream.
+func startEventStream(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// stop nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// writeEvent sends an event on a Server-Sent Events stream, named after its type.
+func writeEvent(c *gin.Context, event models.Event) {
+	c.Render(-1, sse.Event{
+		Id:    event.ID,
+		Event: string(event.Type),
+		Data:  event,
+	})
+}
+
+// GetEvents handles the request to stream the changes to all of the user's checklists, owned and shared, as
+// Server-Sent Events. That includes the user being added to or removed from shared checklists. A client that
+// reconnects with the Last-Event-ID header gets the events it missed. If those are no longer kept, it gets a
+// reset event first, and should reload its checklists.
+func GetEvents(c *gin.Context) {
+	userID := getUserID(c)
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	feed, gap, err := realtime.OpenFeed(userID, lastEventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error opening event feed: " + err.Error(),
+		})
+		return
+	}
+	defer feed.Close()
+
+	startEventStream(c)
+
+	if gap {
+		c.Render(-1, sse.Event{
+			Event: "reset",
+			Data:  gin.H{"message": "Some events are no longer available, reload your checklists"},
+		})
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		events, err := feed.Next(c.Request.Context(), streamHeartbeatInterval)
+		if err != nil {
+			return false
+		}
+
+		if len(events) == 0 {
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		}
+
+		for _, event := range events {
+			writeEvent(c, event)
+		}
+		return true
+	})
+}
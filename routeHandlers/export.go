@@ -0,0 +1,126 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/accounts"
+)
+
+// exportFilename is the name of the archive users download their data export as.
+const exportFilename = "listo-export.zip"
+
+// GetMeExport handles the request to export everything stored about the authenticated user, as a ZIP archive.
+// Small accounts get the archive in the response. Large accounts, or any account with ?async=true, are exported
+// in the background, and the response says where to check on the export.
+func GetMeExport(c *gin.Context) {
+	userID := getUserID(c)
+
+	background := c.Query("async") == "true"
+	if !background {
+		var err error
+		background, err = accounts.ShouldExportInBackground(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error sizing export: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if background {
+		exportID, err := accounts.StartExport(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error starting export: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"id":     exportID,
+			"status": accounts.ExportPending,
+			"url":    "/me/exports/" + exportID,
+		})
+		return
+	}
+
+	archive, err := accounts.BuildExport(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error exporting account: " + err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+exportFilename+`"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// GetMeExportStatus handles the request to check on a background export. Once it is ready, the response
+// includes a time-limited link that downloads the archive without a token.
+func GetMeExportStatus(c *gin.Context) {
+	userID := getUserID(c)
+	exportID := c.Param("exportID")
+
+	status, exportErr, err := accounts.ExportStatus(userID, exportID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting export: " + err.Error(),
+		})
+		return
+	} else if status == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Export not found",
+		})
+		return
+	}
+
+	response := gin.H{
+		"id":     exportID,
+		"status": status,
+	}
+	switch status {
+	case accounts.ExportReady:
+		expires, signature := accounts.SignExportDownload(exportID)
+		response["download_url"] = "/exports/" + exportID + "?" + url.Values{
+			"expires":   {expires},
+			"signature": {signature},
+		}.Encode()
+	case accounts.ExportFailed:
+		response["error"] = exportErr
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetExportDownload handles the request to download a background export through its signed link.
+func GetExportDownload(c *gin.Context) {
+	exportID := c.Param("exportID")
+
+	if !accounts.VerifyExportDownload(exportID, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Download link is invalid or has expired",
+		})
+		return
+	}
+
+	archive, err := accounts.ExportArchive(exportID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting export: " + err.Error(),
+		})
+		return
+	} else if archive == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Export has expired",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+exportFilename+`"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
@@ -0,0 +1,239 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/events"
+	"checklist-api/ingest"
+	"checklist-api/models"
+	"checklist-api/notifications"
+	"checklist-api/tokens"
+)
+
+// GetIngestURL handles the request to check whether a checklist has an ingest URL. The secret is only stored
+// hashed, so the URL itself can't be shown again.
+func GetIngestURL(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	hash, createdAt, err := service.GetIngestURL(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting ingest URL: " + err.Error(),
+		})
+	} else if hash == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Ingest URL is not enabled",
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":    true,
+			"created_at": createdAt,
+		})
+	}
+}
+
+// PutIngestURL handles the request to enable the ingest URL for a checklist.
+// Calling it again rotates the secret, revoking the previous URL.
+func PutIngestURL(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	secret, hash, err := ingest.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error generating ingest secret: " + err.Error(),
+		})
+		return
+	}
+
+	createdAt := time.Now().Format(time.RFC3339)
+
+	err = service.EnableIngestURL(ownerID, checklistID, hash, createdAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error enabling ingest URL: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Ingest URL enabled",
+			"secret":     secret,
+			"path":       "/ingest/" + secret,
+			"created_at": createdAt,
+		})
+	}
+}
+
+// DeleteIngestURL handles the request to revoke the ingest URL for a checklist.
+func DeleteIngestURL(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	err = service.DisableIngestURL(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error disabling ingest URL: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Ingest URL disabled",
+		})
+	}
+}
+
+// PostIngest handles the unauthenticated request to add items to a checklist through its ingest URL.
+// The body is plain text with one item per line, or JSON with "content" or "items".
+// Items are added one at a time, so if the checklist is locked partway through, the ones already added are kept.
+func PostIngest(c *gin.Context) {
+	hash := tokens.Hash(c.Param("secret"))
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	ownerID, checklistID, err := service.GetIngestChecklistOwner(hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting ingest URL: " + err.Error(),
+		})
+		return
+	} else if checklistID == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Checklist does not exist",
+		})
+		return
+	}
+
+	// without Redis the limit can't be enforced, and an unlimited public URL is worse than an unavailable one
+	allowed, retryAfter, err := ingest.Allow(hash, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error checking rate limit: " + err.Error(),
+		})
+		return
+	} else if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"message": "Too many requests, try again later",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, ingest.MaxBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": "Request is too large",
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	contents, err := ingest.Parse(c.ContentType(), body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	items, err := service.GetChecklistItems(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting items: " + err.Error(),
+		})
+		return
+	}
+
+	ordering := 0
+	for _, item := range items {
+		ordering = max(ordering, item.Ordering+1)
+	}
+
+	created := []models.ChecklistItem{}
+	for _, content := range contents {
+		newItem := models.ChecklistItem{
+			ID:        uuid.New().String(),
+			Content:   content,
+			Ordering:  ordering,
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+		newItem.UpdatedAt = newItem.CreatedAt
+
+		err := service.CreateChecklistItem(ownerID, checklistID, &newItem)
+		if errors.Is(err, db.ErrChecklistLocked) {
+			c.JSON(http.StatusLocked, gin.H{
+				"message": "Checklist is locked",
+				"items":   created,
+			})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error creating item: " + err.Error(),
+				"items":   created,
+			})
+			return
+		}
+
+		events.Publish(models.Event{
+			Type:        models.EventItemCreated,
+			ChecklistID: checklistID,
+			ItemID:      newItem.ID,
+			Data:        newItem,
+			OwnerID:     ownerID,
+		})
+		if len(created) == 0 {
+			notifications.Reopened(checklistID)
+		}
+
+		created = append(created, newItem)
+		ordering++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Items created",
+		"items":   created,
+	})
+}
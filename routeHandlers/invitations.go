@@ -0,0 +1,353 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/db"
+	"checklist-api/events"
+	"checklist-api/invitations"
+	"checklist-api/models"
+	"checklist-api/notifications"
+	"checklist-api/tokens"
+)
+
+// GetChecklistInvitations handles the request to list the pending invitations to a checklist.
+func GetChecklistInvitations(c *gin.Context) {
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	pending, err := service.GetChecklistInvitations(checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting invitations: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, pending)
+	}
+}
+
+// PostChecklistInvitation handles the request to invite someone to a checklist by email, with a role of editor (the
+// default) or viewer.
+func PostChecklistInvitation(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	var request struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	email, err := invitations.ParseEmail(request.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	role, err := invitations.ParseRole(request.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	checklist, err := service.GetChecklist(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting checklist: " + err.Error(),
+		})
+		return
+	}
+
+	invitation, err := invitations.Invite(service, ownerID, checklist, email, role)
+	if err != nil {
+		respondInvitationError(c, "Error inviting collaborator: ", err)
+	} else {
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    "Invitation sent",
+			"invitation": invitation,
+		})
+	}
+}
+
+// PostChecklistInvitationResend handles the request to send an invitation again, which restarts its expiry.
+func PostChecklistInvitationResend(c *gin.Context) {
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	invitation, err := service.GetInvitation(c.Param("invitationID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting invitation: " + err.Error(),
+		})
+		return
+	} else if invitation.ChecklistID != checklistID {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invitation does not exist",
+		})
+		return
+	}
+
+	invitation, err = invitations.Resend(service, invitation)
+	if err != nil {
+		respondInvitationError(c, "Error resending invitation: ", err)
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Invitation resent",
+			"invitation": invitation,
+		})
+	}
+}
+
+// DeleteChecklistInvitation handles the request to revoke an invitation. An emailed invitation's link stops working.
+func DeleteChecklistInvitation(c *gin.Context) {
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	invitation, err := service.GetInvitation(c.Param("invitationID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting invitation: " + err.Error(),
+		})
+		return
+	} else if invitation.ChecklistID != checklistID {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invitation does not exist",
+		})
+		return
+	}
+
+	err = service.DeleteInvitation(invitation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error revoking invitation: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Invitation revoked",
+		})
+	}
+}
+
+// GetInvitations handles the request to list the pending invitations the user got in the app.
+func GetInvitations(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	pending, err := service.GetUserInvitations(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting invitations: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, pending)
+	}
+}
+
+// PostInvitationAccept handles the request to accept an invitation the user got in the app.
+func PostInvitationAccept(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	invitation, err := service.GetInvitation(c.Param("invitationID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting invitation: " + err.Error(),
+		})
+		return
+	} else if invitation.InviteeID != userID {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invitation does not exist",
+		})
+		return
+	}
+
+	acceptInvitation(c, service, invitation)
+}
+
+// PostInvitationAcceptToken handles the request to accept an emailed invitation, with the token from its link.
+// Whoever has the link can accept it, whatever email they signed up with.
+func PostInvitationAcceptToken(c *gin.Context) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	} else if request.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Token is required",
+		})
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	invitation, err := service.GetInvitationByToken(tokens.Hash(request.Token))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting invitation: " + err.Error(),
+		})
+		return
+	} else if invitation.ID == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invitation does not exist, or has expired",
+		})
+		return
+	}
+
+	acceptInvitation(c, service, invitation)
+}
+
+// DeleteInvitation handles the request to decline an invitation the user got in the app.
+func DeleteInvitation(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	invitation, err := service.GetInvitation(c.Param("invitationID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting invitation: " + err.Error(),
+		})
+		return
+	} else if invitation.InviteeID != userID {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invitation does not exist",
+		})
+		return
+	}
+
+	err = service.DeleteInvitation(invitation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error declining invitation: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Invitation declined",
+		})
+	}
+}
+
+// acceptInvitation adds the user to the checklist an invitation is for, and tells the owner, like joining with a
+// share code does.
+func acceptInvitation(c *gin.Context, service *db.DynamoDBService, invitation models.Invitation) {
+	userID := getUserID(c)
+
+	checklist, err := invitations.Accept(service, invitation, userID)
+	if err != nil {
+		respondInvitationError(c, "Error accepting invitation: ", err)
+		return
+	}
+
+	events.Publish(models.Event{
+		Type:        models.EventMembershipAdded,
+		ChecklistID: checklist.ID,
+		Data:        checklist,
+		OwnerID:     invitation.OwnerID,
+		ActorID:     userID,
+		Recipients:  []string{userID},
+	})
+	notifications.NotifyOwner(models.NotificationCollaboratorJoined, invitation.OwnerID, checklist.ID, userID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Invitation accepted",
+		"checklist_id": checklist.ID,
+		"role":         invitation.Role,
+	})
+}
+
+// respondInvitationError responds with the status that suits an error from the invitations package.
+func respondInvitationError(c *gin.Context, prefix string, err error) {
+	switch {
+	case errors.Is(err, invitations.ErrInviteSelf):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+	case errors.Is(err, invitations.ErrAlreadyCollaborator), errors.Is(err, invitations.ErrAlreadyInvited):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+	case errors.Is(err, invitations.ErrChecklistGone), errors.Is(err, db.ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+	case errors.Is(err, invitations.ErrEmailsOff):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": prefix + err.Error(),
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/db"
+)
+
+// The number of notifications listed per page, unless ?limit= asks for a different number, up to the maximum.
+const (
+	defaultNotificationsLimit = 20
+	maxNotificationsLimit     = 100
+)
+
+// GetNotifications handles the request to list the authenticated user's notifications, newest first, with how many
+// are unread. Pages continue from the notification in ?before=, the next value of the previous page.
+func GetNotifications(c *gin.Context) {
+	userID := getUserID(c)
+
+	limit := defaultNotificationsLimit
+	if query := c.Query("limit"); query != "" {
+		parsed, err := strconv.Atoi(query)
+		if err != nil || parsed < 1 || parsed > maxNotificationsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "limit must be between 1 and " + strconv.Itoa(maxNotificationsLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	notifications, next, err := service.GetNotifications(userID, c.Query("before"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting notifications: " + err.Error(),
+		})
+		return
+	}
+
+	unread, err := service.CountUnreadNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error counting unread notifications: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread_count":  unread,
+		"next":          next,
+	})
+}
+
+// PostNotificationRead handles the request to mark one of the authenticated user's notifications as read.
+func PostNotificationRead(c *gin.Context) {
+	userID := getUserID(c)
+	notificationID := c.Param("notificationID")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	found, err := service.MarkNotificationRead(userID, notificationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error marking notification read: " + err.Error(),
+		})
+	} else if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Notification does not exist",
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Notification marked read",
+		})
+	}
+}
+
+// PostNotificationsReadAll handles the request to mark all of the authenticated user's notifications as read.
+func PostNotificationsReadAll(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	count, err := service.MarkAllNotificationsRead(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error marking notifications read: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Notifications marked read",
+			"count":   count,
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/collab"
+	"checklist-api/db"
+	"checklist-api/events"
+	"checklist-api/models"
+	"checklist-api/ot"
+)
+
+// PostItemOperation handles the request to edit an item's content with an operation, in a checklist owned or shared.
+// The operation is merged with any edits made since the version it was made against.
+func PostItemOperation(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+	itemID := c.Param("itemID")
+
+	var request struct {
+		Version   *int         `json:"version"`
+		Operation ot.Operation `json:"operation"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	} else if request.Version == nil || *request.Version < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "The version the operation was made against is required",
+		})
+		return
+	}
+
+	item, edit, err := collab.Apply(ownerID, checklistID, itemID, *request.Version, request.Operation)
+	if !respondToEditError(c, err) {
+		return
+	}
+
+	publishItemEdit(c, ownerID, checklistID, item, edit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Item updated",
+		"item":      item,
+		"operation": edit.Operation,
+	})
+}
+
+// respondToEditError sends the response for an error from editing an item's content. It returns true if there was
+// no error, and the request can carry on.
+func respondToEditError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, collab.ErrItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Item does not exist",
+		})
+	case errors.Is(err, db.ErrChecklistLocked):
+		c.JSON(http.StatusLocked, gin.H{
+			"message": "Checklist is locked",
+		})
+	case errors.Is(err, ot.ErrInvalidOperation), errors.Is(err, ot.ErrLengthMismatch), errors.Is(err, collab.ErrVersionAhead):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid operation: " + err.Error(),
+		})
+	case errors.Is(err, collab.ErrHistoryUnavailable), errors.Is(err, collab.ErrTooManyConflicts):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Could not merge the edit, reload the item and try again: " + err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error updating item: " + err.Error(),
+		})
+	}
+
+	return false
+}
+
+// publishItemEdit tells the clients watching a checklist about an edit to an item's content.
+func publishItemEdit(c *gin.Context, ownerID string, checklistID string, item models.ChecklistItem, edit collab.Edit) {
+	events.Publish(models.Event{
+		Type:        models.EventItemUpdated,
+		ChecklistID: checklistID,
+		ItemID:      item.ID,
+		Data:        collab.ItemUpdate{ChecklistItem: item, Operation: edit.Operation},
+		OwnerID:     ownerID,
+		ActorID:     getUserID(c),
+	})
+}
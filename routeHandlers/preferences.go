@@ -0,0 +1,273 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+
+	"checklist-api/db"
+	"checklist-api/events"
+	"checklist-api/models"
+	"checklist-api/notifications"
+)
+
+// maxDisplayNameLength is the longest display name a user can set, in characters.
+const maxDisplayNameLength = 64
+
+// itemSorts are the orders a checklist's items can be sorted in.
+var itemSorts = []models.ItemSort{
+	models.ItemSortManual,
+	models.ItemSortCreated,
+	models.ItemSortAlphabetical,
+	models.ItemSortUncheckedFirst,
+}
+
+// GetMePreferences handles the request to get the authenticated user's preferences.
+func GetMePreferences(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	preferences, err := service.GetUserPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting preferences: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, preferences)
+	}
+}
+
+// PatchMePreferences handles the request to change the authenticated user's preferences.
+// Preferences left out of the body keep their current values.
+func PatchMePreferences(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	preferences, err := service.GetUserPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting preferences: " + err.Error(),
+		})
+		return
+	}
+
+	if err := c.BindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := validatePreferences(service, userID, &preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	found, err := service.UpdateUserPreferences(userID, preferences)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error updating preferences: " + err.Error(),
+		})
+	} else if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Save your profile with PUT /me before setting preferences",
+		})
+	} else {
+		c.JSON(http.StatusOK, preferences)
+	}
+}
+
+// PostQuickAdd handles the request to add an item to the authenticated user's default checklist.
+func PostQuickAdd(c *gin.Context) {
+	userID := getUserID(c)
+
+	var newItem models.ChecklistItem
+	if err := c.BindJSON(&newItem); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	} else if strings.TrimSpace(newItem.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Content is required",
+		})
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	preferences, err := service.GetUserPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting preferences: " + err.Error(),
+		})
+		return
+	} else if preferences.DefaultChecklistID == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "No default checklist is set",
+		})
+		return
+	}
+	checklistID := preferences.DefaultChecklistID
+
+	// access can change after the default is set, so it is checked again on every add
+	ownerID, role, err := service.GetChecklistAccess(userID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error checking access: " + err.Error(),
+		})
+		return
+	} else if !role.Can(models.ActionWrite) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "You can no longer add items to your default checklist",
+		})
+		return
+	}
+
+	items, err := service.GetChecklistItems(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting items: " + err.Error(),
+		})
+		return
+	}
+
+	newItem.ID = uuid.New().String()
+	newItem.Checked = false
+	newItem.Ordering = 0
+	for _, item := range items {
+		newItem.Ordering = max(newItem.Ordering, item.Ordering+1)
+	}
+	newItem.CreatedAt = time.Now().Format(time.RFC3339)
+	newItem.UpdatedAt = newItem.CreatedAt
+
+	err = service.CreateChecklistItem(ownerID, checklistID, &newItem)
+	if errors.Is(err, db.ErrChecklistLocked) {
+		c.JSON(http.StatusLocked, gin.H{
+			"message": "Checklist is locked",
+		})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error creating item: " + err.Error(),
+		})
+	} else {
+		events.Publish(models.Event{
+			Type:        models.EventItemCreated,
+			ChecklistID: checklistID,
+			ItemID:      newItem.ID,
+			Data:        newItem,
+			OwnerID:     ownerID,
+			ActorID:     userID,
+		})
+		notifications.Reopened(checklistID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Item created",
+			"checklist_id": checklistID,
+			"item":         newItem,
+		})
+	}
+}
+
+// validatePreferences checks the preferences, and normalises the locale to its canonical form.
+func validatePreferences(service *db.DynamoDBService, userID string, preferences *models.Preferences) error {
+	if utf8.RuneCountInString(preferences.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("display_name can't be longer than %d characters", maxDisplayNameLength)
+	}
+
+	if _, err := time.LoadLocation(preferences.TimeZone); err != nil || preferences.TimeZone == "" {
+		return fmt.Errorf("time_zone %q is not a known time zone", preferences.TimeZone)
+	}
+
+	if preferences.Locale != "" {
+		tag, err := language.Parse(preferences.Locale)
+		if err != nil {
+			return fmt.Errorf("locale %q is not a valid language tag", preferences.Locale)
+		}
+		preferences.Locale = tag.String()
+	}
+
+	if !slices.Contains(itemSorts, preferences.DefaultItemSort) {
+		return fmt.Errorf("default_item_sort must be one of %v", itemSorts)
+	}
+
+	if err := notifications.ValidateMuted(preferences.Notifications.Muted); err != nil {
+		return fmt.Errorf("notifications.muted must only contain %v", notifications.Types)
+	} else if preferences.Notifications.Muted == nil {
+		preferences.Notifications.Muted = []models.NotificationType{}
+	}
+
+	if preferences.DefaultChecklistID != "" {
+		_, role, err := service.GetChecklistAccess(userID, preferences.DefaultChecklistID)
+		if err != nil {
+			return fmt.Errorf("error checking default checklist: %v", err)
+		} else if !role.Can(models.ActionWrite) {
+			return fmt.Errorf("default_checklist_id must be a checklist you can add items to")
+		}
+	}
+
+	return nil
+}
+
+// itemOrder returns the order to list a checklist's items in: the ?sort query parameter if it is valid,
+// or else the user's default item sort.
+func itemOrder(c *gin.Context, service *db.DynamoDBService) (models.ItemSort, error) {
+	if order := models.ItemSort(c.Query("sort")); slices.Contains(itemSorts, order) {
+		return order, nil
+	}
+
+	preferences, err := service.GetUserPreferences(getUserID(c))
+	if err != nil {
+		return "", err
+	}
+
+	return preferences.DefaultItemSort, nil
+}
+
+// sortItems sorts a checklist's items in the given order. Ties keep their manual order.
+func sortItems(items []models.ChecklistItem, order models.ItemSort) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Ordering < items[j].Ordering })
+
+	switch order {
+	case models.ItemSortCreated:
+		sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt < items[j].CreatedAt })
+	case models.ItemSortAlphabetical:
+		sort.SliceStable(items, func(i, j int) bool {
+			return strings.ToLower(items[i].Content) < strings.ToLower(items[j].Content)
+		})
+	case models.ItemSortUncheckedFirst:
+		sort.SliceStable(items, func(i, j int) bool { return !items[i].Checked && items[j].Checked })
+	}
+}
@@ -0,0 +1,127 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/models"
+)
+
+func TestValidatePreferencesRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*models.Preferences)
+	}{
+		{"long display name", func(p *models.Preferences) { p.DisplayName = strings.Repeat("a", maxDisplayNameLength+1) }},
+		{"empty time zone", func(p *models.Preferences) { p.TimeZone = "" }},
+		{"unknown time zone", func(p *models.Preferences) { p.TimeZone = "Mars/Olympus_Mons" }},
+		{"invalid locale", func(p *models.Preferences) { p.Locale = "not a locale" }},
+		{"empty item sort", func(p *models.Preferences) { p.DefaultItemSort = "" }},
+		{"unknown item sort", func(p *models.Preferences) { p.DefaultItemSort = "newest" }},
+		{"unknown muted type", func(p *models.Preferences) { p.Notifications.Muted = []models.NotificationType{"item.deleted"} }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			preferences := models.DefaultPreferences()
+			test.change(&preferences)
+
+			// rejected before the default checklist is looked up, so no service is needed
+			if err := validatePreferences(nil, "alice", &preferences); err == nil {
+				t.Fatalf("Expected preferences with a %s to be rejected", test.name)
+			}
+		})
+	}
+}
+
+func TestValidatePreferencesAccepts(t *testing.T) {
+	preferences := models.DefaultPreferences()
+	preferences.DisplayName = strings.Repeat("é", maxDisplayNameLength)
+	preferences.TimeZone = "Europe/London"
+	preferences.Locale = "en-gb"
+	preferences.DefaultItemSort = models.ItemSortUncheckedFirst
+	preferences.Notifications.Muted = nil
+
+	if err := validatePreferences(nil, "alice", &preferences); err != nil {
+		t.Fatalf("Expected preferences to be valid, but got: %v", err)
+	}
+
+	if preferences.Locale != "en-GB" {
+		t.Fatalf("Expected the locale to be normalised to en-GB, but got %q", preferences.Locale)
+	}
+	if preferences.Notifications.Muted == nil {
+		t.Fatalf("Expected muted notifications to be an empty list, not nil")
+	}
+}
+
+func TestPatchPreferencesMerge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	current := models.DefaultPreferences()
+	current.DisplayName = "Alice"
+	current.TimeZone = "Europe/Paris"
+	current.DefaultItemSort = models.ItemSortAlphabetical
+	current.Notifications.Muted = []models.NotificationType{models.NotificationItemChecked}
+
+	body := `{"locale": "fr", "notifications": {"email": false}}`
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/me/preferences", strings.NewReader(body))
+
+	// PatchMePreferences binds the body onto the stored preferences, so only the fields in it change
+	preferences := current
+	if err := c.BindJSON(&preferences); err != nil {
+		t.Fatalf("Failed to bind body: %v", err)
+	}
+	if err := validatePreferences(nil, "alice", &preferences); err != nil {
+		t.Fatalf("Expected merged preferences to be valid, but got: %v", err)
+	}
+
+	if preferences.Locale != "fr" || preferences.Notifications.Email {
+		t.Fatalf("Expected the locale and email setting to change, but got %+v", preferences)
+	}
+	if preferences.DisplayName != current.DisplayName || preferences.TimeZone != current.TimeZone ||
+		preferences.DefaultItemSort != current.DefaultItemSort {
+		t.Fatalf("Expected preferences left out of the body to be kept, but got %+v", preferences)
+	}
+	if len(preferences.Notifications.Muted) != 1 || preferences.Notifications.Muted[0] != models.NotificationItemChecked {
+		t.Fatalf("Expected muted notifications to be kept, but got %v", preferences.Notifications.Muted)
+	}
+}
+
+func TestSortItems(t *testing.T) {
+	items := []models.ChecklistItem{
+		{ID: "c", Content: "cheese", Ordering: 2, Checked: true, CreatedAt: "2024-01-01T00:00:00Z"},
+		{ID: "a", Content: "Apples", Ordering: 0, Checked: false, CreatedAt: "2024-01-03T00:00:00Z"},
+		{ID: "b", Content: "bread", Ordering: 1, Checked: true, CreatedAt: "2024-01-02T00:00:00Z"},
+		{ID: "d", Content: "Cabbage", Ordering: 3, Checked: false, CreatedAt: "2024-01-02T00:00:00Z"},
+	}
+
+	tests := []struct {
+		order    models.ItemSort
+		expected string
+	}{
+		{models.ItemSortManual, "abcd"},
+		{models.ItemSortCreated, "cbda"},
+		{models.ItemSortAlphabetical, "abdc"},
+		{models.ItemSortUncheckedFirst, "adbc"},
+		{"", "abcd"},
+	}
+
+	for _, test := range tests {
+		sorted := append([]models.ChecklistItem{}, items...)
+		sortItems(sorted, test.order)
+
+		ids := ""
+		for _, item := range sorted {
+			ids += item.ID
+		}
+		if ids != test.expected {
+			t.Errorf("Expected %q order %s, but got %s", test.order, test.expected, ids)
+		}
+	}
+}
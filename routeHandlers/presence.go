@@ -0,0 +1,84 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/db"
+	"checklist-api/models"
+	"checklist-api/realtime"
+)
+
+// GetPresence handles the request to get the other users viewing a checklist.
+func GetPresence(c *gin.Context) {
+	userID := getUserID(c)
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	present, err := realtime.Present(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting presence: " + err.Error(),
+		})
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	viewers := []models.Collaborator{}
+	for _, viewerID := range present {
+		if viewerID == userID {
+			continue
+		}
+
+		user, err := service.GetUser(viewerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error getting user: " + err.Error(),
+			})
+			return
+		}
+		viewers = append(viewers, models.Collaborator{Email: user.Email, Picture: user.Picture})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"viewers": viewers,
+	})
+}
+
+// PostPresence handles a heartbeat from a user viewing a checklist. Clients that don't keep the checklist's
+// event stream open should send one about every 30 seconds.
+func PostPresence(c *gin.Context) {
+	err := realtime.Heartbeat(getOwnerID(c), c.Param("id"), getUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error recording presence: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Presence recorded",
+		})
+	}
+}
+
+// DeletePresence handles the request to stop counting the user as viewing a checklist.
+func DeletePresence(c *gin.Context) {
+	err := realtime.Leave(getOwnerID(c), c.Param("id"), getUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error removing presence: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Presence removed",
+		})
+	}
+}
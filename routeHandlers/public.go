@@ -0,0 +1,182 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+
+	"checklist-api/db"
+	"checklist-api/models"
+	"checklist-api/sharing"
+)
+
+//go:embed templates/public_checklist.html
+var templateFS embed.FS
+
+var publicChecklistTemplate = template.Must(template.ParseFS(templateFS, "templates/public_checklist.html"))
+
+// GetPublicLink handles the request to get the public link token for a checklist.
+func GetPublicLink(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	token, err := service.GetPublicToken(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting public link: " + err.Error(),
+		})
+	} else if token == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Public link is not enabled",
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"token": token,
+		})
+	}
+}
+
+// PutPublicLink handles the request to enable the public link for a checklist.
+// Calling it again rotates the token, revoking the previous link.
+func PutPublicLink(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	token, err := sharing.GeneratePublicToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error generating public token: " + err.Error(),
+		})
+		return
+	}
+
+	err = service.EnablePublicLink(ownerID, checklistID, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error enabling public link: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Public link enabled",
+			"token":   token,
+		})
+	}
+}
+
+// DeletePublicLink handles the request to revoke the public link for a checklist.
+func DeletePublicLink(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	err = service.DisablePublicLink(ownerID, checklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error disabling public link: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Public link disabled",
+		})
+	}
+}
+
+// GetPublicChecklist handles the unauthenticated request for a checklist by its public token.
+// Browsers asking for HTML get a rendered page, everything else gets JSON.
+func GetPublicChecklist(c *gin.Context) {
+	token := c.Param("token")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	ownerID, checklistID, err := service.GetPublicChecklistOwner(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting public link: " + err.Error(),
+		})
+		return
+	} else if checklistID == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Checklist does not exist",
+		})
+		return
+	}
+
+	checklist, checklistErr := service.GetChecklist(ownerID, checklistID)
+	items, itemsErr := service.GetChecklistItems(ownerID, checklistID)
+
+	if checklistErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting checklist: " + checklistErr.Error(),
+		})
+		return
+	} else if itemsErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting items: " + itemsErr.Error(),
+		})
+		return
+	} else if checklist.ID == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Checklist does not exist",
+		})
+		return
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Ordering < items[j].Ordering
+	})
+
+	publicChecklist := models.PublicChecklist{
+		Title:     checklist.Title,
+		Locked:    checklist.Locked,
+		Items:     items,
+		UpdatedAt: checklist.UpdatedAt,
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Render(http.StatusOK, render.HTML{
+			Template: publicChecklistTemplate,
+			Data:     publicChecklist,
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"checklist": publicChecklist,
+		})
+	}
+}
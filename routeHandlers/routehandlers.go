@@ -2,14 +2,18 @@
 package routehandlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"checklist-api/collab"
 	"checklist-api/db"
+	"checklist-api/events"
 	"checklist-api/models"
+	"checklist-api/notifications"
 	"checklist-api/sharing"
 )
 
@@ -21,6 +25,24 @@ func getUserID(c *gin.Context) string {
 	return sub.(string)
 }
 
+// getOwnerID returns the owner of the checklist being accessed, as resolved by middleware.ChecklistAccess.
+func getOwnerID(c *gin.Context) string {
+	ownerID, exist := c.Get("ownerID")
+	if !exist {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+	return ownerID.(string)
+}
+
+// getRole returns the user's role on the checklist being accessed, as resolved by middleware.ChecklistAccess.
+func getRole(c *gin.Context) models.Role {
+	role, exist := c.Get("role")
+	if !exist {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+	return role.(models.Role)
+}
+
 // GetChecklists handles the request to get all checklists.
 func GetChecklists(c *gin.Context) {
 	userID := getUserID(c)
@@ -69,9 +91,9 @@ func GetSharedChecklists(c *gin.Context) {
 	}
 }
 
-// GetChecklist handles the request to get a single checklist.
+// GetChecklist handles the request to get a single checklist, owned or shared.
 func GetChecklist(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
 	id := c.Param("id")
 
 	service, err := db.NewDynamoDBService()
@@ -83,8 +105,8 @@ func GetChecklist(c *gin.Context) {
 		return
 	}
 
-	checklist, checklistErr := service.GetChecklist(userID, id)
-	items, itemsErr := service.GetChecklistItems(userID, id)
+	checklist, checklistErr := service.GetChecklist(ownerID, id)
+	items, itemsErr := service.GetChecklistItems(ownerID, id)
 
 	if checklistErr != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -99,50 +121,15 @@ func GetChecklist(c *gin.Context) {
 			"message": "Checklist does not exist",
 		})
 	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"checklist": checklist,
-			"items":     items,
-		})
-	}
-}
-
-// GetSharedChecklist handles the request to get a shared checklist.
-func GetSharedChecklist(c *gin.Context) {
-	userID := getUserID(c)
-	checklistID := c.Param("id")
-
-	service, err := db.NewDynamoDBService()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error setting up DynamoDBService: " + err.Error(),
-		})
-		return
-	}
-
-	ownerID, err := service.GetChecklistOwner(userID, checklistID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error getting checklist owner: " + err.Error(),
-		})
-		return
-	}
-
-	checklist, checklistErr := service.GetChecklist(ownerID, checklistID)
-	items, itemsErr := service.GetChecklistItems(ownerID, checklistID)
+		order, err := itemOrder(c, service)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error getting preferences: " + err.Error(),
+			})
+			return
+		}
+		sortItems(items, order)
 
-	if checklistErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Error getting checklist: " + checklistErr.Error(),
-		})
-	} else if itemsErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Error getting items: " + itemsErr.Error(),
-		})
-	} else if checklist.ID == "" {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Checklist does not exist",
-		})
-	} else {
 		c.JSON(http.StatusOK, gin.H{
 			"checklist": checklist,
 			"items":     items,
@@ -150,9 +137,10 @@ func GetSharedChecklist(c *gin.Context) {
 	}
 }
 
-// PutChecklist handles the request to update a checklist.
+// PutChecklist handles the request to update a checklist, owned or shared.
 func PutChecklist(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
 
 	service, err := db.NewDynamoDBService()
 	var updatedChecklist models.Checklist
@@ -168,63 +156,64 @@ func PutChecklist(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "Invalid request: " + err.Error(),
 		})
-	} else {
-		updatedChecklist.ID = c.Param("id")
-		updatedChecklist.UpdatedAt = time.Now().Format(time.RFC3339)
-		err := service.UpdateChecklist(userID, updatedChecklist.ID, &updatedChecklist)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Error updating checklist: " + err.Error(),
-			})
-		} else {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Checklist updated",
-			})
-		}
+		return
 	}
-}
 
-// PutSharedChecklist handles the request to update a shared checklist.
-func PutSharedChecklist(c *gin.Context) {
-	userID := getUserID(c)
-	checklistID := c.Param("id")
-
-	service, err := db.NewDynamoDBService()
+	existingChecklist, err := service.GetChecklist(ownerID, checklistID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error setting up DynamoDBService: " + err.Error(),
+			"message": "Error getting checklist: " + err.Error(),
 		})
 		return
 	}
 
-	ownerID, err := service.GetChecklistOwner(userID, checklistID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error getting checklist owner: " + err.Error(),
+	// clients that predate lock modes leave it out, which keeps whatever mode the checklist already has
+	if !updatedChecklist.Locked {
+		updatedChecklist.LockMode = ""
+	} else if updatedChecklist.LockMode == "" {
+		updatedChecklist.LockMode = existingChecklist.LockMode
+		if updatedChecklist.LockMode == "" {
+			updatedChecklist.LockMode = models.LockModeChecks
+		}
+	} else if updatedChecklist.LockMode != models.LockModeChecks && updatedChecklist.LockMode != models.LockModeFrozen {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Lock mode must be checks or frozen",
 		})
 		return
 	}
 
-	var updatedChecklist models.Checklist
-	if err := c.BindJSON(&updatedChecklist); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid request: " + err.Error(),
+	lockChanged := updatedChecklist.Locked != existingChecklist.Locked || updatedChecklist.LockMode != existingChecklist.LockMode
+	if lockChanged && getRole(c) != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only the owner can lock or unlock a checklist",
+		})
+		return
+	}
+
+	updatedChecklist.ID = checklistID
+	updatedChecklist.UpdatedAt = time.Now().Format(time.RFC3339)
+	err = service.UpdateChecklist(ownerID, updatedChecklist.ID, &updatedChecklist)
+
+	if errors.Is(err, db.ErrChecklistLocked) {
+		c.JSON(http.StatusLocked, gin.H{
+			"message": "Checklist is locked",
+		})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error updating checklist: " + err.Error(),
 		})
 	} else {
-		updatedChecklist.ID = checklistID
-		updatedChecklist.UpdatedAt = time.Now().Format(time.RFC3339)
-		err := service.UpdateChecklist(ownerID, updatedChecklist.ID, &updatedChecklist)
+		events.Publish(models.Event{
+			Type:        models.EventChecklistUpdated,
+			ChecklistID: checklistID,
+			Data:        updatedChecklist,
+			OwnerID:     ownerID,
+			ActorID:     getUserID(c),
+		})
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Error updating checklist: " + err.Error(),
-			})
-		} else {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Checklist updated",
-			})
-		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Checklist updated",
+		})
 	}
 }
 
@@ -272,7 +261,7 @@ func PostChecklist(c *gin.Context) {
 
 // DeleteChecklist handles the request to delete a checklist.
 func DeleteChecklist(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
 	id := c.Param("id")
 
 	service, err := db.NewDynamoDBService()
@@ -282,13 +271,34 @@ func DeleteChecklist(c *gin.Context) {
 			"message": "Error setting up DynamoDBService: " + err.Error(),
 		})
 	} else {
-		err := service.DeleteChecklist(userID, id)
-
+		// the collaborators are deleted along with the checklist, so find out who to tell first
+		collaboratorIDs, err := service.GetCollaboratorIDs(ownerID, id)
 		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error getting collaborators: " + err.Error(),
+			})
+			return
+		}
+
+		err = service.DeleteChecklist(ownerID, id)
+
+		if errors.Is(err, db.ErrChecklistLocked) {
+			c.JSON(http.StatusLocked, gin.H{
+				"message": "Checklist is locked",
+			})
+		} else if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"message": "Error deleting checklist: " + err.Error(),
 			})
 		} else {
+			events.Publish(models.Event{
+				Type:        models.EventChecklistDeleted,
+				ChecklistID: id,
+				OwnerID:     ownerID,
+				ActorID:     getUserID(c),
+				Recipients:  append([]string{ownerID}, collaboratorIDs...),
+			})
+
 			c.JSON(http.StatusOK, gin.H{
 				"message": "Checklist deleted",
 			})
@@ -296,11 +306,90 @@ func DeleteChecklist(c *gin.Context) {
 	}
 }
 
+// PostChecklistTransfer handles the request to transfer a checklist to one of its collaborators.
+func PostChecklistTransfer(c *gin.Context) {
+	ownerID := getOwnerID(c)
+	checklistID := c.Param("id")
+
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	} else if request.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Email is required",
+		})
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	newOwnerID, err := service.GetCollaboratorIDByEmail(ownerID, checklistID, request.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error finding collaborator: " + err.Error(),
+		})
+		return
+	} else if newOwnerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "No collaborator on this checklist has that email",
+		})
+		return
+	}
+
+	err = service.TransferChecklist(ownerID, checklistID, newOwnerID)
+	if errors.Is(err, db.ErrNotCollaborator) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Error transferring checklist: " + err.Error(),
+		})
+	} else if errors.Is(err, db.ErrTransferInProgress) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Error transferring checklist: " + err.Error(),
+		})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error transferring checklist: " + err.Error(),
+		})
+	} else {
+		// read back for its new owner and collaborators, which is what clients are sent
+		if checklist, err := service.GetChecklist(newOwnerID, checklistID); err == nil {
+			events.Publish(models.Event{
+				Type:        models.EventChecklistTransferred,
+				ChecklistID: checklistID,
+				Data:        checklist,
+				OwnerID:     newOwnerID,
+				ActorID:     getUserID(c),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Checklist transferred",
+		})
+	}
+}
+
 // LeaveSharedChecklist handles the request to remove a user from a shared checklist.
 func LeaveSharedChecklist(c *gin.Context) {
 	userID := getUserID(c)
 	checklistID := c.Param("id")
 
+	if getRole(c) == models.RoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "You can't leave your own checklist, transfer it to a collaborator first",
+		})
+		return
+	}
+
 	service, err := db.NewDynamoDBService()
 
 	if err != nil {
@@ -315,6 +404,15 @@ func LeaveSharedChecklist(c *gin.Context) {
 				"message": "Error leaving shared checklist: " + err.Error(),
 			})
 		} else {
+			events.Publish(models.Event{
+				Type:        models.EventMembershipRemoved,
+				ChecklistID: checklistID,
+				OwnerID:     getOwnerID(c),
+				ActorID:     userID,
+				Recipients:  []string{userID},
+			})
+			notifications.NotifyOwner(models.NotificationCollaboratorLeft, getOwnerID(c), checklistID, userID, nil)
+
 			c.JSON(http.StatusOK, gin.H{
 				"message": "Left shared checklist",
 			})
@@ -324,10 +422,10 @@ func LeaveSharedChecklist(c *gin.Context) {
 
 // GetShareCode handles the request to generate a share code for a checklist.
 func GetShareCode(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
 	checklistID := c.Param("id")
 
-	code, err := sharing.GetShareCode(checklistID, userID)
+	code, err := sharing.GetShareCode(checklistID, ownerID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -376,21 +474,45 @@ func PostUserToSharedChecklist(c *gin.Context) {
 		return
 	}
 
-	err = service.AddCollaborator(parsedToken.UserID, parsedToken.ChecklistID, userID)
+	// share codes outlive ownership transfers, so make sure the checklist is still where the code says it is
+	checklist, err := service.GetChecklist(parsedToken.UserID, parsedToken.ChecklistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting checklist: " + err.Error(),
+		})
+		return
+	} else if checklist.ID == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Checklist does not exist",
+		})
+		return
+	}
+
+	err = service.AddCollaborator(parsedToken.UserID, parsedToken.ChecklistID, userID, models.RoleEditor)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error adding user to shared checklist: " + err.Error(),
 		})
 	} else {
+		events.Publish(models.Event{
+			Type:        models.EventMembershipAdded,
+			ChecklistID: checklist.ID,
+			Data:        checklist,
+			OwnerID:     parsedToken.UserID,
+			ActorID:     userID,
+			Recipients:  []string{userID},
+		})
+		notifications.NotifyOwner(models.NotificationCollaboratorJoined, parsedToken.UserID, checklist.ID, userID, nil)
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "User added to shared checklist",
 		})
 	}
 }
 
-// PostItem handles the request to add an item to a checklist.
+// PostItem handles the request to add an item to a checklist, owned or shared.
 func PostItem(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
 	checklistID := c.Param("id")
 	var newItem models.ChecklistItem
 
@@ -410,60 +532,29 @@ func PostItem(c *gin.Context) {
 		newItem.CreatedAt = time.Now().Format(time.RFC3339)
 		newItem.UpdatedAt = newItem.CreatedAt
 
-		err := service.CreateChecklistItem(userID, checklistID, &newItem)
+		err := service.CreateChecklistItem(ownerID, checklistID, &newItem)
 
-		if err != nil {
+		if errors.Is(err, db.ErrChecklistLocked) {
+			c.JSON(http.StatusLocked, gin.H{
+				"message": "Checklist is locked",
+			})
+		} else if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"message": "Error creating item: " + err.Error(),
 			})
 		} else {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Item created",
-				"item":    newItem,
+			events.Publish(models.Event{
+				Type:        models.EventItemCreated,
+				ChecklistID: checklistID,
+				ItemID:      newItem.ID,
+				Data:        newItem,
+				OwnerID:     ownerID,
+				ActorID:     getUserID(c),
 			})
-		}
-	}
-}
-
-// PostSharedItem handles the request to add an item to a shared checklist.
-func PostSharedItem(c *gin.Context) {
-	userID := getUserID(c)
-	checklistID := c.Param("id")
-
-	service, err := db.NewDynamoDBService()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error setting up DynamoDBService: " + err.Error(),
-		})
-		return
-	}
+			if !newItem.Checked {
+				notifications.Reopened(checklistID)
+			}
 
-	ownerID, err := service.GetChecklistOwner(userID, checklistID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error getting checklist owner: " + err.Error(),
-		})
-		return
-	}
-
-	var newItem models.ChecklistItem
-	if err := c.BindJSON(&newItem); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid request: " + err.Error(),
-		})
-	} else {
-		newItem.ID = uuid.New().String()
-		newItem.Checked = false
-		newItem.CreatedAt = time.Now().Format(time.RFC3339)
-		newItem.UpdatedAt = newItem.CreatedAt
-
-		err := service.CreateChecklistItem(ownerID, checklistID, &newItem)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Error creating item: " + err.Error(),
-			})
-		} else {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "Item created",
 				"item":    newItem,
@@ -472,9 +563,9 @@ func PostSharedItem(c *gin.Context) {
 	}
 }
 
-// PutItem handles the request to update an item in a checklist.
+// PutItem handles the request to update an item in a checklist, owned or shared.
 func PutItem(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
 	checklistID := c.Param("id")
 	itemID := c.Param("itemID")
 
@@ -496,67 +587,44 @@ func PutItem(c *gin.Context) {
 		return
 	}
 
-	updatedItem.UpdatedAt = time.Now().Format(time.RFC3339)
-	err = service.UpdateChecklistItem(userID, checklistID, itemID, &updatedItem)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error updating item: " + err.Error(),
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Item updated",
-		})
-	}
-}
-
-// PutSharedItem handles the request to update an item in a shared checklist.
-func PutSharedItem(c *gin.Context) {
-	userID := getUserID(c)
-	checklistID := c.Param("id")
-	itemID := c.Param("itemID")
-
-	service, err := db.NewDynamoDBService()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error setting up DynamoDBService: " + err.Error(),
-		})
+	// content goes through the same path as operations, so clients editing it concurrently can merge with this
+	item, edit, err := collab.Replace(ownerID, checklistID, itemID, updatedItem.Content)
+	if !respondToEditError(c, err) {
 		return
 	}
 
-	ownerID, err := service.GetChecklistOwner(userID, checklistID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error getting checklist owner: " + err.Error(),
-		})
-		return
-	}
+	wasChecked := item.Checked
+	item.Checked = updatedItem.Checked
+	item.Ordering = updatedItem.Ordering
+	item.UpdatedAt = time.Now().Format(time.RFC3339)
+	err = service.UpdateChecklistItem(ownerID, checklistID, itemID, &item)
 
-	var updatedItem models.ChecklistItem
-	if err := c.BindJSON(&updatedItem); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid request",
+	if errors.Is(err, db.ErrChecklistLocked) {
+		c.JSON(http.StatusLocked, gin.H{
+			"message": "Checklist is locked",
 		})
-		return
-	}
-
-	updatedItem.UpdatedAt = time.Now().Format(time.RFC3339)
-	err = service.UpdateChecklistItem(ownerID, checklistID, itemID, &updatedItem)
-
-	if err != nil {
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error updating item: " + err.Error(),
 		})
 	} else {
+		publishItemEdit(c, ownerID, checklistID, item, edit)
+		if item.Checked && !wasChecked {
+			notifications.NotifyOwner(models.NotificationItemChecked, ownerID, checklistID, getUserID(c), &item)
+			notifications.NotifyIfCompleted(ownerID, checklistID, getUserID(c))
+		} else if !item.Checked && wasChecked {
+			notifications.Reopened(checklistID)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Item updated",
 		})
 	}
 }
 
-// PutAllItems handles the request to update all items in a checklist.
+// PutAllItems handles the request to update all items in a checklist, owned or shared.
 func PutAllItems(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
 	checklistID := c.Param("id")
 	checked := c.Query("checked") == "true"
 	service, err := db.NewDynamoDBService()
@@ -568,57 +636,40 @@ func PutAllItems(c *gin.Context) {
 		return
 	}
 
-	err = service.UpdateChecklistItems(userID, checklistID, checked)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error updating items: " + err.Error(),
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Items updated",
-		})
-	}
-}
-
-// PutAllSharedItems handles the request to update all items in a shared checklist.
-func PutAllSharedItems(c *gin.Context) {
-	userID := getUserID(c)
-	checklistID := c.Param("id")
-	checked := c.Query("checked") == "true"
-
-	service, err := db.NewDynamoDBService()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error setting up DynamoDBService: " + err.Error(),
-		})
-		return
-	}
-
-	ownerID, err := service.GetChecklistOwner(userID, checklistID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error getting checklist owner: " + err.Error(),
-		})
-		return
-	}
-
 	err = service.UpdateChecklistItems(ownerID, checklistID, checked)
 
-	if err != nil {
+	if errors.Is(err, db.ErrChecklistLocked) {
+		c.JSON(http.StatusLocked, gin.H{
+			"message": "Checklist is locked",
+		})
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error updating items: " + err.Error(),
 		})
 	} else {
+		events.Publish(models.Event{
+			Type:        models.EventItemsUpdated,
+			ChecklistID: checklistID,
+			Data:        gin.H{"checked": checked},
+			OwnerID:     ownerID,
+			ActorID:     getUserID(c),
+		})
+		if checked {
+			notifications.NotifyOwner(models.NotificationItemChecked, ownerID, checklistID, getUserID(c), nil)
+			notifications.NotifyIfCompleted(ownerID, checklistID, getUserID(c))
+		} else {
+			notifications.Reopened(checklistID)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Items updated",
 		})
 	}
 }
 
-// DeleteItem handles the request to delete an item from a checklist.
+// DeleteItem handles the request to delete an item from a checklist, owned or shared.
 func DeleteItem(c *gin.Context) {
-	userID := getUserID(c)
+	ownerID := getOwnerID(c)
 	checklistID := c.Param("id")
 	itemID := c.Param("itemID")
 
@@ -631,101 +682,27 @@ func DeleteItem(c *gin.Context) {
 		return
 	}
 
-	err = service.DeleteChecklistItem(userID, checklistID, itemID)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error deleting item: " + err.Error(),
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Item deleted",
-		})
-	}
-}
-
-// DeleteSharedItem handles the request to delete an item from a shared checklist.
-func DeleteSharedItem(c *gin.Context) {
-	userID := getUserID(c)
-	checklistID := c.Param("id")
-	itemID := c.Param("itemID")
-
-	service, err := db.NewDynamoDBService()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error setting up DynamoDBService: " + err.Error(),
-		})
-		return
-	}
-
-	ownerID, err := service.GetChecklistOwner(userID, checklistID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error getting checklist owner: " + err.Error(),
-		})
-		return
-	}
-
 	err = service.DeleteChecklistItem(ownerID, checklistID, itemID)
 
-	if err != nil {
+	if errors.Is(err, db.ErrChecklistLocked) {
+		c.JSON(http.StatusLocked, gin.H{
+			"message": "Checklist is locked",
+		})
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error deleting item: " + err.Error(),
 		})
 	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Item deleted",
-		})
-	}
-}
-
-// PostUser handles the request to create a new user. It updates an existing user if the ID already exists.
-func PostUser(c *gin.Context) {
-	var user models.User
-	if err := c.BindJSON(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error parsing user info: " + err.Error(),
-		})
-		return
-	}
-
-	service, err := db.NewDynamoDBService()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error setting up DynamoDBService: " + err.Error(),
+		events.Publish(models.Event{
+			Type:        models.EventItemDeleted,
+			ChecklistID: checklistID,
+			ItemID:      itemID,
+			OwnerID:     ownerID,
+			ActorID:     getUserID(c),
 		})
-		return
-	}
 
-	existingUser, err := service.GetUser(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Error checking for user: " + err.Error(),
-		})
-		return
-	}
-
-	if existingUser.ID != "" {
-		err = service.UpdateUser(user.ID, user.Email, user.Picture)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Error updating user: " + err.Error(),
-			})
-			return
-		}
 		c.JSON(http.StatusOK, gin.H{
-			"message": "User updated",
-		})
-	} else {
-		err = service.CreateUser(user.ID, user.Email, user.Picture)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Error creating user: " + err.Error(),
-			})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "User created",
+			"message": "Item deleted",
 		})
 	}
 }
@@ -0,0 +1,168 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/middleware"
+	"checklist-api/models"
+)
+
+// accessTokenScopes are the scopes a personal access token can be created with.
+var accessTokenScopes = []string{models.ScopeChecklistsRead, models.ScopeChecklistsWrite, models.ScopeChecklistsShare}
+
+// GetAccessTokens handles the request to list the user's personal access tokens.
+func GetAccessTokens(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	tokens, err := service.GetAccessTokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting tokens: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"tokens": tokens,
+		})
+	}
+}
+
+// PostAccessToken handles the request to create a personal access token.
+// The token is only ever returned in this response.
+func PostAccessToken(c *gin.Context) {
+	userID := getUserID(c)
+
+	// a leaked token shouldn't be able to mint more tokens
+	if c.GetString("authMethod") == "pat" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Personal access tokens can't create other tokens",
+		})
+		return
+	}
+
+	var request struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	} else if request.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Name is required",
+		})
+		return
+	} else if len(request.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "At least one scope is required",
+		})
+		return
+	} else if request.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Expiry can't be in the past",
+		})
+		return
+	}
+
+	// a token can't be granted more than the caller's own token has
+	granted := c.GetStringSlice("scopes")
+	for _, scope := range request.Scopes {
+		if !slices.Contains(accessTokenScopes, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unknown scope: " + scope,
+			})
+			return
+		} else if !slices.Contains(granted, scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "You can't grant a scope you don't have: " + scope,
+			})
+			return
+		}
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	tokenString, tokenHash, err := middleware.NewPersonalAccessToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error generating token: " + err.Error(),
+		})
+		return
+	}
+
+	slices.Sort(request.Scopes)
+	token := models.AccessToken{
+		ID:        uuid.New().String(),
+		Name:      request.Name,
+		Scopes:    slices.Compact(request.Scopes),
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if request.ExpiresInDays > 0 {
+		token.ExpiresAt = time.Now().AddDate(0, 0, request.ExpiresInDays).Format(time.RFC3339)
+	}
+
+	err = service.CreateAccessToken(userID, tokenHash, &token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error creating token: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Token created",
+			"token":        token,
+			"access_token": tokenString,
+		})
+	}
+}
+
+// DeleteAccessToken handles the request to revoke a personal access token.
+func DeleteAccessToken(c *gin.Context) {
+	userID := getUserID(c)
+	tokenID := c.Param("tokenID")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	found, err := service.DeleteAccessToken(userID, tokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error revoking token: " + err.Error(),
+		})
+	} else if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Token does not exist",
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Token revoked",
+		})
+	}
+}
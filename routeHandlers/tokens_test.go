@@ -0,0 +1,34 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/models"
+)
+
+func TestPostAccessTokenRejectsScopesTheCallerLacks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	body := `{"name": "sharing bot", "scopes": ["checklists:write", "checklists:share"]}`
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tokens", strings.NewReader(body))
+	c.Set("sub", "alice")
+	c.Set("scopes", []string{models.ScopeChecklistsWrite})
+
+	// rejected before the token is stored, so no service is needed
+	PostAccessToken(c)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusForbidden, recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), models.ScopeChecklistsShare) {
+		t.Fatalf("Expected the response to name the %s scope, but got %s", models.ScopeChecklistsShare, recorder.Body.String())
+	}
+}
@@ -0,0 +1,261 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"checklist-api/accounts"
+	"checklist-api/db"
+	"checklist-api/middleware"
+	"checklist-api/models"
+	"checklist-api/onboarding"
+)
+
+// GetMe handles the request to get the authenticated user's profile.
+func GetMe(c *gin.Context) {
+	getUser(c, getUserID(c))
+}
+
+// PutMe handles the request to create or update the authenticated user's profile. The ID comes from the token,
+// and the email and picture from the identity provider's verified claims. Values in the body are optional,
+// and rejected if they don't match what the provider vouches for.
+// It also serves the deprecated POST /user, whose clients send the profile in the body.
+func PutMe(c *gin.Context) {
+	userID := getUserID(c)
+
+	var request models.User
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Error parsing user info: " + err.Error(),
+		})
+		return
+	}
+
+	verified, err := middleware.VerifiedProfile(c)
+	if errors.Is(err, middleware.ErrProfileUnverifiable) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"message": "Error verifying profile: " + err.Error(),
+		})
+		return
+	}
+
+	if request.ID != "" && request.ID != userID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You can only update your own profile",
+		})
+		return
+	} else if request.Email != "" && request.Email != verified.Email {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Email doesn't match the one verified by your identity provider",
+		})
+		return
+	} else if request.Picture != "" && request.Picture != verified.Picture {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Picture doesn't match the one from your identity provider",
+		})
+		return
+	}
+
+	saveUser(c, verified)
+}
+
+// DeleteMe handles the request to delete the authenticated user's account and everything stored about them.
+// The deletion happens after the grace period, and can be cancelled until then.
+func DeleteMe(c *gin.Context) {
+	// a leaked token shouldn't be able to delete the account it belongs to
+	if c.GetString("authMethod") == "pat" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Personal access tokens can't delete accounts",
+		})
+		return
+	}
+
+	deleteAccount(c, getUserID(c))
+}
+
+// DeleteMeDeletion handles the request to cancel the authenticated user's scheduled account deletion.
+func DeleteMeDeletion(c *gin.Context) {
+	cancelled, err := accounts.CancelDeletion(getUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error cancelling account deletion: " + err.Error(),
+		})
+	} else if !cancelled {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No account deletion is scheduled",
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Account deletion cancelled",
+		})
+	}
+}
+
+// PostMeOnboarding handles the request to create the onboarding checklist again, for example after the user changed
+// their locale. It uses the user's locale preference, or their Accept-Language header if they have none.
+func PostMeOnboarding(c *gin.Context) {
+	userID := getUserID(c)
+
+	if !onboarding.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Onboarding is turned off",
+		})
+		return
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	preferences, err := service.GetUserPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting preferences: " + err.Error(),
+		})
+		return
+	}
+
+	template := onboarding.Select(preferences.Locale, c.GetHeader("Accept-Language"))
+	checklist, err := onboarding.CreateChecklist(service, userID, template)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error creating onboarding checklist: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Checklist created",
+			"checklist": checklist,
+		})
+	}
+}
+
+// GetAdminUser handles an admin's request to get any user's profile.
+func GetAdminUser(c *gin.Context) {
+	getUser(c, c.Param("id"))
+}
+
+// PutAdminUser handles an admin's request to create or update any user's profile. The profile is taken from the body
+// as is, since there are no claims to check it against.
+func PutAdminUser(c *gin.Context) {
+	var user models.User
+	if err := c.BindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Error parsing user info: " + err.Error(),
+		})
+		return
+	}
+	user.ID = c.Param("id")
+
+	saveUser(c, user)
+}
+
+// DeleteAdminUser handles an admin's request to delete any user's account, with the same grace period as DELETE /me.
+func DeleteAdminUser(c *gin.Context) {
+	deleteAccount(c, c.Param("id"))
+}
+
+// deleteAccount schedules the user's account for deletion, and responds with when it will happen.
+func deleteAccount(c *gin.Context, userID string) {
+	scheduledFor, deleted, err := accounts.RequestDeletion(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error deleting account: " + err.Error(),
+		})
+	} else if deleted {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Account deleted",
+		})
+	} else {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":                "Account deletion scheduled",
+			"deletion_scheduled_for": scheduledFor.Format(time.RFC3339),
+		})
+	}
+}
+
+// getUser responds with the user's profile, or a 404 if they have none.
+func getUser(c *gin.Context, userID string) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := service.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting user: " + err.Error(),
+		})
+	} else if user.ID == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "User not found",
+		})
+	} else {
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// saveUser creates the user, or updates them if they already exist, and responds with their profile.
+func saveUser(c *gin.Context, user models.User) {
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	existingUser, err := service.GetUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error checking for user: " + err.Error(),
+		})
+		return
+	}
+
+	if existingUser.ID != "" {
+		err = service.UpdateUser(user.ID, user.Email, user.Picture)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error updating user: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	} else {
+		err = service.CreateUser(user.ID, user.Email, user.Picture)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error creating user: " + err.Error(),
+			})
+			return
+		}
+
+		if onboarding.Enabled() {
+			template := onboarding.Select("", c.GetHeader("Accept-Language"))
+			if _, err := onboarding.CreateChecklist(service, user.ID, template); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Error creating onboarding checklist: " + err.Error(),
+				})
+				return
+			}
+		}
+		c.JSON(http.StatusCreated, user)
+	}
+}
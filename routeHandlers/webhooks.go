@@ -0,0 +1,246 @@
+// Package routehandlers provides the route handlers for the application.
+package routehandlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/models"
+	"checklist-api/webhooks"
+)
+
+// GetWebhooks handles the request to list the user's webhooks.
+func GetWebhooks(c *gin.Context) {
+	userID := getUserID(c)
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	list, err := service.GetWebhooks(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting webhooks: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"webhooks": list,
+		})
+	}
+}
+
+// PostWebhook handles the request to create a webhook, for one checklist or every checklist the user can see.
+// The secret its deliveries are signed with is only ever returned in this response.
+func PostWebhook(c *gin.Context) {
+	userID := getUserID(c)
+
+	if !webhooks.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message": "Webhooks are off, the change streams aren't read",
+		})
+		return
+	}
+
+	var request struct {
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		ChecklistID string   `json:"checklist_id"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	} else if err := webhooks.ValidateURL(request.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid URL: " + err.Error(),
+		})
+		return
+	} else if len(request.Events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "At least one event is required",
+		})
+		return
+	}
+
+	for _, event := range request.Events {
+		if !slices.Contains(webhooks.Events, event) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unknown event: " + event,
+			})
+			return
+		}
+	}
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	if request.ChecklistID != "" {
+		ownerID, _, err := service.GetChecklistAccess(userID, request.ChecklistID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Error getting checklist: " + err.Error(),
+			})
+			return
+		} else if ownerID == "" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Checklist does not exist",
+			})
+			return
+		}
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error generating secret: " + err.Error(),
+		})
+		return
+	}
+
+	slices.Sort(request.Events)
+	webhook := models.Webhook{
+		ID:          uuid.New().String(),
+		URL:         request.URL,
+		Events:      slices.Compact(request.Events),
+		ChecklistID: request.ChecklistID,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	}
+
+	err = service.CreateWebhook(userID, secret, &webhook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error creating webhook: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Webhook created",
+			"webhook": webhook,
+			"secret":  secret,
+		})
+	}
+}
+
+// DeleteWebhook handles the request to delete one of the user's webhooks. Its pending deliveries fail.
+func DeleteWebhook(c *gin.Context) {
+	userID := getUserID(c)
+	webhookID := c.Param("webhookID")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return
+	}
+
+	found, err := service.DeleteWebhook(userID, webhookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error deleting webhook: " + err.Error(),
+		})
+	} else if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Webhook does not exist",
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Webhook deleted",
+		})
+	}
+}
+
+// GetWebhookDeliveries handles the request to list a webhook's recent deliveries.
+func GetWebhookDeliveries(c *gin.Context) {
+	webhookID, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := webhooks.Deliveries(webhookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting deliveries: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"deliveries": deliveries,
+		})
+	}
+}
+
+// PostWebhookRedelivery handles the request to send one of a webhook's past deliveries again, as a new delivery.
+func PostWebhookRedelivery(c *gin.Context) {
+	if !webhooks.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message": "Webhooks are off, the change streams aren't read",
+		})
+		return
+	}
+
+	webhookID, ok := ownWebhook(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := webhooks.Redeliver(webhookID, c.Param("deliveryID"))
+	if errors.Is(err, webhooks.ErrDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Delivery does not exist",
+		})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error redelivering: " + err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Delivery queued",
+			"delivery": delivery,
+		})
+	}
+}
+
+// ownWebhook checks that the webhook in the path belongs to the user, and returns its ID. If it doesn't, the response
+// has been sent and it returns false.
+func ownWebhook(c *gin.Context) (string, bool) {
+	userID := getUserID(c)
+	webhookID := c.Param("webhookID")
+
+	service, err := db.NewDynamoDBService()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error setting up DynamoDBService: " + err.Error(),
+		})
+		return "", false
+	}
+
+	ownerID, _, _, err := service.GetWebhook(webhookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error getting webhook: " + err.Error(),
+		})
+		return "", false
+	} else if ownerID != userID {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Webhook does not exist",
+		})
+		return "", false
+	}
+
+	return webhookID, true
+}
@@ -2,7 +2,9 @@
 package sharing
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
@@ -83,6 +85,11 @@ func GetShareCode(checklistID string, userID string) (string, error) {
 		return "error saving token in redis", err
 	}
 
+	err = service.AddUserShareCode(userID, shortCode)
+	if err != nil {
+		return "error saving share code in redis", err
+	}
+
 	return shortCode, nil
 }
 
@@ -100,3 +107,13 @@ func GetTokenFromShareCode(shareCode string) (string, error) {
 
 	return token, nil
 }
+
+// GeneratePublicToken creates a random, URL safe token for a checklist's public link.
+func GeneratePublicToken() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -121,3 +121,23 @@ func TestGetTokenFromShareCode(t *testing.T) {
 		t.Fatalf("Expected userID %s to equal %s", claims.UserID, userID)
 	}
 }
+
+func TestGeneratePublicToken(t *testing.T) {
+	token, err := GeneratePublicToken()
+	if err != nil {
+		t.Fatalf("Failed to generate public token: %v", err)
+	}
+
+	if len(token) != 24 {
+		t.Fatalf("Expected a 24 character token, but got %q", token)
+	}
+
+	other, err := GeneratePublicToken()
+	if err != nil {
+		t.Fatalf("Failed to generate public token: %v", err)
+	}
+
+	if token == other {
+		t.Fatalf("Expected unique tokens, but got %s twice", token)
+	}
+}
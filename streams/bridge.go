@@ -0,0 +1,93 @@
+// Package streams reads the change streams of the Checklists and ChecklistCollaborators tables.
+package streams
+
+import (
+	"os"
+	"time"
+
+	"checklist-api/db"
+	"checklist-api/events"
+	"checklist-api/models"
+)
+
+// StreamedEventTypes are the events that come from the streams when realtime updates are read from them.
+// A transfer's moved records aren't changes, so it arrives as one checklist.transferred event.
+// The others stay with the handlers: item.updated events carry the operation behind an edit, items.updated is one
+// event for a whole checklist, checklist.deleted needs the collaborators from before the deletion, and presence
+// isn't stored in the tables.
+var StreamedEventTypes = []models.EventType{
+	models.EventChecklistUpdated,
+	models.EventChecklistTransferred,
+	models.EventItemCreated,
+	models.EventItemDeleted,
+	models.EventMembershipAdded,
+	models.EventMembershipRemoved,
+}
+
+// RealtimeFromStreams reports whether realtime updates are read from the streams, so they include changes made
+// outside the API. REALTIME_SOURCE=streams turns it on.
+func RealtimeFromStreams() bool {
+	return os.Getenv("REALTIME_SOURCE") == "streams"
+}
+
+// EventsBridge is a Handler that publishes changes as events, for realtime updates.
+type EventsBridge struct {
+	dynamo *db.DynamoDBService
+}
+
+// NewEventsBridge sets up an EventsBridge.
+func NewEventsBridge() (*EventsBridge, error) {
+	dynamo, err := db.NewDynamoDBService()
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventsBridge{dynamo: dynamo}, nil
+}
+
+// HandleChange publishes the event for a change, if there is one.
+func (b *EventsBridge) HandleChange(change Change) error {
+	event := models.Event{
+		ChecklistID: change.ChecklistID,
+		ItemID:      change.ItemID,
+		Time:        change.Time.Format(time.RFC3339),
+		OwnerID:     change.OwnerID,
+	}
+
+	switch change.Type {
+	case ChecklistUpdated:
+		checklist, err := b.dynamo.GetChecklist(change.OwnerID, change.ChecklistID)
+		if err != nil || checklist.ID == "" {
+			// a checklist deleted since is left to its checklist.deleted event
+			return err
+		}
+		event.Type, event.Data = models.EventChecklistUpdated, checklist
+	case ChecklistDeleted:
+		event.Type = models.EventChecklistDeleted
+	case ChecklistTransferred:
+		checklist, err := b.dynamo.GetChecklist(change.OwnerID, change.ChecklistID)
+		if err != nil || checklist.ID == "" {
+			return err
+		}
+		event.Type, event.Data, event.ActorID = models.EventChecklistTransferred, checklist, change.UserID
+	case ItemCreated:
+		event.Type, event.Data = models.EventItemCreated, change.Item
+	case ItemUpdated, ItemChecked, ItemUnchecked:
+		event.Type, event.Data = models.EventItemUpdated, change.Item
+	case ItemDeleted:
+		event.Type = models.EventItemDeleted
+	case CollaboratorJoined:
+		checklist, err := b.dynamo.GetChecklist(change.OwnerID, change.ChecklistID)
+		if err != nil || checklist.ID == "" {
+			return err
+		}
+		event.Type, event.Data, event.Recipients = models.EventMembershipAdded, checklist, []string{change.UserID}
+	case CollaboratorLeft:
+		event.Type, event.Recipients = models.EventMembershipRemoved, []string{change.UserID}
+	default:
+		return nil
+	}
+
+	events.PublishFromStream(event)
+	return nil
+}
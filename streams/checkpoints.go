@@ -0,0 +1,67 @@
+// Package streams reads the change streams of the Checklists and ChecklistCollaborators tables.
+package streams
+
+import (
+	"sync"
+	"time"
+
+	"checklist-api/db"
+)
+
+// checkpointTTL is how long a shard's checkpoint is kept. Stream records are kept for 24 hours, so a checkpoint is
+// no use after that.
+const checkpointTTL = 48 * time.Hour
+
+// Checkpoints records how far each shard has been read, and which API instance reads the streams.
+type Checkpoints interface {
+	// Get returns a shard's checkpoint, or an empty string if it hasn't been read.
+	Get(streamARN string, shardID string) (string, error)
+	Set(streamARN string, shardID string, checkpoint string) error
+	// Lease makes holder the instance that reads the streams for ttl, or extends its lease. It returns false if
+	// another instance holds the lease.
+	Lease(holder string, ttl time.Duration) (bool, error)
+}
+
+// redisCheckpoints keeps checkpoints in Redis, so they survive restarts and are shared between instances.
+type redisCheckpoints struct {
+	redis *db.RedisService
+}
+
+func (c redisCheckpoints) Get(streamARN string, shardID string) (string, error) {
+	return c.redis.GetStreamCheckpoint(streamARN, shardID)
+}
+
+func (c redisCheckpoints) Set(streamARN string, shardID string, checkpoint string) error {
+	return c.redis.SetStreamCheckpoint(streamARN, shardID, checkpoint, checkpointTTL)
+}
+
+func (c redisCheckpoints) Lease(holder string, ttl time.Duration) (bool, error) {
+	return c.redis.AcquireStreamLease(holder, ttl)
+}
+
+// memoryCheckpoints keeps checkpoints in memory, for running locally without Redis. The streams are read from the
+// start again after every restart.
+type memoryCheckpoints struct {
+	mu          sync.Mutex
+	checkpoints map[string]string
+}
+
+func (c *memoryCheckpoints) Get(streamARN string, shardID string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.checkpoints[streamARN+":"+shardID], nil
+}
+
+func (c *memoryCheckpoints) Set(streamARN string, shardID string, checkpoint string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkpoints[streamARN+":"+shardID] = checkpoint
+	return nil
+}
+
+// Lease always succeeds, since there is no other instance to share with.
+func (c *memoryCheckpoints) Lease(string, time.Duration) (bool, error) {
+	return true, nil
+}
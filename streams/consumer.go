@@ -0,0 +1,296 @@
+// Package streams reads the change streams of the Checklists and ChecklistCollaborators tables.
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+)
+
+// Tables are the tables whose streams are read.
+var Tables = []string{"Checklists", "ChecklistCollaborators"}
+
+const (
+	// pollInterval is how often each shard is read.
+	pollInterval = time.Second
+	// shardRefreshInterval is how often the streams are checked for new shards, which DynamoDB adds every few hours.
+	shardRefreshInterval = time.Minute
+	// leaseTTL is how long an instance reads the streams for after it last renewed its lease.
+	leaseTTL = 30 * time.Second
+	// recordsPerPoll is the most records read from a shard at once.
+	recordsPerPoll = 1000
+	// shardEnd is the checkpoint of a shard that has been read to its end.
+	shardEnd = "SHARD_END"
+)
+
+// shard is a shard of a table's stream, and how far it has been read.
+type shard struct {
+	table     string
+	streamARN string
+	id        string
+	parentID  string
+	iterator  *string
+	done      bool
+	// childrenFromStart is set for shards whose children are read from their start, so nothing is missed between
+	// them. That is every shard except the ones that had already closed, unread, when the consumer first started.
+	childrenFromStart bool
+}
+
+// Consumer reads the tables' streams, and delivers their changes to the registered handlers. Only one API instance
+// reads the streams at a time, and shards are checkpointed as they are read, so another instance can take over.
+type Consumer struct {
+	dynamo      *db.DynamoDBService
+	client      *dynamodbstreams.Client
+	checkpoints Checkpoints
+	holder      string
+	// startAt is where shards without a checkpoint are read from, when the consumer first starts
+	startAt     types.ShardIteratorType
+	shards      map[string]*shard
+	refreshedAt time.Time
+}
+
+// NewConsumer sets up a consumer. Checkpoints are kept in Redis. When running locally without Redis they are kept
+// in memory instead, and the streams are read from the start.
+func NewConsumer() (*Consumer, error) {
+	dynamo, err := db.NewDynamoDBService()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := db.NewDynamoDBStreamsClient()
+	if err != nil {
+		return nil, err
+	}
+
+	consumer := &Consumer{
+		dynamo:  dynamo,
+		client:  client,
+		holder:  uuid.New().String(),
+		startAt: types.ShardIteratorTypeLatest,
+		shards:  map[string]*shard{},
+	}
+	if os.Getenv("STREAMS_START_POSITION") == "trim_horizon" || Local() {
+		consumer.startAt = types.ShardIteratorTypeTrimHorizon
+	}
+
+	redisService, err := db.NewRedisService()
+	if err == nil {
+		consumer.checkpoints = redisCheckpoints{redis: redisService}
+	} else if Local() {
+		fmt.Println("Stream checkpoints are kept in memory, Redis is unavailable: " + err.Error())
+		consumer.checkpoints = &memoryCheckpoints{checkpoints: map[string]string{}}
+	} else {
+		return nil, err
+	}
+
+	return consumer, nil
+}
+
+// Run reads the streams until ctx is done, while this instance holds the lease.
+func (c *Consumer) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		leader, err := c.checkpoints.Lease(c.holder, leaseTTL)
+		if err != nil {
+			fmt.Println("Error renewing stream lease: " + err.Error())
+			continue
+		} else if !leader {
+			// another instance reads the streams, and this one starts over from the checkpoints if it takes over
+			c.shards = map[string]*shard{}
+			continue
+		}
+
+		if time.Since(c.refreshedAt) > shardRefreshInterval {
+			if err := c.refreshShards(ctx); err != nil {
+				fmt.Println("Error listing stream shards: " + err.Error())
+				continue
+			}
+		}
+
+		for _, s := range c.shards {
+			if parent, ok := c.shards[s.parentID]; s.done || (ok && !parent.done) {
+				// children wait until their parent is read, so changes to a record are delivered in order
+				continue
+			}
+
+			if err := c.readShard(ctx, s); err != nil {
+				fmt.Printf("Error reading shard %s of the %s stream: %v\n", s.id, s.table, err)
+			}
+		}
+	}
+}
+
+// refreshShards lists the shards of each table's stream, adding new ones and forgetting the ones that have expired.
+func (c *Consumer) refreshShards(ctx context.Context) error {
+	found := map[string]bool{}
+
+	for _, table := range Tables {
+		streamARN, err := c.dynamo.GetStreamARN(table)
+		if err != nil {
+			return err
+		} else if streamARN == "" {
+			fmt.Printf("The %s table's stream is off, its changes won't be read\n", table)
+			continue
+		}
+
+		var startShardID *string
+		for {
+			output, err := c.client.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+				StreamArn:             aws.String(streamARN),
+				ExclusiveStartShardId: startShardID,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to describe stream, %v", err)
+			}
+
+			for _, description := range output.StreamDescription.Shards {
+				id := aws.ToString(description.ShardId)
+				found[id] = true
+				if _, ok := c.shards[id]; ok {
+					continue
+				}
+
+				s, err := c.newShard(table, streamARN, description)
+				if err != nil {
+					return err
+				}
+				c.shards[id] = s
+			}
+
+			startShardID = output.StreamDescription.LastEvaluatedShardId
+			if startShardID == nil {
+				break
+			}
+		}
+	}
+
+	for id := range c.shards {
+		if !found[id] {
+			delete(c.shards, id)
+		}
+	}
+
+	c.refreshedAt = time.Now()
+	return nil
+}
+
+func (c *Consumer) newShard(table string, streamARN string, description types.Shard) (*shard, error) {
+	s := &shard{
+		table:     table,
+		streamARN: streamARN,
+		id:        aws.ToString(description.ShardId),
+		parentID:  aws.ToString(description.ParentShardId),
+	}
+
+	checkpoint, err := c.checkpoints.Get(streamARN, s.id)
+	if err != nil {
+		return nil, err
+	}
+
+	open := description.SequenceNumberRange == nil || description.SequenceNumberRange.EndingSequenceNumber == nil
+	s.done = checkpoint == shardEnd
+	s.childrenFromStart = checkpoint != "" || open || c.startAt == types.ShardIteratorTypeTrimHorizon
+
+	return s, nil
+}
+
+// readShard delivers the next records from a shard, and checkpoints them.
+func (c *Consumer) readShard(ctx context.Context, s *shard) error {
+	if s.iterator == nil {
+		iterator, err := c.shardIterator(ctx, s)
+		if err != nil {
+			return err
+		}
+		s.iterator = iterator
+	}
+
+	output, err := c.client.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+		ShardIterator: s.iterator,
+		Limit:         aws.Int32(recordsPerPoll),
+	})
+	var expiredErr *types.ExpiredIteratorException
+	var trimmedErr *types.TrimmedDataAccessException
+	if errors.As(err, &expiredErr) || errors.As(err, &trimmedErr) {
+		// start again from the checkpoint, or the oldest record left
+		s.iterator = nil
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get records, %v", err)
+	}
+
+	for _, record := range output.Records {
+		change, ok, err := decodeRecord(s.table, record)
+		if err != nil {
+			fmt.Println("Error decoding stream record: " + err.Error())
+		} else if ok {
+			deliver(change)
+		}
+	}
+
+	if len(output.Records) > 0 {
+		last := aws.ToString(output.Records[len(output.Records)-1].Dynamodb.SequenceNumber)
+		if err := c.checkpoints.Set(s.streamARN, s.id, last); err != nil {
+			return err
+		}
+	}
+
+	s.iterator = output.NextShardIterator
+	if s.iterator == nil {
+		s.done = true
+		return c.checkpoints.Set(s.streamARN, s.id, shardEnd)
+	}
+
+	return nil
+}
+
+// shardIterator starts reading a shard after its checkpoint. Without one, a shard is read from its start if its
+// parent was read, and from where the consumer starts otherwise.
+func (c *Consumer) shardIterator(ctx context.Context, s *shard) (*string, error) {
+	checkpoint, err := c.checkpoints.Get(s.streamARN, s.id)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(s.streamARN),
+		ShardId:           aws.String(s.id),
+		ShardIteratorType: c.startAt,
+	}
+	if checkpoint != "" {
+		input.ShardIteratorType = types.ShardIteratorTypeAfterSequenceNumber
+		input.SequenceNumber = aws.String(checkpoint)
+	} else if parent, ok := c.shards[s.parentID]; ok && parent.childrenFromStart {
+		input.ShardIteratorType = types.ShardIteratorTypeTrimHorizon
+	}
+
+	output, err := c.client.GetShardIterator(ctx, input)
+	var trimmedErr *types.TrimmedDataAccessException
+	if errors.As(err, &trimmedErr) {
+		// the checkpoint is older than the records the stream keeps
+		input.ShardIteratorType = types.ShardIteratorTypeTrimHorizon
+		input.SequenceNumber = nil
+		output, err = c.client.GetShardIterator(ctx, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shard iterator, %v", err)
+	}
+
+	return output.ShardIterator, nil
+}
@@ -0,0 +1,243 @@
+// Package streams reads the change streams of the Checklists and ChecklistCollaborators tables.
+package streams
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	dynamotypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+// decodeRecord turns a stream record from one of the tables into a Change. It returns false for records that aren't
+// checklists, items or collaborators, like public links, and for the writes that move a checklist's records to a new
+// owner, other than the checklist's own arrival, which is a ChecklistTransferred change.
+func decodeRecord(table string, record types.Record) (change Change, ok bool, err error) {
+	// records are decoded with the db package's converters, which expect the attributes the app always writes
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			change, ok, err = Change{}, false, fmt.Errorf("malformed %s record: %v", table, recovered)
+		}
+	}()
+
+	if record.Dynamodb == nil {
+		return Change{}, false, nil
+	}
+
+	keys := toDynamoDBMap(record.Dynamodb.Keys)
+	newImage := toDynamoDBMap(record.Dynamodb.NewImage)
+	oldImage := toDynamoDBMap(record.Dynamodb.OldImage)
+
+	change = Change{
+		ID:   aws.ToString(record.Dynamodb.SequenceNumber),
+		Time: aws.ToTime(record.Dynamodb.ApproximateCreationDateTime),
+	}
+
+	pk := stringAttribute(keys, "PK")
+	sk := stringAttribute(keys, "SK")
+	if !strings.HasPrefix(pk, "USER#") || !strings.HasPrefix(sk, "CHECKLIST#") {
+		return Change{}, false, nil
+	}
+
+	transferredFrom := ""
+	if record.EventName == types.OperationTypeInsert {
+		transferredFrom = stringAttribute(newImage, "TransferredFrom")
+	}
+
+	switch table {
+	case "Checklists":
+		change.OwnerID = strings.TrimPrefix(pk, "USER#")
+		checklistID, itemID, isItem := strings.Cut(strings.TrimPrefix(sk, "CHECKLIST#"), "ITEM#")
+		change.ChecklistID = checklistID
+
+		if transferredFrom != "" && !isItem {
+			checklist := db.ChecklistFromRecord(newImage)
+			change.Type, change.Checklist, change.UserID = ChecklistTransferred, &checklist, transferredFrom
+		} else if transferredFrom != "" || transferring(record.EventName, newImage, oldImage) {
+			return Change{}, false, nil
+		} else if isItem {
+			change.ItemID = itemID
+			err = decodeItem(&change, record.EventName, newImage, oldImage)
+		} else {
+			decodeChecklist(&change, record.EventName, newImage, oldImage)
+		}
+	case "ChecklistCollaborators":
+		if transferring(record.EventName, newImage, oldImage) {
+			return Change{}, false, nil
+		}
+
+		change.UserID = strings.TrimPrefix(pk, "USER#")
+		change.ChecklistID = strings.TrimPrefix(sk, "CHECKLIST#")
+		ok = decodeCollaborator(&change, record.EventName, newImage, oldImage)
+		if !ok {
+			return Change{}, false, nil
+		}
+	default:
+		return Change{}, false, nil
+	}
+
+	if err != nil {
+		return Change{}, false, err
+	}
+
+	return change, true, nil
+}
+
+func decodeChecklist(change *Change, eventName types.OperationType, newImage map[string]dynamotypes.AttributeValue, oldImage map[string]dynamotypes.AttributeValue) {
+	if newImage != nil {
+		checklist := db.ChecklistFromRecord(newImage)
+		change.Checklist = &checklist
+	}
+	if oldImage != nil {
+		checklist := db.ChecklistFromRecord(oldImage)
+		change.OldChecklist = &checklist
+	}
+
+	switch eventName {
+	case types.OperationTypeInsert:
+		change.Type = ChecklistCreated
+	case types.OperationTypeRemove:
+		change.Type = ChecklistDeleted
+	default:
+		change.Type = ChecklistUpdated
+	}
+}
+
+func decodeItem(change *Change, eventName types.OperationType, newImage map[string]dynamotypes.AttributeValue, oldImage map[string]dynamotypes.AttributeValue) error {
+	if newImage != nil {
+		item, err := db.ChecklistItemFromRecord(newImage)
+		if err != nil {
+			return err
+		}
+		change.Item = &item
+	}
+	if oldImage != nil {
+		item, err := db.ChecklistItemFromRecord(oldImage)
+		if err != nil {
+			return err
+		}
+		change.OldItem = &item
+	}
+
+	switch {
+	case eventName == types.OperationTypeInsert:
+		change.Type = ItemCreated
+	case eventName == types.OperationTypeRemove:
+		change.Type = ItemDeleted
+	case change.Item != nil && change.OldItem != nil && change.Item.Checked != change.OldItem.Checked:
+		if change.Item.Checked {
+			change.Type = ItemChecked
+		} else {
+			change.Type = ItemUnchecked
+		}
+	default:
+		change.Type = ItemUpdated
+	}
+
+	return nil
+}
+
+// decodeCollaborator fills in a change to who a checklist is shared with. It returns false for updates that don't
+// change the collaborator's role.
+func decodeCollaborator(change *Change, eventName types.OperationType, newImage map[string]dynamotypes.AttributeValue, oldImage map[string]dynamotypes.AttributeValue) bool {
+	image := newImage
+	if image == nil {
+		image = oldImage
+	}
+	change.OwnerID = strings.TrimPrefix(stringAttribute(image, "GSI1PK"), "USER#")
+	change.Role = collaboratorRole(image)
+
+	switch eventName {
+	case types.OperationTypeInsert:
+		change.Type = CollaboratorJoined
+	case types.OperationTypeRemove:
+		change.Type = CollaboratorLeft
+	default:
+		if oldImage == nil || collaboratorRole(oldImage) == change.Role {
+			return false
+		}
+		change.Type = CollaboratorRoleChanged
+	}
+
+	return true
+}
+
+// transferring reports whether a record is being marked for a transfer, or deleted from the previous owner once it
+// has been, rather than changed. An item deleted while its checklist is part way through a transfer looks the same,
+// and is skipped too.
+func transferring(eventName types.OperationType, newImage map[string]dynamotypes.AttributeValue, oldImage map[string]dynamotypes.AttributeValue) bool {
+	switch eventName {
+	case types.OperationTypeModify:
+		return stringAttribute(newImage, "TransferTo") != "" && stringAttribute(oldImage, "TransferTo") == ""
+	case types.OperationTypeRemove:
+		return stringAttribute(oldImage, "TransferTo") != ""
+	default:
+		return false
+	}
+}
+
+// collaboratorRole reads a collaborator record's role. Collaborators without a stored role are editors.
+func collaboratorRole(image map[string]dynamotypes.AttributeValue) models.Role {
+	if role := stringAttribute(image, "Role"); role != "" {
+		return models.Role(role)
+	}
+
+	return models.RoleEditor
+}
+
+func stringAttribute(image map[string]dynamotypes.AttributeValue, name string) string {
+	if value, ok := image[name].(*dynamotypes.AttributeValueMemberS); ok {
+		return value.Value
+	}
+
+	return ""
+}
+
+// toDynamoDBMap converts a stream record's attributes to the DynamoDB client's types, which the db package reads.
+func toDynamoDBMap(image map[string]types.AttributeValue) map[string]dynamotypes.AttributeValue {
+	if image == nil {
+		return nil
+	}
+
+	converted := make(map[string]dynamotypes.AttributeValue, len(image))
+	for name, value := range image {
+		converted[name] = toDynamoDB(value)
+	}
+
+	return converted
+}
+
+func toDynamoDB(value types.AttributeValue) dynamotypes.AttributeValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return &dynamotypes.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &dynamotypes.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &dynamotypes.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &dynamotypes.AttributeValueMemberB{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &dynamotypes.AttributeValueMemberSS{Value: v.Value}
+	case *types.AttributeValueMemberNS:
+		return &dynamotypes.AttributeValueMemberNS{Value: v.Value}
+	case *types.AttributeValueMemberBS:
+		return &dynamotypes.AttributeValueMemberBS{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &dynamotypes.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberM:
+		return &dynamotypes.AttributeValueMemberM{Value: toDynamoDBMap(v.Value)}
+	case *types.AttributeValueMemberL:
+		list := make([]dynamotypes.AttributeValue, len(v.Value))
+		for i, item := range v.Value {
+			list[i] = toDynamoDB(item)
+		}
+		return &dynamotypes.AttributeValueMemberL{Value: list}
+	default:
+		return &dynamotypes.AttributeValueMemberNULL{Value: true}
+	}
+}
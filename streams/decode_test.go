@@ -0,0 +1,205 @@
+// Package streams reads the change streams of the Checklists and ChecklistCollaborators tables.
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+
+	"checklist-api/models"
+)
+
+func itemImage(checked bool) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK":        &types.AttributeValueMemberS{Value: "USER#alice"},
+		"SK":        &types.AttributeValueMemberS{Value: "CHECKLIST#groceriesITEM#milk"},
+		"Entity":    &types.AttributeValueMemberS{Value: "ITEM"},
+		"Content":   &types.AttributeValueMemberS{Value: "Milk"},
+		"Checked":   &types.AttributeValueMemberBOOL{Value: checked},
+		"Ordering":  &types.AttributeValueMemberN{Value: "1"},
+		"Version":   &types.AttributeValueMemberN{Value: "3"},
+		"CreatedAt": &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"},
+		"UpdatedAt": &types.AttributeValueMemberS{Value: "2024-01-02T00:00:00Z"},
+	}
+}
+
+func record(eventName types.OperationType, keys map[string]types.AttributeValue, newImage map[string]types.AttributeValue, oldImage map[string]types.AttributeValue) types.Record {
+	return types.Record{
+		EventName: eventName,
+		Dynamodb: &types.StreamRecord{
+			Keys:                        keys,
+			NewImage:                    newImage,
+			OldImage:                    oldImage,
+			SequenceNumber:              aws.String("100"),
+			ApproximateCreationDateTime: aws.Time(time.Unix(1700000000, 0)),
+		},
+	}
+}
+
+func TestDecodeItemChecked(t *testing.T) {
+	keys := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#alice"},
+		"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#groceriesITEM#milk"},
+	}
+
+	change, ok, err := decodeRecord("Checklists", record(types.OperationTypeModify, keys, itemImage(true), itemImage(false)))
+	if err != nil || !ok {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+
+	if change.Type != ItemChecked {
+		t.Fatalf("Expected %s, but got %s", ItemChecked, change.Type)
+	}
+	if change.OwnerID != "alice" || change.ChecklistID != "groceries" || change.ItemID != "milk" || change.ID != "100" {
+		t.Fatalf("Expected the change to identify the item, but got %+v", change)
+	}
+	if !change.Item.Checked || change.OldItem.Checked || change.Item.Version != 3 {
+		t.Fatalf("Expected the item before and after, but got %+v and %+v", change.OldItem, change.Item)
+	}
+}
+
+func TestDecodeChecklistDeleted(t *testing.T) {
+	keys := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#alice"},
+		"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#groceries"},
+	}
+	oldImage := map[string]types.AttributeValue{
+		"PK":        keys["PK"],
+		"SK":        keys["SK"],
+		"Title":     &types.AttributeValueMemberS{Value: "Groceries"},
+		"Locked":    &types.AttributeValueMemberBOOL{Value: false},
+		"CreatedAt": &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"},
+		"UpdatedAt": &types.AttributeValueMemberS{Value: "2024-01-02T00:00:00Z"},
+	}
+
+	change, ok, err := decodeRecord("Checklists", record(types.OperationTypeRemove, keys, nil, oldImage))
+	if err != nil || !ok {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+
+	if change.Type != ChecklistDeleted || change.Checklist != nil || change.OldChecklist.Title != "Groceries" {
+		t.Fatalf("Expected the deleted checklist, but got %+v", change)
+	}
+}
+
+func TestDecodeCollaborators(t *testing.T) {
+	keys := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#bob"},
+		"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#groceries"},
+	}
+	image := func(role string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"PK":     keys["PK"],
+			"SK":     keys["SK"],
+			"GSI1PK": &types.AttributeValueMemberS{Value: "USER#alice"},
+			"Role":   &types.AttributeValueMemberS{Value: role},
+		}
+	}
+
+	change, ok, err := decodeRecord("ChecklistCollaborators", record(types.OperationTypeInsert, keys, image("viewer"), nil))
+	if err != nil || !ok {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+	if change.Type != CollaboratorJoined || change.UserID != "bob" || change.OwnerID != "alice" || change.Role != models.RoleViewer {
+		t.Fatalf("Expected bob to join alice's checklist as a viewer, but got %+v", change)
+	}
+
+	_, ok, err = decodeRecord("ChecklistCollaborators", record(types.OperationTypeModify, keys, image("viewer"), image("viewer")))
+	if err != nil || ok {
+		t.Fatalf("Expected an update that keeps the role to be skipped, but got %v and %v", ok, err)
+	}
+}
+
+func TestDecodeSkipsOtherRecords(t *testing.T) {
+	keys := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "PUBLIC#token"},
+		"SK": &types.AttributeValueMemberS{Value: "PUBLIC#token"},
+	}
+
+	_, ok, err := decodeRecord("Checklists", record(types.OperationTypeInsert, keys, keys, nil))
+	if err != nil || ok {
+		t.Fatalf("Expected public link records to be skipped, but got %v and %v", ok, err)
+	}
+}
+
+func TestDecodeMalformedRecord(t *testing.T) {
+	keys := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#alice"},
+		"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#groceries"},
+	}
+
+	_, ok, err := decodeRecord("Checklists", record(types.OperationTypeInsert, keys, keys, nil))
+	if err == nil || ok {
+		t.Fatal("Expected a checklist record without its attributes to fail to decode")
+	}
+}
+
+func TestDecodeTransfer(t *testing.T) {
+	itemKeys := func(owner string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + owner},
+			"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#groceriesITEM#milk"},
+		}
+	}
+	marked := itemImage(false)
+	marked["TransferTo"] = &types.AttributeValueMemberS{Value: "bob"}
+	moved := itemImage(false)
+	moved["PK"] = &types.AttributeValueMemberS{Value: "USER#bob"}
+	moved["TransferredFrom"] = &types.AttributeValueMemberS{Value: "alice"}
+
+	skipped := map[string]types.Record{
+		"marking an item":        record(types.OperationTypeModify, itemKeys("alice"), marked, itemImage(false)),
+		"deleting a marked item": record(types.OperationTypeRemove, itemKeys("alice"), nil, marked),
+		"writing the moved item": record(types.OperationTypeInsert, itemKeys("bob"), moved, nil),
+	}
+	for name, skippedRecord := range skipped {
+		if _, ok, err := decodeRecord("Checklists", skippedRecord); err != nil || ok {
+			t.Fatalf("Expected %s to be skipped, but got %v and %v", name, ok, err)
+		}
+	}
+
+	membershipKeys := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#bob"},
+		"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#groceries"},
+	}
+	membership := map[string]types.AttributeValue{
+		"PK":         membershipKeys["PK"],
+		"SK":         membershipKeys["SK"],
+		"GSI1PK":     &types.AttributeValueMemberS{Value: "USER#alice"},
+		"TransferTo": &types.AttributeValueMemberS{Value: "bob"},
+	}
+	if _, ok, err := decodeRecord("ChecklistCollaborators", record(types.OperationTypeRemove, membershipKeys, nil, membership)); err != nil || ok {
+		t.Fatalf("Expected deleting the new owner's membership to be skipped, but got %v and %v", ok, err)
+	}
+
+	checked := itemImage(true)
+	checked["TransferTo"] = &types.AttributeValueMemberS{Value: "bob"}
+	change, ok, err := decodeRecord("Checklists", record(types.OperationTypeModify, itemKeys("alice"), checked, marked))
+	if err != nil || !ok || change.Type != ItemChecked {
+		t.Fatalf("Expected checking a marked item to be a change, but got %+v, %v and %v", change, ok, err)
+	}
+
+	checklistKeys := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#bob"},
+		"SK": &types.AttributeValueMemberS{Value: "CHECKLIST#groceries"},
+	}
+	newImage := map[string]types.AttributeValue{
+		"PK":              checklistKeys["PK"],
+		"SK":              checklistKeys["SK"],
+		"Title":           &types.AttributeValueMemberS{Value: "Groceries"},
+		"Locked":          &types.AttributeValueMemberBOOL{Value: false},
+		"CreatedAt":       &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"},
+		"UpdatedAt":       &types.AttributeValueMemberS{Value: "2024-01-02T00:00:00Z"},
+		"TransferredFrom": &types.AttributeValueMemberS{Value: "alice"},
+	}
+
+	change, ok, err = decodeRecord("Checklists", record(types.OperationTypeInsert, checklistKeys, newImage, nil))
+	if err != nil || !ok {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+	if change.Type != ChecklistTransferred || change.OwnerID != "bob" || change.UserID != "alice" || change.Checklist.Title != "Groceries" {
+		t.Fatalf("Expected the checklist to be transferred from alice to bob, but got %+v", change)
+	}
+}
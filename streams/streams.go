@@ -0,0 +1,97 @@
+// Package streams reads the change streams of the Checklists and ChecklistCollaborators tables, and turns their
+// records into typed changes for the handlers that act on them, like realtime updates and integrations.
+// Changes are delivered at least once, so handlers should tolerate seeing one twice.
+package streams
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"checklist-api/models"
+)
+
+// ChangeType is the kind of change a Change describes.
+type ChangeType string
+
+// The changes read from the streams.
+const (
+	ChecklistCreated ChangeType = "checklist.created"
+	ChecklistUpdated ChangeType = "checklist.updated"
+	ChecklistDeleted ChangeType = "checklist.deleted"
+	ItemCreated      ChangeType = "item.created"
+	// ItemUpdated is an edit to an item other than checking or unchecking it, like to its content or ordering.
+	ItemUpdated   ChangeType = "item.updated"
+	ItemChecked   ChangeType = "item.checked"
+	ItemUnchecked ChangeType = "item.unchecked"
+	ItemDeleted   ChangeType = "item.deleted"
+	// ChecklistTransferred is a checklist moving to a new owner. The records moved along with it aren't changes.
+	ChecklistTransferred ChangeType = "checklist.transferred"
+	// CollaboratorJoined, CollaboratorLeft and CollaboratorRoleChanged are changes to who a checklist is shared with.
+	CollaboratorJoined      ChangeType = "collaborator.joined"
+	CollaboratorLeft        ChangeType = "collaborator.left"
+	CollaboratorRoleChanged ChangeType = "collaborator.role_changed"
+)
+
+// Change is a change to a checklist, read from a table's stream.
+type Change struct {
+	Type ChangeType
+	// ID is the stream record's sequence number, which handlers can use to spot changes they've already seen.
+	ID          string
+	Time        time.Time
+	OwnerID     string
+	ChecklistID string
+	ItemID      string
+	// UserID and Role are the collaborator, for changes to who a checklist is shared with. For transfers, UserID is
+	// the previous owner.
+	UserID string
+	Role   models.Role
+	// Checklist and Item are the record after the change, and OldChecklist and OldItem before it.
+	// Each is nil when there is no such record, like before a creation or after a deletion.
+	Checklist    *models.Checklist
+	OldChecklist *models.Checklist
+	Item         *models.ChecklistItem
+	OldItem      *models.ChecklistItem
+}
+
+// Handler acts on the changes read from the streams.
+type Handler interface {
+	HandleChange(change Change) error
+}
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+)
+
+// Register adds a handler that every change is delivered to.
+func Register(handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	handlers = append(handlers, handler)
+}
+
+// deliver hands a change to every registered handler. The change is checkpointed either way, so a handler that
+// fails is logged, and should keep anything it has to retry itself.
+func deliver(change Change) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler.HandleChange(change); err != nil {
+			fmt.Printf("Error handling %s change for checklist %s: %v\n", change.Type, change.ChecklistID, err)
+		}
+	}
+}
+
+// Enabled reports whether the streams are read. STREAMS_ENABLED turns it on.
+func Enabled() bool {
+	return os.Getenv("STREAMS_ENABLED") == "true"
+}
+
+// Local reports whether the streams are DynamoDB Local's, in development.
+func Local() bool {
+	return os.Getenv("ENVIRONMENT") == "development"
+}
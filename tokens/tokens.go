@@ -0,0 +1,29 @@
+// Package tokens generates the random bearer tokens the app hands out, like personal access tokens, ingest secrets
+// and invitation links, and hashes them for storage. Only the hashes are stored, so the tokens can't be shown again.
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// New generates a token starting with prefix, which tells the kinds of tokens apart, and the hash it should be
+// stored under.
+func New(prefix string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token := prefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	return token, Hash(token), nil
+}
+
+// Hash hashes a token for storage and lookup. The tokens are random and long, so a fast unsalted hash is enough.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%x", sum)
+}
@@ -0,0 +1,26 @@
+// Package tokens generates the random bearer tokens the app hands out, and hashes them for storage.
+package tokens
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	token, hash, err := New("listo_test_")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	if !strings.HasPrefix(token, "listo_test_") {
+		t.Fatalf("Expected the token to start with listo_test_, but got %s", token)
+	}
+	if hash != Hash(token) || strings.Contains(hash, token) {
+		t.Fatal("Expected the token's hash to be returned, not the token")
+	}
+
+	other, _, err := New("listo_test_")
+	if err != nil || other == token {
+		t.Fatal("Expected every token to be different")
+	}
+}
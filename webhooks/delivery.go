@@ -0,0 +1,273 @@
+// Package webhooks POSTs checklist events to the URLs users subscribe.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"checklist-api/db"
+	"checklist-api/models"
+)
+
+const (
+	// maxAttempts is how many times a delivery is tried before it fails. The seven waits between the attempts add up
+	// to 127 times firstRetryDelay, so a receiver that stays down fails deliveries after about 63 minutes.
+	maxAttempts = 8
+	// firstRetryDelay is the wait before the first retry, which doubles with each one after.
+	firstRetryDelay = 30 * time.Second
+	// deliveryTTL is how long deliveries are kept for their logs, and can be redelivered.
+	deliveryTTL = 7 * 24 * time.Hour
+	// deliveriesKept is how many of each webhook's deliveries are logged.
+	deliveriesKept = 100
+	// claimTimeout is how long an attempt has before another instance may try the delivery again.
+	claimTimeout = time.Minute
+	// workerInterval is how often the queue is checked for due deliveries.
+	workerInterval = time.Second
+	// deliveriesPerPoll is the most deliveries attempted at once by each instance.
+	deliveriesPerPoll = 20
+)
+
+// ErrDeliveryNotFound is returned when a delivery doesn't exist, or belongs to another webhook.
+var ErrDeliveryNotFound = errors.New("delivery does not exist")
+
+// client POSTs the deliveries. It doesn't follow redirects, which count as failed attempts, and it only connects to
+// public addresses, so webhooks can't be pointed at services on the server's own network. It doesn't use a proxy
+// either, since the address it connects to is the one that's checked.
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: checkDialAddress}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// Sign computes a delivery's signature: the hex HMAC-SHA256, under the webhook's secret, of the timestamp, a dot and
+// the body. It is sent in the X-Listo-Signature header, prefixed with "sha256=".
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay is the wait before retrying a delivery that has failed attempts times.
+func retryDelay(attempts int) time.Duration {
+	return firstRetryDelay << (attempts - 1)
+}
+
+// enqueue stores a new delivery and queues it to be attempted straight away.
+func enqueue(redisService *db.RedisService, webhookID string, event string, payload string, redeliveryOf string) (models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		ID:           uuid.New().String(),
+		WebhookID:    webhookID,
+		Event:        event,
+		Payload:      payload,
+		Status:       models.WebhookDeliveryPending,
+		RedeliveryOf: redeliveryOf,
+		CreatedAt:    time.Now().Format(time.RFC3339),
+	}
+
+	if err := saveDelivery(redisService, delivery, true); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	return delivery, redisService.QueueWebhookDelivery(delivery.ID, time.Now())
+}
+
+func saveDelivery(redisService *db.RedisService, delivery models.WebhookDelivery, isNew bool) error {
+	payload, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	return redisService.SaveWebhookDelivery(delivery.WebhookID, delivery.ID, payload, isNew, deliveriesKept, deliveryTTL)
+}
+
+func getDelivery(redisService *db.RedisService, deliveryID string) (models.WebhookDelivery, error) {
+	payload, err := redisService.GetWebhookDelivery(deliveryID)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	} else if payload == nil {
+		return models.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+
+	var delivery models.WebhookDelivery
+	err = json.Unmarshal(payload, &delivery)
+	return delivery, err
+}
+
+// Deliveries returns a webhook's recent deliveries, newest first.
+func Deliveries(webhookID string) ([]models.WebhookDelivery, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, err
+	}
+	defer redisService.Client.Close()
+
+	payloads, err := redisService.GetWebhookDeliveries(webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	for _, payload := range payloads {
+		var delivery models.WebhookDelivery
+		if err := json.Unmarshal(payload, &delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues a new delivery of a past delivery's payload to its webhook.
+func Redeliver(webhookID string, deliveryID string) (models.WebhookDelivery, error) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	defer redisService.Client.Close()
+
+	original, err := getDelivery(redisService, deliveryID)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	} else if original.WebhookID != webhookID {
+		return models.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+
+	return enqueue(redisService, webhookID, original.Event, original.Payload, original.ID)
+}
+
+// RunWorker attempts the deliveries that are due, until the context is done. Every instance can run it, since each
+// delivery is claimed before it is attempted.
+func RunWorker(ctx context.Context) {
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		fmt.Println("Webhook deliveries are off, Redis is unavailable: " + err.Error())
+		return
+	}
+	defer redisService.Client.Close()
+
+	dynamo, err := db.NewDynamoDBService()
+	if err != nil {
+		fmt.Println("Webhook deliveries are off: " + err.Error())
+		return
+	}
+
+	ticker := time.NewTicker(workerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		deliveryIDs, err := redisService.ClaimDueWebhookDeliveries(now, now.Add(claimTimeout), deliveriesPerPoll)
+		if err != nil {
+			fmt.Println("Error claiming webhook deliveries: " + err.Error())
+			continue
+		}
+
+		for _, deliveryID := range deliveryIDs {
+			if err := attempt(ctx, redisService, dynamo, deliveryID); err != nil {
+				fmt.Printf("Error attempting webhook delivery %s: %v\n", deliveryID, err)
+			}
+		}
+	}
+}
+
+// attempt POSTs a delivery to its webhook, and records the outcome. A failed attempt is retried later, unless the
+// delivery has run out of attempts or its webhook has been deleted.
+func attempt(ctx context.Context, redisService *db.RedisService, dynamo *db.DynamoDBService, deliveryID string) error {
+	delivery, err := getDelivery(redisService, deliveryID)
+	if errors.Is(err, ErrDeliveryNotFound) {
+		return redisService.DequeueWebhookDelivery(deliveryID)
+	} else if err != nil {
+		return err
+	}
+
+	userID, webhook, secret, err := dynamo.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = time.Now().Format(time.RFC3339)
+	delivery.NextAttemptAt = ""
+
+	if userID == "" {
+		delivery.Status, delivery.Error = models.WebhookDeliveryFailed, "webhook was deleted"
+	} else {
+		delivery.ResponseStatus, err = post(ctx, webhook.URL, secret, delivery)
+		switch {
+		case err == nil:
+			delivery.Status, delivery.Error = models.WebhookDeliverySucceeded, ""
+		case delivery.Attempts >= maxAttempts:
+			delivery.Status, delivery.Error = models.WebhookDeliveryFailed, err.Error()
+		default:
+			next := time.Now().Add(retryDelay(delivery.Attempts))
+			delivery.Error, delivery.NextAttemptAt = err.Error(), next.Format(time.RFC3339)
+			if err := saveDelivery(redisService, delivery, false); err != nil {
+				return err
+			}
+			return redisService.QueueWebhookDelivery(delivery.ID, next)
+		}
+	}
+
+	if err := saveDelivery(redisService, delivery, false); err != nil {
+		return err
+	}
+
+	return redisService.DequeueWebhookDelivery(delivery.ID)
+}
+
+// post sends a delivery, and returns the response's status. Any status outside 2xx is an error.
+func post(ctx context.Context, url string, secret string, delivery models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Listo-Webhooks")
+	request.Header.Set("X-Listo-Event", delivery.Event)
+	request.Header.Set("X-Listo-Delivery", delivery.ID)
+	request.Header.Set("X-Listo-Timestamp", timestamp)
+	request.Header.Set("X-Listo-Signature", Sign(secret, timestamp, body))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook responded with %s", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
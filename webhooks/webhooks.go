@@ -0,0 +1,286 @@
+// Package webhooks POSTs checklist events to the URLs users subscribe. Events come from the tables' change streams,
+// and each delivery is queued in Redis, signed with the webhook's secret, and retried with backoff until it succeeds.
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"checklist-api/completion"
+	"checklist-api/db"
+	"checklist-api/models"
+	"checklist-api/streams"
+)
+
+// ChecklistCompleted is sent when the last unchecked item on a checklist is checked. It is sent again only after
+// the checklist has been reopened, by unchecking or adding an item.
+const ChecklistCompleted = "checklist.completed"
+
+// Events are the events webhooks can subscribe to.
+var Events = []string{
+	string(streams.ChecklistUpdated),
+	string(streams.ChecklistDeleted),
+	string(streams.ChecklistTransferred),
+	ChecklistCompleted,
+	string(streams.ItemCreated),
+	string(streams.ItemUpdated),
+	string(streams.ItemChecked),
+	string(streams.ItemUnchecked),
+	string(streams.ItemDeleted),
+	string(streams.CollaboratorJoined),
+	string(streams.CollaboratorLeft),
+	string(streams.CollaboratorRoleChanged),
+}
+
+// enabled is set once this instance's Handler is reading the change streams, which every delivery comes from.
+var enabled atomic.Bool
+
+// Enable records that webhooks are delivered, once a Handler is registered with the change streams and the delivery
+// worker is running.
+func Enable() {
+	enabled.Store(true)
+}
+
+// Enabled reports whether webhooks are delivered. Without the change streams, no events reach them.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// ErrInvalidURL is returned for webhook URLs that aren't absolute HTTPS URLs.
+var ErrInvalidURL = errors.New("webhook URLs must be absolute https URLs")
+
+// ErrForbiddenAddress is returned for webhook URLs that point at a private, loopback or link-local address.
+var ErrForbiddenAddress = errors.New("webhook URLs can't point at private, loopback or link-local addresses")
+
+// Payload is the JSON body POSTed to a webhook. Its ID stays the same across retries and redeliveries, so receivers
+// can spot events they have already handled.
+type Payload struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	ChecklistID string      `json:"checklist_id"`
+	Data        interface{} `json:"data,omitempty"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+// CollaboratorData is the data of the collaborator events.
+type CollaboratorData struct {
+	Collaborator models.Collaborator `json:"collaborator"`
+	Role         models.Role         `json:"role"`
+}
+
+// NewSecret generates a secret for signing a webhook's deliveries.
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// ValidateURL checks that a webhook URL is absolute and uses HTTPS, and that its host isn't a private, loopback or
+// link-local address. Plain HTTP and local receivers are allowed in development. Hosts with names are checked again
+// when deliveries connect, since they can resolve to anything.
+func ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+
+	development := os.Getenv("ENVIRONMENT") == "development"
+	if parsed.Scheme != "https" && !(parsed.Scheme == "http" && development) {
+		return ErrInvalidURL
+	}
+
+	if !development {
+		host := parsed.Hostname()
+		if ip := net.ParseIP(host); (ip != nil && forbiddenIP(ip)) || strings.EqualFold(host, "localhost") {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// sharedAddressSpace is the range carrier-grade NAT uses, which is as internal as the private ranges.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// forbiddenIP reports whether an address is on the server's own network or host, which webhooks mustn't reach.
+func forbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// checkDialAddress refuses connections to forbidden addresses, after the webhook's host has been resolved, so a
+// name pointing at an internal address can't get around ValidateURL. Anything goes in development.
+func checkDialAddress(network string, address string, _ syscall.RawConn) error {
+	if os.Getenv("ENVIRONMENT") == "development" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || forbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// Handler is a streams.Handler that queues deliveries for the webhooks subscribed to each change.
+type Handler struct {
+	dynamo *db.DynamoDBService
+	redis  *db.RedisService
+}
+
+// NewHandler sets up a Handler.
+func NewHandler() (*Handler, error) {
+	dynamo, err := db.NewDynamoDBService()
+	if err != nil {
+		return nil, err
+	}
+
+	redisService, err := db.NewRedisService()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{dynamo: dynamo, redis: redisService}, nil
+}
+
+// HandleChange queues the change for every webhook subscribed to it, and reports the checklist as completed if the
+// change checked its last unchecked item. A transfer arrives as a single checklist.transferred change, which reaches
+// the webhooks of the new owner, the collaborators and the previous owner.
+func (h *Handler) HandleChange(change streams.Change) error {
+	reopened := change.Type == streams.ItemUnchecked || (change.Type == streams.ItemCreated && !change.Item.Checked)
+	if reopened {
+		if err := completion.Reopen(h.redis, change.ChecklistID); err != nil {
+			return err
+		}
+	}
+
+	userIDs, err := h.dynamo.GetCollaboratorIDs(change.OwnerID, change.ChecklistID)
+	if err != nil {
+		return err
+	}
+	userIDs = append(userIDs, change.OwnerID)
+	if change.UserID != "" && !slices.Contains(userIDs, change.UserID) {
+		userIDs = append(userIDs, change.UserID)
+	}
+
+	webhooks, owners, err := h.dynamo.GetWebhookSubscribers(change.ChecklistID, userIDs)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	payload, err := h.payload(change)
+	if err != nil {
+		return err
+	}
+	if err := h.queue(webhooks, owners, payload); err != nil {
+		return err
+	}
+
+	if change.Type != streams.ItemChecked {
+		return nil
+	}
+
+	return h.checkCompleted(change, webhooks, owners)
+}
+
+// checkCompleted queues checklist.completed if every item on the checklist is now checked.
+func (h *Handler) checkCompleted(change streams.Change, webhooks map[string]models.Webhook, owners map[string]string) error {
+	completed, err := completion.Check(h.dynamo, h.redis, completion.Webhooks, change.OwnerID, change.ChecklistID)
+	if err != nil || !completed {
+		return err
+	}
+
+	checklist, err := h.dynamo.GetChecklist(change.OwnerID, change.ChecklistID)
+	if err != nil {
+		return err
+	}
+
+	return h.queue(webhooks, owners, Payload{
+		ID:          change.ID + "-completed",
+		Type:        ChecklistCompleted,
+		ChecklistID: change.ChecklistID,
+		Data:        checklist,
+		CreatedAt:   change.Time.Format(time.RFC3339),
+	})
+}
+
+// payload builds the body sent for a change.
+func (h *Handler) payload(change streams.Change) (Payload, error) {
+	payload := Payload{
+		ID:          change.ID,
+		Type:        string(change.Type),
+		ChecklistID: change.ChecklistID,
+		CreatedAt:   change.Time.Format(time.RFC3339),
+	}
+
+	switch {
+	case change.Checklist != nil:
+		payload.Data = change.Checklist
+	case change.OldChecklist != nil:
+		payload.Data = change.OldChecklist
+	case change.Item != nil:
+		payload.Data = change.Item
+	case change.OldItem != nil:
+		payload.Data = change.OldItem
+	case change.UserID != "":
+		user, err := h.dynamo.GetUser(change.UserID)
+		if err != nil {
+			return Payload{}, err
+		}
+		payload.Data = CollaboratorData{
+			Collaborator: models.Collaborator{Email: user.Email, Picture: user.Picture},
+			Role:         change.Role,
+		}
+	}
+
+	return payload, nil
+}
+
+// queue queues a delivery of the payload to every webhook subscribed to its type. Webhooks limited to a checklist
+// their user can no longer see are skipped.
+func (h *Handler) queue(webhooks map[string]models.Webhook, owners map[string]string, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for id, webhook := range webhooks {
+		if !slices.Contains(webhook.Events, payload.Type) {
+			continue
+		}
+
+		if webhook.ChecklistID != "" {
+			ownerID, _, err := h.dynamo.GetChecklistAccess(owners[id], webhook.ChecklistID)
+			if err != nil {
+				return err
+			} else if ownerID == "" {
+				continue
+			}
+		}
+
+		if _, err := enqueue(h.redis, webhook.ID, payload.Type, string(body), ""); err != nil {
+			return fmt.Errorf("failed to queue delivery, %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package webhooks POSTs checklist events to the URLs users subscribe.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"item.checked"}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if signature := Sign("whsec_test", "1700000000", body); signature != expected {
+		t.Fatalf("Expected %s, but got %s", expected, signature)
+	}
+	if Sign("whsec_other", "1700000000", body) == expected {
+		t.Fatal("Expected a different secret to give a different signature")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if retryDelay(1) != 30*time.Second || retryDelay(2) != time.Minute || retryDelay(4) != 4*time.Minute {
+		t.Fatalf("Expected the delay to double from 30s, but got %v, %v and %v", retryDelay(1), retryDelay(2), retryDelay(4))
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+
+	other, _ := NewSecret()
+	if !strings.HasPrefix(secret, "whsec_") || secret == other {
+		t.Fatalf("Expected distinct whsec_ secrets, but got %s and %s", secret, other)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+
+	if err := ValidateURL("https://hooks.example.com/listo"); err != nil {
+		t.Fatalf("Expected an https URL to be valid, but got %v", err)
+	}
+
+	for _, rawURL := range []string{"http://hooks.example.com/listo", "/listo", "ftp://example.com", ""} {
+		if err := ValidateURL(rawURL); !errors.Is(err, ErrInvalidURL) {
+			t.Fatalf("Expected %q to be invalid, but got %v", rawURL, err)
+		}
+	}
+
+	for _, rawURL := range []string{
+		"https://localhost/listo", "https://127.0.0.1/listo", "https://10.0.0.5/listo", "https://192.168.1.1/listo",
+		"https://169.254.169.254/latest/meta-data", "https://[::1]/listo", "https://[fd00::1]/listo", "https://0.0.0.0/listo",
+		"https://100.64.0.1/listo",
+	} {
+		if err := ValidateURL(rawURL); !errors.Is(err, ErrForbiddenAddress) {
+			t.Fatalf("Expected %q to be forbidden, but got %v", rawURL, err)
+		}
+	}
+
+	t.Setenv("ENVIRONMENT", "development")
+	if err := ValidateURL("http://localhost:9000/listo"); err != nil {
+		t.Fatalf("Expected http URLs to be valid in development, but got %v", err)
+	}
+}
+
+func TestCheckDialAddress(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+
+	if err := checkDialAddress("tcp4", "93.184.216.34:443", nil); err != nil {
+		t.Fatalf("Expected a public address to be allowed, but got %v", err)
+	}
+	for _, address := range []string{"127.0.0.1:443", "10.1.2.3:443", "172.16.0.1:80", "169.254.169.254:80", "[::1]:443", "[::ffff:10.0.0.1]:443"} {
+		if err := checkDialAddress("tcp", address, nil); !errors.Is(err, ErrForbiddenAddress) {
+			t.Fatalf("Expected %s to be refused, but got %v", address, err)
+		}
+	}
+
+	t.Setenv("ENVIRONMENT", "development")
+	if err := checkDialAddress("tcp4", "127.0.0.1:9000", nil); err != nil {
+		t.Fatalf("Expected local addresses to be allowed in development, but got %v", err)
+	}
+}
+
+func TestClientRefusesRedirects(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+
+	redirected := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			redirected = true
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer server.Close()
+
+	response, err := client.Post(server.URL+"/hook", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusFound || redirected {
+		t.Fatalf("Expected the redirect to be returned, not followed, but got %s", response.Status)
+	}
+}